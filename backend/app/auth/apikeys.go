@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fondo-mod/data"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiKeyHeader is the header used by service accounts to send their API key
+const apiKeyHeader = "X-API-Key"
+
+// statusRecorder keeps the status written by the handler so it can be stored on the use of the key
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	s.status = status
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// validateAPIKey validates a key of the form ffk_<prefijo>_<secreto>, its scopes are the permissions
+// of the returned user. The user has the id of the key once it is found, even when it is rejected
+func (h *Auth) validateAPIKey(key string) (bool, data.User, error) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != "ffk" {
+		return false, data.User{}, nil
+	}
+
+	k, err := h.u.GetAPIKey(parts[1])
+	if err == data.ErrAPIKeyNotFound {
+		return false, data.User{}, nil
+	}
+	if err != nil {
+		return false, data.User{}, err
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(k.ClaveHash)) != 1 {
+		return false, data.User{}, nil
+	}
+
+	us := data.User{Fondo: k.IDFondo, APIKey: k.ID, Permisos: k.Scopes}
+	if !k.Valid(time.Now()) {
+		h.l.Info("[validateAPIKey] API key is revoked or expired", "key", k.ID)
+		return false, us, nil
+	}
+
+	return true, us, nil
+}
+
+// recordAPIKeyUse stores the request made with the key, a failure is only logged
+func (h *Auth) recordAPIKeyUse(us data.User, r *http.Request, status int) {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	err = h.u.RecordAPIKeyUse(us.APIKey, r.Method, r.URL.Path, status, ip)
+	if err != nil {
+		h.l.Error("[recordAPIKeyUse] Error recording use of API key", "key", us.APIKey, "error", err)
+	}
+}
@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hashicorp/go-hclog"
+)
+
+// ErrKeyNotFound is raised when a token is signed with a kid that is not published
+var ErrKeyNotFound = fmt.Errorf("Signing key not found")
+
+// keysTTL is how long the fetched keys are used before asking for them again
+const keysTTL = 10 * time.Minute
+
+// minRefreshInterval limits how often an unknown kid can force a new fetch
+const minRefreshInterval = 30 * time.Second
+
+// jwk is a public key as published by the authentication api
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+}
+
+// publicKey is a parsed jwk
+type publicKey struct {
+	alg string
+	key interface{}
+}
+
+// KeySet fetches and caches the public keys published by the authentication api
+type KeySet struct {
+	l       hclog.Logger
+	url     string
+	client  *http.Client
+	mu      sync.RWMutex
+	keys    map[string]publicKey
+	fetched time.Time
+}
+
+// NewKeySet creates a key set for the given JWKS url, caPath is an optional
+// PEM file used to trust the certificate of the authentication api
+func NewKeySet(l hclog.Logger, url string, caPath string) (*KeySet, error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	if caPath != "" {
+		ca, err := ioutil.ReadFile(caPath)
+		if err != nil {
+			return nil, err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("No certificates found on %s", caPath)
+		}
+
+		client.Transport = &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}
+	}
+
+	return &KeySet{l: l, url: url, client: client, keys: map[string]publicKey{}}, nil
+}
+
+// Keyfunc returns the public key used to verify a token based on its kid header,
+// keys are fetched again when they are stale or the kid is unknown
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k.mu.RLock()
+	pk, ok := k.keys[kid]
+	fetched := k.fetched
+	k.mu.RUnlock()
+
+	stale := time.Since(fetched) > keysTTL
+	if stale || (!ok && time.Since(fetched) > minRefreshInterval) {
+		err := k.refresh()
+		if err != nil {
+			// keep using the cached keys while the authentication api is unreachable
+			k.l.Error("[Keyfunc] Fetching signing keys", "url", k.url, "error", err)
+		}
+
+		k.mu.RLock()
+		pk, ok = k.keys[kid]
+		k.mu.RUnlock()
+	}
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	if token.Method.Alg() != pk.alg {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return pk.key, nil
+}
+
+func (k *KeySet) refresh() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	// another request refreshed the keys while this one was waiting
+	if time.Since(k.fetched) < minRefreshInterval {
+		return nil
+	}
+	k.fetched = time.Now()
+
+	resp, err := k.client.Get(k.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Unexpected status %d", resp.StatusCode)
+	}
+
+	set := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	if err != nil {
+		return err
+	}
+
+	keys := map[string]publicKey{}
+	for _, j := range set.Keys {
+		pk, err := parseJWK(j)
+		if err != nil {
+			k.l.Error("[refresh] Ignoring signing key", "kid", j.Kid, "error", err)
+			continue
+		}
+		keys[j.Kid] = pk
+	}
+
+	k.l.Debug("[refresh] Fetched signing keys", "keys", len(keys))
+	k.keys = keys
+
+	return nil
+}
+
+func parseJWK(j jwk) (publicKey, error) {
+	switch {
+	case j.Kty == "RSA" && j.Alg == jwt.SigningMethodRS256.Alg():
+		n, err := base64.RawURLEncoding.DecodeString(j.N)
+		if err != nil {
+			return publicKey{}, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(j.E)
+		if err != nil {
+			return publicKey{}, err
+		}
+
+		key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		return publicKey{alg: j.Alg, key: key}, nil
+	case j.Kty == "OKP" && j.Crv == "Ed25519" && j.Alg == SigningMethodEd25519.Alg():
+		x, err := base64.RawURLEncoding.DecodeString(j.X)
+		if err != nil {
+			return publicKey{}, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return publicKey{}, fmt.Errorf("Invalid Ed25519 key size %d", len(x))
+		}
+
+		return publicKey{alg: j.Alg, key: ed25519.PublicKey(x)}, nil
+	}
+
+	return publicKey{}, fmt.Errorf("Unsupported key %s %s", j.Kty, j.Alg)
+}
@@ -1,20 +1,21 @@
 package auth
 
 import (
-	"fmt"
 	"fondo-mod/data"
-	"os"
-	"strconv"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/hashicorp/go-hclog"
 )
 
-var signKey = []byte(os.Getenv("secretAuthKey"))
-
 // Auth define a object of auth to validate requests tokens
 type Auth struct {
 	l hclog.Logger
+	u *data.UserService
+	k *KeySet
+	// p gives the permissions of each rol
+	p data.Policy
+	// requireMFA rejects administrator tokens issued without a second factor
+	requireMFA bool
 }
 
 // AuthError is a generic auth error message returned by a server
@@ -23,47 +24,108 @@ type AuthError struct {
 }
 
 // New creates a new auth validator instance
-func New(l hclog.Logger) *Auth {
+func New(l hclog.Logger, u *data.UserService, k *KeySet, p data.Policy, requireMFA bool) *Auth {
 	l.Debug("[New] Creating new auth instance")
 
-	return &Auth{l}
+	return &Auth{l, u, k, p, requireMFA}
 }
 
 // KeyClient usada para el middleware
 type KeyClient struct{}
 
-// ValidateToken validates a user request to be signed with a JWT token
-func (h *Auth) validateToken(t string, l string) (bool, data.User, error) {
+// ValidateToken validates a user request to be signed with a JWT token, the user gets the permissions of its rol
+func (h *Auth) validateToken(t string) (bool, data.User, error) {
 	h.l.Info("[validateToken] Validating token")
 
-	token, err := jwt.Parse(t, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("[validateToken] Unexpected signing method: %v", token.Header["alg"])
-		}
-
-		return signKey, nil
-	})
+	token, err := jwt.Parse(t, h.k.Keyfunc)
 
 	if err != nil {
 		return false, data.User{}, err
 	}
 
 	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		rol := claims["rol"].(float64)
+		// tokens with a purpose, like email verification, can't be used as access tokens
+		if _, ok := claims["purpose"]; ok {
+			return false, data.User{}, nil
+		}
+
+		rol := int(claims["rol"].(float64))
 		email := claims["email"].(string)
 		id := claims["id"].(float64)
 
-		s := fmt.Sprintf("%.0f", rol)
-		if s <= l {
-			rol, err := strconv.Atoi(s)
+		// every query is scoped to the fondo of the token, older tokens have to be refreshed
+		fondo, _ := claims["fondo"].(float64)
+		if fondo == 0 {
+			h.l.Info("[validateToken] Token without fondo", "id", id)
+			return false, data.User{}, nil
+		}
+
+		if jti, ok := claims["jti"].(string); ok {
+			revoked, err := h.u.IsTokenRevoked(jti)
 			if err != nil {
 				return false, data.User{}, err
 			}
-			return true, data.User{ID: int(id), Rol: rol, Email: email}, nil
+			if revoked {
+				h.l.Info("[validateToken] Token has been revoked", "id", id)
+				return false, data.User{}, nil
+			}
+		}
+
+		if sid, ok := claims["sid"].(string); ok && sid != "" {
+			revoked, err := h.u.IsSessionRevoked(sid)
+			if err != nil {
+				return false, data.User{}, err
+			}
+			if revoked {
+				h.l.Info("[validateToken] Session has been revoked", "id", id)
+				return false, data.User{}, nil
+			}
 		}
 
+		// tokens issued before a deactivation, or a role change in the fondo of the token, are not accepted
+		ver, _ := claims["ver"].(float64)
+		fver, _ := claims["fver"].(float64)
+		state, err := h.u.GetTokenState(int(id), int(fondo))
+		if err != nil {
+			return false, data.User{}, err
+		}
+		if state.Version != int(ver) || state.FondoVersion != int(fver) {
+			h.l.Info("[validateToken] Token version is outdated", "id", id)
+			return false, data.User{}, nil
+		}
+
+		// members wait for an administrator of the fondo to approve them
+		if state.Estado != data.EstadoAprobado {
+			h.l.Info("[validateToken] User is not approved", "id", id, "estado", state.Estado)
+			return false, data.User{}, nil
+		}
+
+		// members that left the fondo through a liquidacion, or were deactivated in it, can't use it anymore
+		if !state.MiembroActivo {
+			h.l.Info("[validateToken] User is not an active member of the fondo", "id", id, "fondo", fondo)
+			return false, data.User{}, nil
+		}
+
+		if h.requireMFA && rol == 1 && !hasAmr(claims, "otp") {
+			h.l.Info("[validateToken] Administrator token without two factor authentication", "id", id)
+			return false, data.User{}, nil
+		}
+
+		return true, data.User{ID: int(id), Rol: rol, Email: email, Fondo: int(fondo), Permisos: h.p.Permissions(rol)}, nil
 	}
 
 	return false, data.User{}, err
 
 }
+
+// hasAmr returns true if the given method is on the amr claim of the token
+func hasAmr(claims jwt.MapClaims, method string) bool {
+	amr, _ := claims["amr"].([]interface{})
+	for _, m := range amr {
+		if m == method {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,144 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fondo-mod/data"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/hashicorp/go-hclog"
+)
+
+// newMockAuth returns an Auth on a mocked database that trusts the returned key, the expectations are
+// checked when the test ends
+func newMockAuth(t *testing.T, requireMFA bool) (*Auth, sqlmock.Sqlmock, ed25519.PrivateKey) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := hclog.NewNullLogger()
+	k := &KeySet{l: l, keys: map[string]publicKey{"test": {alg: SigningMethodEd25519.Alg(), key: pub}}, fetched: time.Now()}
+
+	return New(l, data.NewUserService(db, l), k, data.DefaultPolicy(), requireMFA), mock, priv
+}
+
+// sign returns a token with the claims signed by the key of newMockAuth
+func sign(t *testing.T, key ed25519.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(SigningMethodEd25519, claims)
+	token.Header["kid"] = "test"
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
+
+func TestValidateToken(t *testing.T) {
+	type state struct {
+		ver, fver int
+		estado    string
+		activo    bool
+	}
+	aprobado := &state{2, 5, data.EstadoAprobado, true}
+
+	tests := []struct {
+		name       string
+		claims     jwt.MapClaims
+		requireMFA bool
+		revokedJTI bool
+		revokedSid bool
+		state      *state
+		valid      bool
+	}{
+		{name: "valid", state: aprobado, valid: true},
+		{name: "user version outdated", state: &state{3, 5, data.EstadoAprobado, true}},
+		{name: "fondo version outdated", state: &state{2, 6, data.EstadoAprobado, true}},
+		{name: "token of an older membership", claims: jwt.MapClaims{"fver": nil}, state: aprobado},
+		{name: "not approved in the fondo", state: &state{2, 5, "pendiente", true}},
+		{name: "not an active member", state: &state{2, 5, data.EstadoAprobado, false}},
+		{name: "revoked token", revokedJTI: true},
+		{name: "revoked session", revokedSid: true},
+		{name: "token with a purpose", claims: jwt.MapClaims{"purpose": "verify-email"}},
+		{name: "token without fondo", claims: jwt.MapClaims{"fondo": nil}},
+		{name: "administrator without second factor", claims: jwt.MapClaims{"rol": 1}, requireMFA: true, state: aprobado},
+		{name: "administrator with second factor", claims: jwt.MapClaims{"rol": 1, "amr": []string{"pwd", "otp"}}, requireMFA: true, state: aprobado, valid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock, key := newMockAuth(t, tt.requireMFA)
+
+			claims := jwt.MapClaims{"id": 7, "rol": 3, "email": "ana@x.co", "jti": "jti", "sid": "sid", "amr": []string{"pwd"},
+				"ver": 2, "fver": 5, "fondo": 2, "exp": time.Now().Add(time.Minute).Unix()}
+			for k, v := range tt.claims {
+				if v == nil {
+					delete(claims, k)
+					continue
+				}
+				claims[k] = v
+			}
+
+			if tt.state != nil || tt.revokedJTI || tt.revokedSid {
+				jti := sqlmock.NewRows([]string{"jti"})
+				if tt.revokedJTI {
+					jti.AddRow("jti")
+				}
+				mock.ExpectQuery("SELECT jti FROM revoked_tokens WHERE jti = \\?").WithArgs("jti").WillReturnRows(jti)
+			}
+			if tt.state != nil || tt.revokedSid {
+				sid := sqlmock.NewRows([]string{"familia"})
+				if tt.revokedSid {
+					sid.AddRow("sid")
+				}
+				mock.ExpectQuery("SELECT familia FROM sesiones WHERE familia = \\? AND revocada = 1").WithArgs("sid").WillReturnRows(sid)
+			}
+			if tt.state != nil {
+				mock.ExpectQuery("FROM usuario u\\s+LEFT JOIN fondo_usuario fu").WithArgs(2, 7).
+					WillReturnRows(sqlmock.NewRows([]string{"ver", "fver", "estado", "activo"}).AddRow(tt.state.ver, tt.state.fver, tt.state.estado, tt.state.activo))
+			}
+
+			valid, us, err := h.validateToken(sign(t, key, claims))
+			if err != nil {
+				t.Fatalf("validateToken error = %v", err)
+			}
+			if valid != tt.valid {
+				t.Errorf("validateToken = %v, want %v", valid, tt.valid)
+			}
+			if valid && (us.ID != 7 || us.Fondo != 2 || len(us.Permisos) == 0) {
+				t.Errorf("validateToken user = %+v, want user 7 of fondo 2 with the permissions of its rol", us)
+			}
+		})
+	}
+}
+
+func TestValidateTokenSignature(t *testing.T) {
+	h, _, _ := newMockAuth(t, false)
+	_, otra, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid, _, err := h.validateToken(sign(t, otra, jwt.MapClaims{"id": 7, "rol": 3, "email": "ana@x.co", "fondo": 2}))
+	if valid || err == nil {
+		t.Errorf("validateToken of a token signed by another key = %v, %v, want an error", valid, err)
+	}
+}
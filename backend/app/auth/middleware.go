@@ -6,63 +6,130 @@ import (
 	"net/http"
 
 	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
 )
 
-// MiddlewareTokenValidationRol3 validates resquests tokens
-func (h *Auth) MiddlewareTokenValidationRol3(next http.Handler) http.Handler {
-	h.l.Info("[MiddlewareTokenValidationRol3] Handling validator middleware request")
+// routePermissions are the permissions that allow calling each route, any of them is enough.
+// Routes that are not listed are denied
+var routePermissions = map[string][]string{
+	"GET /aportes/resumen":                      {data.PermAportesRead, data.PermReporteRead},
+	"GET /aportes":                              {data.PermAportesRead},
+	"POST /aportes":                             {data.PermAportesWrite},
+	"POST /aportes/{id:[0-9]+}/ajustes":         {data.PermAportesAdjust},
+	"GET /creditos":                             {data.PermCreditosRead},
+	"POST /creditos":                            {data.PermCreditosWrite},
+	"GET /creditos/proyeccion":                  {data.PermCreditosSimulate},
+	"POST /pago":                                {data.PermPagosWrite},
+	"POST /descuentos":                          {data.PermDescuentosWrite},
+	"POST /descuentos/capital":                  {data.PermDescuentosWrite},
+	"POST /descuentos/interes":                  {data.PermDescuentosWrite},
+	"GET /reporte":                              {data.PermReporteRead},
+	"GET /usuarios/{id:[0-9]+}/aportes":         {data.PermCuentaRead, data.PermAportesRead},
+	"GET /usuarios/{id:[0-9]+}/aportes/sum":     {data.PermCuentaRead, data.PermAportesRead},
+	"GET /usuarios/{id:[0-9]+}/aportes/estado":  {data.PermCuentaRead, data.PermAportesRead},
+	"GET /usuarios/{id:[0-9]+}/aportes/resumen": {data.PermCuentaRead, data.PermAportesRead},
+	"GET /usuarios/{id:[0-9]+}/planes":          {data.PermCuentaRead, data.PermAportesRead},
+	"PUT /usuarios/{id:[0-9]+}/plan":            {data.PermPlanesWrite},
+	"DELETE /usuarios/{id:[0-9]+}/plan":         {data.PermPlanesWrite},
+	"GET /usuarios/{id:[0-9]+}/multas":          {data.PermCuentaRead, data.PermMultasRead},
+	"GET /multas":                               {data.PermMultasRead},
+	"POST /multas/evaluar":                      {data.PermMultasEvaluate},
+	"POST /multas/{id:[0-9]+}/pagos":            {data.PermMultasWrite},
+	"DELETE /multas/{id:[0-9]+}":                {data.PermMultasWrite},
+	"GET /multas/reglas":                        {data.PermMultasRead},
+	"POST /multas/reglas":                       {data.PermMultasWrite},
+	"DELETE /multas/reglas/{id:[0-9]+}":         {data.PermMultasWrite},
+	"GET /morosidad":                            {data.PermAportesRead},
+	"GET /usuarios/{id:[0-9]+}/creditos":        {data.PermCuentaRead, data.PermCreditosRead},
+	"GET /usuarios/{id:[0-9]+}/liquidacion":     {data.PermLiquidacionesRead},
+	"POST /usuarios/{id:[0-9]+}/liquidacion":    {data.PermLiquidacionesWrite},
+	"GET /liquidaciones":                        {data.PermLiquidacionesRead},
+	"GET /importaciones":                        {data.PermImportacionesRead},
+	"POST /importaciones":                       {data.PermImportacionesWrite},
+	"POST /importaciones/preview":               {data.PermImportacionesWrite},
+}
+
+// MiddlewarePermission validates the request token, or API key, and checks that the user
+// has one of the permissions of the route
+func (h *Auth) MiddlewarePermission(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewarePermission] Handling validator middleware request")
 
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Content-Type", "application/json")
 
-		if r.Header["Authorization"] != nil {
-			tv, us, err := h.validateToken(r.Header["Authorization"][0], "3")
+		// service accounts send an API key instead of a token, every request made
+		// with the key is recorded with the status of the response
+		if key := r.Header.Get(apiKeyHeader); key != "" {
+			tv, us, err := h.validateAPIKey(key)
 			if err != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				h.l.Error("[MiddlewareTokenValidationRol3] Error parsing or validating request token", "error", err, "endpoint", r.URL)
+				w.WriteHeader(http.StatusInternalServerError)
+				h.l.Error("[MiddlewarePermission] Error validating API key", "error", err, "endpoint", r.URL)
 				data.ToJSON(&AuthError{Message: err.Error()}, w)
 
 				return
 			}
 
-			if tv {
-				context.Set(r, "us", us)
-				next.ServeHTTP(w, r)
-				return
+			sr := &statusRecorder{w, http.StatusOK}
+			h.authorize(sr, r, next, tv, us)
+
+			if us.APIKey != 0 {
+				h.recordAPIKeyUse(us, r, sr.status)
 			}
+			return
 		}
 
-		w.WriteHeader(http.StatusUnauthorized)
-		h.l.Info("[MiddlewareTokenValidationRol3] User request not autorized")
-		fmt.Fprintf(w, "User request not autorized")
-	})
-}
-
-// MiddlewareTokenValidationRol1 validates resquests tokens
-func (h *Auth) MiddlewareTokenValidationRol1(next http.Handler) http.Handler {
-	h.l.Info("[MiddlewareTokenValidationRol1] Handling validator middleware request")
-
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("Content-Type", "application/json")
-
+		tv := false
+		us := data.User{}
 		if r.Header["Authorization"] != nil {
-			tv, us, err := h.validateToken(r.Header["Authorization"][0], "1")
+			var err error
+			tv, us, err = h.validateToken(r.Header["Authorization"][0])
 			if err != nil {
 				w.WriteHeader(http.StatusBadRequest)
-				h.l.Error("[MiddlewareTokenValidationRol1] Error parsing or validating request token", "error", err, "endpoint", r.URL)
-
-				return
-			}
+				h.l.Error("[MiddlewarePermission] Error parsing or validating request token", "error", err, "endpoint", r.URL)
+				data.ToJSON(&AuthError{Message: err.Error()}, w)
 
-			if tv {
-				context.Set(r, "us", us)
-				next.ServeHTTP(w, r)
 				return
 			}
 		}
 
+		h.authorize(w, r, next, tv, us)
+	})
+}
+
+// authorize calls the next handler when the user is valid and has a permission of the route
+func (h *Auth) authorize(w http.ResponseWriter, r *http.Request, next http.Handler, valid bool, us data.User) {
+	if !valid {
 		w.WriteHeader(http.StatusUnauthorized)
-		h.l.Info("[MiddlewareTokenValidationRol1] User request not autorized")
+		h.l.Info("[authorize] User request not autorized")
 		fmt.Fprintf(w, "User request not autorized")
-	})
+		return
+	}
+
+	route := r.Method + " " + routeTemplate(r)
+	for _, perm := range routePermissions[route] {
+		if us.HasPermission(perm) {
+			context.Set(r, "us", us)
+			next.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	h.l.Info("[authorize] User without permission", "user", us.ID, "key", us.APIKey, "route", route)
+	w.WriteHeader(http.StatusForbidden)
+	data.ToJSON(&AuthError{Message: "User does not have permission"}, w)
+}
+
+// routeTemplate returns the path template of the matched route
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return ""
+	}
+
+	t, err := route.GetPathTemplate()
+	if err != nil {
+		return ""
+	}
+
+	return t
 }
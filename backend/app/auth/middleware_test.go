@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"fondo-mod/data"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestAuthorize(t *testing.T) {
+	p := data.DefaultPolicy()
+	h := &Auth{l: hclog.NewNullLogger(), p: p}
+
+	admin := data.User{ID: 1, Rol: 1, Fondo: 1, Permisos: p.Permissions(1)}
+	auditor := data.User{ID: 2, Rol: 2, Fondo: 1, Permisos: p.Permissions(2)}
+	miembro := data.User{ID: 3, Rol: 3, Fondo: 1, Permisos: p.Permissions(3)}
+	apiKey := data.User{Fondo: 1, APIKey: 7, Permisos: []string{data.PermAportesWrite}}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		valid  bool
+		us     data.User
+		status int
+	}{
+		{"invalid token", http.MethodGet, "/aportes", false, admin, http.StatusUnauthorized},
+		{"admin writes aportes", http.MethodPost, "/aportes", true, admin, http.StatusOK},
+		{"auditor reads aportes", http.MethodGet, "/aportes", true, auditor, http.StatusOK},
+		{"auditor can't write aportes", http.MethodPost, "/aportes", true, auditor, http.StatusForbidden},
+		{"member can't read every aporte", http.MethodGet, "/aportes", true, miembro, http.StatusForbidden},
+		{"member reads its account", http.MethodGet, "/usuarios/3/aportes", true, miembro, http.StatusOK},
+		{"any permission of the route is enough", http.MethodGet, "/aportes/resumen", true, miembro, http.StatusOK},
+		{"api key with the scope", http.MethodPost, "/aportes", true, apiKey, http.StatusOK},
+		{"api key without the scope", http.MethodGet, "/aportes", true, apiKey, http.StatusForbidden},
+		{"route that is not listed", http.MethodGet, "/sin-permisos", true, admin, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			sm := mux.NewRouter()
+			sm.Use(func(http.Handler) http.Handler {
+				return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					h.authorize(w, r, next, tt.valid, tt.us)
+				})
+			})
+			for route := range routePermissions {
+				parts := strings.SplitN(route, " ", 2)
+				sm.Methods(parts[0]).Path(parts[1]).Handler(next)
+			}
+			sm.Methods(http.MethodGet).Path("/sin-permisos").Handler(next)
+
+			rw := httptest.NewRecorder()
+			sm.ServeHTTP(rw, httptest.NewRequest(tt.method, tt.path, nil))
+			if rw.Code != tt.status {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rw.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestRoutePermissionsAreKnown(t *testing.T) {
+	admin := data.User{Permisos: data.DefaultPolicy().Permissions(1)}
+
+	for route, perms := range routePermissions {
+		if len(perms) == 0 {
+			t.Errorf("%s has no permissions", route)
+		}
+		for _, perm := range perms {
+			if !admin.HasPermission(perm) {
+				t.Errorf("%s needs the unknown permission %q", route, perm)
+			}
+		}
+	}
+}
+
+func TestMiddlewarePermissionAPIKey(t *testing.T) {
+	const key = "ffk_abcd_secreto"
+	sum := sha256.Sum256([]byte(key))
+	claveHash := hex.EncodeToString(sum[:])
+	columns := []string{"id", "idCuenta", "idFondo", "claveHash", "scopes", "expira", "revocada", "activa"}
+	scopes := data.PermAportesWrite + "," + data.PermCreditosRead
+
+	tests := []struct {
+		name   string
+		key    string
+		method string
+		path   string
+		row    []driver.Value
+		status int
+		// recorded is false when the key is not found, its uses can't be stored then
+		recorded bool
+	}{
+		{"scope of the route", key, http.MethodPost, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, nil, false, true}, http.StatusCreated, true},
+		{"without the scope of the route", key, http.MethodGet, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, nil, false, true}, http.StatusForbidden, true},
+		{"revoked key", key, http.MethodPost, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, nil, true, true}, http.StatusUnauthorized, true},
+		{"expired key", key, http.MethodPost, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, time.Now().Add(-time.Hour), false, true}, http.StatusUnauthorized, true},
+		{"inactive service account", key, http.MethodPost, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, nil, false, false}, http.StatusUnauthorized, true},
+		{"wrong secret", "ffk_abcd_otro", http.MethodPost, "/aportes", []driver.Value{3, 1, 2, claveHash, scopes, nil, false, true}, http.StatusUnauthorized, false},
+		{"unknown prefijo", key, http.MethodPost, "/aportes", nil, http.StatusUnauthorized, false},
+		{"not an API key", "Bearer abc", http.MethodPost, "/aportes", nil, http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock, _ := newMockAuth(t, false)
+			if strings.HasPrefix(tt.key, "ffk_") {
+				rows := sqlmock.NewRows(columns)
+				if tt.row != nil {
+					rows.AddRow(tt.row...)
+				}
+				mock.ExpectQuery("FROM api_keys k JOIN cuentas_servicio c ON c.id = k.idCuenta WHERE k.prefijo = \\?").WithArgs("abcd").WillReturnRows(rows)
+			}
+			if tt.recorded {
+				mock.ExpectExec("INSERT INTO api_keys_uso").WithArgs(3, tt.method, tt.path, tt.status, "192.0.2.1", sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(1, 1))
+				mock.ExpectExec("UPDATE api_keys SET ultimoUso = \\? WHERE id = \\?").WithArgs(sqlmock.AnyArg(), 3).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			}
+
+			// the handler runs as the fondo of the key with its scopes as permissions
+			var us data.User
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				us = context.Get(r, "us").(data.User)
+				w.WriteHeader(http.StatusCreated)
+			})
+			sm := mux.NewRouter()
+			sm.Use(h.MiddlewarePermission)
+			sm.Methods(http.MethodGet, http.MethodPost).Path("/aportes").Handler(next)
+
+			r := httptest.NewRequest(tt.method, tt.path, nil)
+			r.Header.Set(apiKeyHeader, tt.key)
+			rw := httptest.NewRecorder()
+			sm.ServeHTTP(rw, r)
+
+			if rw.Code != tt.status {
+				t.Errorf("%s %s = %d, want %d", tt.method, tt.path, rw.Code, tt.status)
+			}
+			if tt.status == http.StatusCreated && (us.ID != 0 || us.Fondo != 2 || us.APIKey != 3) {
+				t.Errorf("user of the key = %+v, want fondo 2 and key 3", us)
+			}
+		})
+	}
+}
@@ -1,7 +1,9 @@
 package data
 
 import (
+	"database/sql"
 	"fmt"
+	"time"
 )
 
 // ErrValorMayor is raised when a user is not found
@@ -18,7 +20,7 @@ type ReporteGeneral struct {
 
 // PostDescuento is the response when a discount is applied
 type PostDescuento struct {
-	ValorDescuento int    `json:"valorDescuento" validate:"required"`
+	ValorDescuento int    `json:"valorDescuento" validate:"required,min=1"`
 	IDUsuario      int    `json:"idUsuario" validate:"required"`
 	IDCredito      int    `json:"idCredito"`
 	Antes          Estado `json:"antes"`
@@ -31,17 +33,24 @@ type Estado struct {
 	Intereses int `json:"intereses"`
 }
 
-// GetReporteGeneral gives a general report of the status of the fondo
-func (u *UserService) GetReporteGeneral() (ReporteGeneral, error) {
-	u.l.Info("[GetReportegeneral] Getting reporte general")
+// interesesFondo are the earnings of the fondo: the intereses paid on creditos, the comisiones of the
+// liquidaciones minus the intereses paid on them to the members and the multas paid. It takes the
+// id of the fondo three times
+const interesesFondo = `(COALESCE((SELECT SUM(ci.valor) FROM creditos_intereses ci JOIN creditos c ON c.id = ci.idCredito WHERE c.idFondo = ?), 0) +
+	COALESCE((SELECT SUM(comision - intereses) FROM liquidaciones WHERE idFondo = ?), 0) +
+	COALESCE((SELECT SUM(mp.valor) FROM multas_pagos mp JOIN multas m ON m.id = mp.idMulta WHERE m.idFondo = ?), 0))`
+
+// GetReporteGeneral gives a general report of the status of the fondo, see interesesFondo
+func (u *UserService) GetReporteGeneral(idFondo int) (ReporteGeneral, error) {
+	u.l.Info("[GetReportegeneral] Getting reporte general", "fondo", idFondo)
 
 	reporte := ReporteGeneral{}
-	rows, err := u.DB.Query(`SELECT 
-	COALESCE((SELECT SUM(valor) as valor FROM aportes), 0) as capital,
-	COALESCE((SELECT SUM(valor) from creditos_intereses), 0) as intereses,
-	COALESCE((SELECT SUM(totalCapital) from creditos), 0) - COALESCE((SELECT SUM(valor) from creditos_cuotas), 0) as prestado,
-	COALESCE(((SELECT SUM(valor) as valor FROM aportes) + COALESCE((SELECT SUM(valor) from creditos_intereses), 0) + COALESCE((SELECT SUM(valor) from creditos_cuotas), 0) - (SELECT SUM(totalCapital) from creditos)), 0) as total,
-	COALESCE(((SELECT SUM(valor) as valor FROM aportes) - (SELECT SUM(totalCapital) from creditos)), 0) + COALESCE((SELECT SUM(valor) from creditos_cuotas), 0) as totalSinIntereses`)
+	rows, err := u.DB.Query(`SELECT capital, intereses, prestado - cuotas, capital + intereses + cuotas - prestado, capital - prestado + cuotas FROM (SELECT
+	COALESCE((SELECT SUM(`+aporteEfectivo+`) FROM aportes a WHERE a.idFondo = ?), 0) as capital,
+	`+interesesFondo+` as intereses,
+	COALESCE((SELECT SUM(totalCapital) FROM creditos WHERE idFondo = ?), 0) as prestado,
+	COALESCE((SELECT SUM(cc.valor) FROM creditos_cuotas cc JOIN creditos c ON c.id = cc.idCredito WHERE c.idFondo = ?), 0) as cuotas) as totales`,
+		idFondo, idFondo, idFondo, idFondo, idFondo, idFondo)
 	if err != nil {
 		return reporte, err
 	}
@@ -57,153 +66,119 @@ func (u *UserService) GetReporteGeneral() (ReporteGeneral, error) {
 }
 
 // PostDescontarParaCreditoCapital discounts money on aportes to pay it to a credit from a given user
-func (u *UserService) PostDescontarParaCreditoCapital(pDescuento *PostDescuento) (PostDescuento, error) {
-	_, err := u.CreditExists(pDescuento.IDCredito)
-	ap, err := u.GetSumAportesByID(pDescuento.IDUsuario)
-	if err != nil {
-		return PostDescuento{}, err
-	}
-	if ap.Valor >= int(pDescuento.ValorDescuento) {
-		aps, err := u.GetAllAportesByID(pDescuento.IDUsuario, "", "")
+func (u *UserService) PostDescontarParaCreditoCapital(idFondo int, pDescuento *PostDescuento, actor Actor) (PostDescuento, error) {
+	return u.descontar(idFondo, pDescuento, actor, "creditos_cuotas", fmt.Sprintf("Pago a capital del credito %d", pDescuento.IDCredito))
+}
+
+// PostDescontarParaCreditoIntereses discounts money on aportes to pay it to a credit from a given user
+func (u *UserService) PostDescontarParaCreditoIntereses(idFondo int, pDescuento *PostDescuento, actor Actor) (PostDescuento, error) {
+	return u.descontar(idFondo, pDescuento, actor, "creditos_intereses", fmt.Sprintf("Pago a intereses del credito %d", pDescuento.IDCredito))
+}
+
+// PostDescontar discounts money on aportes
+func (u *UserService) PostDescontar(idFondo int, pDescuento *PostDescuento, actor Actor) (PostDescuento, error) {
+	return u.descontar(idFondo, pDescuento, actor, "", "Descuento")
+}
 
+// descontar discounts the descuento from the aportes of the user and, when tabla is given,
+// pays it to the credito on that table of payments, both in the same transaction
+func (u *UserService) descontar(idFondo int, pDescuento *PostDescuento, actor Actor, tabla string, motivo string) (PostDescuento, error) {
+	u.l.Info("[descontar] Discounting from aportes", "fondo", idFondo, "user", pDescuento.IDUsuario, "valor", pDescuento.ValorDescuento, "credito", pDescuento.IDCredito, "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	if tabla != "" {
+		_, err := u.CreditExists(idFondo, pDescuento.IDCredito)
 		if err != nil {
 			return PostDescuento{}, err
 		}
+	}
+	_, err := u.ActiveUserExists(idFondo, pDescuento.IDUsuario)
+	if err != nil {
+		return PostDescuento{}, err
+	}
 
-		antes := Estado{Aportes: ap.Valor}
-		tmp := 0
-		for _, a := range aps {
-			if a.Valor != 0 {
-				var err error
-				if (tmp + a.Valor) > pDescuento.ValorDescuento {
-					sum := pDescuento.ValorDescuento - tmp
-					tmp += sum
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", a.Valor-sum, a.ID)
-
-				} else {
-					tmp += a.Valor
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", 0, a.ID)
-				}
-
-				if err != nil {
-					return PostDescuento{}, err
-				}
-
-				if tmp == pDescuento.ValorDescuento {
-					pago := Pago{ValorCapital: pDescuento.ValorDescuento, IDCredito: pDescuento.IDCredito}
-					ap, err := u.GetSumAportesByID(pDescuento.IDUsuario)
-					err = u.CreatePago(&pago)
-					despues := Estado{Aportes: ap.Valor}
-
-					if err != nil {
-						return PostDescuento{}, err
-					}
-
-					return PostDescuento{Antes: antes, Despues: despues, ValorDescuento: pDescuento.ValorDescuento}, nil
-				}
-			}
-		}
-
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return PostDescuento{}, err
 	}
-	return PostDescuento{}, ErrValorMayor
-}
+	defer tx.Rollback()
 
-// PostDescontarParaCreditoIntereses discounts money on aportes to pay it to a credit from a given user
-func (u *UserService) PostDescontarParaCreditoIntereses(pDescuento *PostDescuento) (PostDescuento, error) {
-	_, err := u.CreditExists(pDescuento.IDCredito)
-	ap, err := u.GetSumAportesByID(pDescuento.IDUsuario)
+	antes, err := descontarAportes(tx, idFondo, pDescuento.IDUsuario, pDescuento.ValorDescuento, AjusteDescuento, motivo, actor)
 	if err != nil {
 		return PostDescuento{}, err
 	}
-	if ap.Valor >= int(pDescuento.ValorDescuento) {
-		aps, err := u.GetAllAportesByID(pDescuento.IDUsuario, "", "")
 
+	if tabla != "" {
+		_, err = tx.Exec("INSERT INTO "+tabla+" (valor, idCredito, fecha) VALUES (?, ?, ?)", pDescuento.ValorDescuento, pDescuento.IDCredito, time.Now())
 		if err != nil {
 			return PostDescuento{}, err
 		}
+	}
 
-		antes := Estado{Aportes: ap.Valor}
-		tmp := 0
-		for _, a := range aps {
-			if a.Valor != 0 {
-				var err error
-				if (tmp + a.Valor) > pDescuento.ValorDescuento {
-					sum := pDescuento.ValorDescuento - tmp
-					tmp += sum
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", a.Valor-sum, a.ID)
-
-				} else {
-					tmp += a.Valor
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", 0, a.ID)
-				}
-
-				if err != nil {
-					return PostDescuento{}, err
-				}
-
-				if tmp == pDescuento.ValorDescuento {
-					pago := Pago{ValorIntrereses: pDescuento.ValorDescuento, IDCredito: pDescuento.IDCredito}
-					ap, err := u.GetSumAportesByID(pDescuento.IDUsuario)
-					err = u.CreatePagoInteres(&pago)
-					despues := Estado{Aportes: ap.Valor}
-
-					if err != nil {
-						return PostDescuento{}, err
-					}
-
-					return PostDescuento{Antes: antes, Despues: despues, ValorDescuento: pDescuento.ValorDescuento}, nil
-				}
-			}
-		}
-
+	err = tx.Commit()
+	if err != nil {
+		return PostDescuento{}, err
 	}
-	return PostDescuento{}, ErrValorMayor
+
+	return PostDescuento{
+		ValorDescuento: pDescuento.ValorDescuento,
+		IDUsuario:      pDescuento.IDUsuario,
+		IDCredito:      pDescuento.IDCredito,
+		Antes:          Estado{Aportes: antes},
+		Despues:        Estado{Aportes: antes - pDescuento.ValorDescuento},
+	}, nil
 }
 
-// PostDescontar discounts money on aportes
-func (u *UserService) PostDescontar(pDescuento *PostDescuento) (PostDescuento, error) {
-	ap, err := u.GetSumAportesByID(pDescuento.IDUsuario)
+// descontarAportes discounts valor from the aportes of an user, oldest first, with ajustes of the
+// given tipo and returns the aportes the user had before. The aportes stay locked until the transaction ends
+func descontarAportes(tx *sql.Tx, idFondo int, idUsuario int, valor int, tipo string, motivo string, actor Actor) (int, error) {
+	rows, err := tx.Query("SELECT a.id, "+aporteEfectivo+" FROM aportes a WHERE a.idFondo = ? AND a.idUsuario = ? ORDER BY a.fecha, a.id FOR UPDATE", idFondo, idUsuario)
 	if err != nil {
-		return PostDescuento{}, err
+		return 0, err
 	}
-	if ap.Valor >= int(pDescuento.ValorDescuento) {
-		aps, err := u.GetAllAportesByID(pDescuento.IDUsuario, "", "")
 
+	aportes := Aportes{}
+	total := 0
+	for rows.Next() {
+		a := &Aporte{}
+		err = rows.Scan(&a.ID, &a.Valor)
 		if err != nil {
-			return PostDescuento{}, err
+			rows.Close()
+			return 0, err
 		}
 
-		antes := Estado{Aportes: ap.Valor}
-		tmp := 0
-		for _, a := range aps {
-			if a.Valor != 0 {
-				var err error
-				if (tmp + a.Valor) > pDescuento.ValorDescuento {
-					sum := pDescuento.ValorDescuento - tmp
-					tmp += sum
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", a.Valor-sum, a.ID)
-
-				} else {
-					tmp += a.Valor
-					_, err = u.DB.Query("UPDATE aportes SET valor = ? WHERE id = ?", 0, a.ID)
-				}
-
-				if err != nil {
-					return PostDescuento{}, err
-				}
-
-				if tmp == pDescuento.ValorDescuento {
-					despues := Estado{Aportes: ap.Valor}
-
-					if err != nil {
-						return PostDescuento{}, err
-					}
-
-					return PostDescuento{Antes: antes, Despues: despues, ValorDescuento: pDescuento.ValorDescuento}, nil
-				}
-			}
+		total += a.Valor
+		aportes = append(aportes, a)
+	}
+	// the rows must be closed before the ajustes are stored on the same transaction
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if total < valor {
+		return total, ErrValorMayor
+	}
+
+	fecha := time.Now()
+	restante := valor
+	for _, a := range aportes {
+		if restante == 0 {
+			break
+		}
+		if a.Valor <= 0 {
+			continue
 		}
 
+		d := a.Valor
+		if d > restante {
+			d = restante
+		}
+		err = insertAjuste(tx, a.ID, -d, tipo, motivo, actor, fecha)
+		if err != nil {
+			return 0, err
+		}
+		restante -= d
 	}
-	return PostDescuento{}, ErrValorMayor
+
+	return total, nil
 }
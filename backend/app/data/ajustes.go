@@ -0,0 +1,166 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ErrAporteNotFound is raised when an aporte can not be found in the fondo
+var ErrAporteNotFound = fmt.Errorf("Aporte not found")
+
+// ErrAjusteSinValor is raised when a correccion does not change the aporte
+var ErrAjusteSinValor = fmt.Errorf("A correccion needs a valor different from zero")
+
+// ErrAjusteNegativo is raised when an ajuste leaves the aporte below zero
+var ErrAjusteNegativo = fmt.Errorf("The ajuste leaves the aporte below zero")
+
+// ErrAporteRevertido is raised when the aporte has no value left to revert
+var ErrAporteRevertido = fmt.Errorf("The aporte has no value left to revert")
+
+// Tipos of the ajustes of an aporte
+const (
+	// AjusteCorreccion adds or takes a value from a mistyped aporte
+	AjusteCorreccion = "correccion"
+	// AjusteReversion takes all the remaining value of an aporte
+	AjusteReversion = "reversion"
+	// AjusteDescuento takes money from the aportes, it may be paid to a credito
+	AjusteDescuento = "descuento"
+	// AjusteLiquidacion takes the aportes of a member that leaves the fondo
+	AjusteLiquidacion = "liquidacion"
+)
+
+// aporteEfectivo is the value of the aporte aliased a after its ajustes
+const aporteEfectivo = "a.valor + COALESCE((SELECT SUM(aj.valor) FROM aportes_ajustes aj WHERE aj.idAporte = a.id), 0)"
+
+// AjusteAporte is a change to an aporte, aportes are never updated so the original value is kept
+type AjusteAporte struct {
+	ID       int       `json:"id"`
+	IDAporte int       `json:"idAporte"`
+	Valor    int       `json:"valor"`
+	Tipo     string    `json:"tipo"`
+	Motivo   string    `json:"motivo"`
+	Fecha    time.Time `json:"fecha"`
+	Actor
+}
+
+// AjustesAporte is a list of AjusteAporte
+type AjustesAporte []*AjusteAporte
+
+// AjusteCreate is the body sent to correct or revert an aporte, valor is added to the aporte
+// on a correccion and ignored on a reversion, which takes all the value left
+type AjusteCreate struct {
+	Tipo   string `json:"tipo" validate:"required,oneof=correccion reversion"`
+	Valor  int    `json:"valor"`
+	Motivo string `json:"motivo" validate:"required,max=255"`
+}
+
+// CreateAjusteAporte corrects or reverts an aporte of an active member of the fondo
+func (u *UserService) CreateAjusteAporte(idFondo int, idAporte int, a *AjusteCreate, actor Actor) (Aporte, error) {
+	u.l.Info("[CreateAjusteAporte] Adjusting aporte", "fondo", idFondo, "aporte", idAporte, "tipo", a.Tipo, "valor", a.Valor, "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return Aporte{}, err
+	}
+	defer tx.Rollback()
+
+	ap := Aporte{ID: idAporte}
+	err = tx.QueryRow("SELECT a.idUsuario, a.valor, "+aporteEfectivo+" FROM aportes a WHERE a.id = ? AND a.idFondo = ? FOR UPDATE",
+		idAporte, idFondo).Scan(&ap.IDUsuario, &ap.Original, &ap.Valor)
+	if err == sql.ErrNoRows {
+		return ap, ErrAporteNotFound
+	}
+	if err != nil {
+		return ap, err
+	}
+
+	var activo bool
+	err = tx.QueryRow("SELECT activo FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ?", idFondo, ap.IDUsuario).Scan(&activo)
+	if err != nil {
+		return ap, err
+	}
+	if !activo {
+		return ap, ErrMiembroInactivo
+	}
+
+	valor := a.Valor
+	switch a.Tipo {
+	case AjusteReversion:
+		if ap.Valor == 0 {
+			return ap, ErrAporteRevertido
+		}
+		valor = -ap.Valor
+	case AjusteCorreccion:
+		if valor == 0 {
+			return ap, ErrAjusteSinValor
+		}
+		if ap.Valor+valor < 0 {
+			return ap, ErrAjusteNegativo
+		}
+	}
+
+	err = insertAjuste(tx, idAporte, valor, a.Tipo, a.Motivo, actor, time.Now())
+	if err != nil {
+		return ap, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return ap, err
+	}
+
+	return u.getAporte(idFondo, idAporte)
+}
+
+// getAporte returns an aporte of the fondo with its ajustes
+func (u *UserService) getAporte(idFondo int, id int) (Aporte, error) {
+	ap := Aporte{ID: id}
+	err := u.DB.QueryRow("SELECT a.idUsuario, a.fecha, a.valor, "+aporteEfectivo+" FROM aportes a WHERE a.id = ? AND a.idFondo = ?",
+		id, idFondo).Scan(&ap.IDUsuario, &ap.Fecha, &ap.Original, &ap.Valor)
+	if err == sql.ErrNoRows {
+		return ap, ErrAporteNotFound
+	}
+	if err != nil {
+		return ap, err
+	}
+	ap.Ajuste = ap.Valor - ap.Original
+
+	ap.Ajustes, err = u.getAjustes("a.id = ?", id)
+
+	return ap, err
+}
+
+// getAjustes returns the ajustes of the aportes a that match the condition, oldest first
+func (u *UserService) getAjustes(where string, args ...interface{}) (AjustesAporte, error) {
+	ajustes := AjustesAporte{}
+	rows, err := u.DB.Query(`SELECT aj.id, aj.idAporte, aj.valor, aj.tipo, aj.motivo, aj.idActor, aj.idAPIKey, aj.fecha
+		FROM aportes_ajustes aj JOIN aportes a ON a.id = aj.idAporte WHERE `+where+` ORDER BY aj.fecha, aj.id`, args...)
+	if err != nil {
+		return ajustes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		aj := &AjusteAporte{}
+		var actor, key sql.NullInt64
+		err = rows.Scan(&aj.ID, &aj.IDAporte, &aj.Valor, &aj.Tipo, &aj.Motivo, &actor, &key, &aj.Fecha)
+		if err != nil {
+			return ajustes, err
+		}
+		aj.Actor = scanActor(actor, key)
+
+		ajustes = append(ajustes, aj)
+	}
+
+	return ajustes, rows.Err()
+}
+
+// insertAjuste stores an ajuste of an aporte made by the actor
+func insertAjuste(tx *sql.Tx, idAporte int, valor int, tipo string, motivo string, actor Actor, fecha time.Time) error {
+	idActor, idAPIKey := actor.values()
+	_, err := tx.Exec("INSERT INTO aportes_ajustes (idAporte, valor, tipo, motivo, idActor, idAPIKey, fecha) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		idAporte, valor, tipo, motivo, idActor, idAPIKey, fecha)
+
+	return err
+}
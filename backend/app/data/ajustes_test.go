@@ -0,0 +1,119 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateAjusteAporte(t *testing.T) {
+	tests := []struct {
+		name     string
+		ajuste   AjusteCreate
+		efectivo int
+		activo   bool
+		valor    int
+		err      error
+	}{
+		{"correccion down", AjusteCreate{Tipo: AjusteCorreccion, Valor: -30, Motivo: "Mal digitado"}, 100, true, -30, nil},
+		{"correccion up", AjusteCreate{Tipo: AjusteCorreccion, Valor: 20, Motivo: "Mal digitado"}, 100, true, 20, nil},
+		{"reversion of what is left", AjusteCreate{Tipo: AjusteReversion, Valor: 5, Motivo: "Duplicado"}, 70, true, -70, nil},
+		{"correccion without valor", AjusteCreate{Tipo: AjusteCorreccion, Motivo: "Nada"}, 100, true, 0, ErrAjusteSinValor},
+		{"correccion below zero", AjusteCreate{Tipo: AjusteCorreccion, Valor: -101, Motivo: "Mal digitado"}, 100, true, 0, ErrAjusteNegativo},
+		{"reversion of a reverted aporte", AjusteCreate{Tipo: AjusteReversion, Motivo: "Duplicado"}, 0, true, 0, ErrAporteRevertido},
+		{"aporte of an inactive member", AjusteCreate{Tipo: AjusteCorreccion, Valor: -30, Motivo: "Mal digitado"}, 100, false, 0, ErrMiembroInactivo},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM aportes a WHERE a.id = \\? AND a.idFondo = \\? FOR UPDATE").WithArgs(5, 2).
+				WillReturnRows(sqlmock.NewRows([]string{"idUsuario", "valor", "efectivo"}).AddRow(7, 100, tt.efectivo))
+			mock.ExpectQuery("SELECT activo FROM fondo_usuario WHERE idFondo = \\? AND idUsuario = \\?").WithArgs(2, 7).
+				WillReturnRows(sqlmock.NewRows([]string{"activo"}).AddRow(tt.activo))
+			if tt.err != nil {
+				mock.ExpectRollback()
+			} else {
+				mock.ExpectExec("INSERT INTO aportes_ajustes").
+					WithArgs(5, tt.valor, tt.ajuste.Tipo, tt.ajuste.Motivo, 1, nil, sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(9, 1))
+				mock.ExpectCommit()
+				mock.ExpectQuery("FROM aportes a WHERE a.id = \\? AND a.idFondo = \\?$").WithArgs(5, 2).
+					WillReturnRows(sqlmock.NewRows([]string{"idUsuario", "fecha", "valor", "efectivo"}).AddRow(7, time.Now(), 100, tt.efectivo+tt.valor))
+				mock.ExpectQuery("FROM aportes_ajustes aj JOIN aportes a ON a.id = aj.idAporte WHERE a.id = \\?").WithArgs(5).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "idAporte", "valor", "tipo", "motivo", "idActor", "idAPIKey", "fecha"}).
+						AddRow(9, 5, tt.valor, tt.ajuste.Tipo, tt.ajuste.Motivo, 1, nil, time.Now()))
+			}
+
+			ap, err := s.CreateAjusteAporte(2, 5, &tt.ajuste, Actor{IDActor: 1})
+			if err != tt.err {
+				t.Fatalf("CreateAjusteAporte error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			// the original valor is kept and the ajustes are added to it
+			if ap.Original != 100 || ap.Valor != tt.efectivo+tt.valor || ap.Ajuste != ap.Valor-100 || len(ap.Ajustes) != 1 || ap.Ajustes[0].IDActor != 1 {
+				t.Errorf("CreateAjusteAporte = original %d, valor %d, ajuste %d with %d ajustes, want 100, %d, %d with 1",
+					ap.Original, ap.Valor, ap.Ajuste, len(ap.Ajustes), tt.efectivo+tt.valor, tt.efectivo+tt.valor-100)
+			}
+		})
+	}
+}
+
+func TestCreateAjusteAporteOfOtherFondo(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM aportes a WHERE a.id = \\? AND a.idFondo = \\? FOR UPDATE").WithArgs(5, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario", "valor", "efectivo"}))
+	mock.ExpectRollback()
+
+	_, err := s.CreateAjusteAporte(2, 5, &AjusteCreate{Tipo: AjusteReversion, Motivo: "Duplicado"}, Actor{IDActor: 1})
+	if err != ErrAporteNotFound {
+		t.Errorf("CreateAjusteAporte error = %v, want %v", err, ErrAporteNotFound)
+	}
+}
+
+func TestDescontarAportes(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM aportes a WHERE a.idFondo = \\? AND a.idUsuario = \\? ORDER BY a.fecha, a.id FOR UPDATE").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "valor"}).AddRow(1, 50).AddRow(2, 0).AddRow(3, 100).AddRow(4, 100))
+	// the oldest aportes are discounted first, skipping the reverted ones
+	mock.ExpectExec("INSERT INTO aportes_ajustes").WithArgs(1, -50, AjusteDescuento, "Descuento", 1, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO aportes_ajustes").WithArgs(3, -70, AjusteDescuento, "Descuento", 1, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectRollback()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	total, err := descontarAportes(tx, 2, 7, 120, AjusteDescuento, "Descuento", Actor{IDActor: 1})
+	if err != nil || total != 250 {
+		t.Errorf("descontarAportes = %d, %v, want 250", total, err)
+	}
+}
+
+func TestDescontarAportesValorMayor(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM aportes a WHERE a.idFondo = \\? AND a.idUsuario = \\?").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "valor"}).AddRow(1, 50))
+	mock.ExpectRollback()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := descontarAportes(tx, 2, 7, 51, AjusteDescuento, "Descuento", Actor{IDActor: 1}); err != ErrValorMayor {
+		t.Errorf("descontarAportes error = %v, want %v", err, ErrValorMayor)
+	}
+}
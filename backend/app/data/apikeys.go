@@ -0,0 +1,67 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAPIKeyNotFound is raised when an API key can not be found
+var ErrAPIKeyNotFound = fmt.Errorf("API key not found")
+
+// APIKey describes an API key of a service account created on the authentication api
+type APIKey struct {
+	ID           int
+	IDCuenta     int
+	IDFondo      int
+	ClaveHash    string
+	Scopes       []string
+	Expira       sql.NullTime
+	Revocada     bool
+	CuentaActiva bool
+}
+
+// Valid returns true if the key was not revoked, did not expire and its service account is active
+func (k APIKey) Valid(now time.Time) bool {
+	if k.Revocada || !k.CuentaActiva {
+		return false
+	}
+
+	return !k.Expira.Valid || now.Before(k.Expira.Time)
+}
+
+// GetAPIKey returns the API key with the given prefijo and the fondo of its service account
+func (u *UserService) GetAPIKey(prefijo string) (APIKey, error) {
+	k := APIKey{}
+	rows, err := u.DB.Query(`SELECT k.id, k.idCuenta, c.idFondo, k.claveHash, k.scopes, k.expira, k.revocada, c.activa
+		FROM api_keys k JOIN cuentas_servicio c ON c.id = k.idCuenta WHERE k.prefijo = ?`, prefijo)
+	if err != nil {
+		return k, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var scopes string
+		err = rows.Scan(&k.ID, &k.IDCuenta, &k.IDFondo, &k.ClaveHash, &scopes, &k.Expira, &k.Revocada, &k.CuentaActiva)
+		k.Scopes = strings.Split(scopes, ",")
+
+		return k, err
+	}
+
+	return k, ErrAPIKeyNotFound
+}
+
+// RecordAPIKeyUse stores a request made with an API key and updates its last use
+func (u *UserService) RecordAPIKeyUse(idKey int, metodo string, ruta string, estado int, ip string) error {
+	now := time.Now()
+	_, err := u.DB.Exec("INSERT INTO api_keys_uso (idKey, metodo, ruta, estado, ip, fecha) VALUES (?, ?, ?, ?, ?, ?)",
+		idKey, metodo, ruta, estado, ip, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = u.DB.Exec("UPDATE api_keys SET ultimoUso = ? WHERE id = ?", now, idKey)
+
+	return err
+}
@@ -2,12 +2,15 @@ package data
 
 import "database/sql"
 
-// Aporte describes
+// Aporte describes an aporte, Valor is its effective value: the Original value plus the Ajuste of its Ajustes
 type Aporte struct {
-	Valor     int    `json:"valor" validate:"required"`
-	Fecha     string `json:"fecha" validate:"required"`
-	IDUsuario int    `json:"idUsuario" validate:"required"`
-	ID        int    `json:"id"`
+	Valor     int           `json:"valor" validate:"required"`
+	Fecha     string        `json:"fecha" validate:"required"`
+	IDUsuario int           `json:"idUsuario" validate:"required"`
+	ID        int           `json:"id"`
+	Original  int           `json:"original"`
+	Ajuste    int           `json:"ajuste"`
+	Ajustes   AjustesAporte `json:"ajustes,omitempty"`
 }
 
 // SumAportes describes the sum of various aportes
@@ -18,37 +21,39 @@ type SumAportes struct {
 // Aportes array of aportes
 type Aportes []*Aporte
 
-// CreateAporte makes
-func (u *UserService) CreateAporte(id int, ap *Aporte) error {
-	u.l.Info("[CreateAporte] Creating aporte", "aporte", ap)
-	_, err := u.UserExists(id)
+// CreateAporte makes an aporte of an user of the fondo, late aportes get the multas of the fondo
+func (u *UserService) CreateAporte(idFondo int, id int, ap *Aporte) error {
+	u.l.Info("[CreateAporte] Creating aporte", "fondo", idFondo, "aporte", ap)
+	_, err := u.ActiveUserExists(idFondo, id)
 
 	if err != nil {
 		return err
 	}
-	_, err = u.DB.Exec("INSERT INTO aportes (valor, idUsuario, fecha) VALUES ( ?, ?, ?)", ap.Valor, id, ap.Fecha)
+	_, err = u.DB.Exec("INSERT INTO aportes (valor, idUsuario, fecha, idFondo) VALUES ( ?, ?, ?, ?)", ap.Valor, id, ap.Fecha, idFondo)
 	if err == nil {
+		u.evaluarMultasAporte(idFondo, id)
 		return nil
 	}
 	return err
 }
 
 // GetAllAportes gives all the aportes in the Fondo
-func (u *UserService) GetAllAportes() (Aportes, error) {
-	u.l.Info("[GetAllAportes] Getting all aportes from database")
+func (u *UserService) GetAllAportes(idFondo int) (Aportes, error) {
+	u.l.Info("[GetAllAportes] Getting all aportes from database", "fondo", idFondo)
 
 	aportes := Aportes{}
-	rows, err := u.DB.Query("SELECT valor, idUsuario, fecha FROM aportes")
+	rows, err := u.DB.Query("SELECT a.id, a.valor, "+aporteEfectivo+", a.idUsuario, a.fecha FROM aportes a WHERE a.idFondo = ?", idFondo)
 	if err != nil {
 		return aportes, err
 	}
 
 	for rows.Next() {
 		aporte := &Aporte{}
-		err = rows.Scan(&aporte.Valor, &aporte.IDUsuario, &aporte.Fecha)
+		err = rows.Scan(&aporte.ID, &aporte.Original, &aporte.Valor, &aporte.IDUsuario, &aporte.Fecha)
 		if err != nil {
 			return aportes, err
 		}
+		aporte.Ajuste = aporte.Valor - aporte.Original
 
 		aportes = append(aportes, aporte)
 	}
@@ -56,23 +61,24 @@ func (u *UserService) GetAllAportes() (Aportes, error) {
 	return aportes, nil
 }
 
-// GetAllAportesByID gives all the aportes in the Fondo for a specific user
-func (u *UserService) GetAllAportesByID(id int, startDate string, endDate string) (Aportes, error) {
-	u.l.Info("[GetAllAportesByID] Getting aportes from id", "userID", id)
+// GetAllAportesByID gives all the aportes in the Fondo for a specific user with their ajustes
+func (u *UserService) GetAllAportesByID(idFondo int, id int, startDate string, endDate string) (Aportes, error) {
+	u.l.Info("[GetAllAportesByID] Getting aportes from id", "fondo", idFondo, "userID", id)
 	aportes := Aportes{}
 	var (
 		rows *sql.Rows
 		err  error
 	)
 
+	query := "SELECT a.valor, " + aporteEfectivo + ", a.idUsuario, a.fecha, a.id FROM aportes a where a.idFondo = ? AND a.idUsuario = ?"
 	if startDate != "" && endDate != "" {
-		rows, err = u.DB.Query("SELECT valor, idUsuario, fecha, id FROM aportes where idUsuario = ? AND fecha BETWEEN ? AND ? ", id, startDate, endDate)
+		rows, err = u.DB.Query(query+" AND a.fecha BETWEEN ? AND ? ", idFondo, id, startDate, endDate)
 	} else if startDate != "" {
-		rows, err = u.DB.Query("SELECT valor, idUsuario, fecha, id FROM aportes where idUsuario = ? AND fecha >= ?", id, startDate)
+		rows, err = u.DB.Query(query+" AND a.fecha >= ?", idFondo, id, startDate)
 	} else if endDate != "" {
-		rows, err = u.DB.Query("SELECT valor, idUsuario, fecha, id FROM aportes where idUsuario = ? AND fecha <= ?", id, endDate)
+		rows, err = u.DB.Query(query+" AND a.fecha <= ?", idFondo, id, endDate)
 	} else {
-		rows, err = u.DB.Query("SELECT valor, idUsuario, fecha, id FROM aportes where idUsuario = ?", id)
+		rows, err = u.DB.Query(query, idFondo, id)
 	}
 
 	if err != nil {
@@ -81,29 +87,46 @@ func (u *UserService) GetAllAportesByID(id int, startDate string, endDate string
 
 	for rows.Next() {
 		aporte := &Aporte{}
-		err = rows.Scan(&aporte.Valor, &aporte.IDUsuario, &aporte.Fecha, &aporte.ID)
+		err = rows.Scan(&aporte.Original, &aporte.Valor, &aporte.IDUsuario, &aporte.Fecha, &aporte.ID)
 		if err != nil {
 			return aportes, err
 		}
+		aporte.Ajuste = aporte.Valor - aporte.Original
 
 		aportes = append(aportes, aporte)
 	}
+	rows.Close()
+
+	ajustes, err := u.getAjustes("a.idFondo = ? AND a.idUsuario = ?", idFondo, id)
+	if err != nil {
+		return aportes, err
+	}
+
+	byID := map[int]*Aporte{}
+	for _, a := range aportes {
+		byID[a.ID] = a
+	}
+	for _, aj := range ajustes {
+		if a, ok := byID[aj.IDAporte]; ok {
+			a.Ajustes = append(a.Ajustes, aj)
+		}
+	}
 
 	return aportes, nil
 }
 
-// GetSumAportesByID gives the sum of aportes of an specific user
-func (u *UserService) GetSumAportesByID(id int) (SumAportes, error) {
-	u.l.Info("[GetSumAportesByID] Getting aportes from id", "userID", id)
+// GetSumAportesByID gives the sum of aportes of an specific user in the fondo
+func (u *UserService) GetSumAportesByID(idFondo int, id int) (SumAportes, error) {
+	u.l.Info("[GetSumAportesByID] Getting aportes from id", "fondo", idFondo, "userID", id)
 
 	sumAporte := SumAportes{}
-	_, err := u.UserExists(id)
+	_, err := u.UserExists(idFondo, id)
 
 	if err != nil {
 		return sumAporte, err
 	}
 
-	rows, err := u.DB.Query("SELECT COALESCE(SUM(valor), 0) FROM aportes where idUsuario = ?", id)
+	rows, err := u.DB.Query("SELECT COALESCE(SUM("+aporteEfectivo+"), 0) FROM aportes a where a.idFondo = ? AND a.idUsuario = ?", idFondo, id)
 	if err != nil {
 		return sumAporte, err
 	}
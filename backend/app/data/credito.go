@@ -62,10 +62,10 @@ type Cuotas []*Cuota
 // Creditos array of credito
 type Creditos []*Credito
 
-// CreateCredito makes
-func (u *UserService) CreateCredito(id int, cr *Credito) error {
-	u.l.Info("[CreateCredito] Creating credito", "credito", cr)
-	_, err := u.UserExists(id)
+// CreateCredito makes a credito to an user of the fondo
+func (u *UserService) CreateCredito(idFondo int, id int, cr *Credito) error {
+	u.l.Info("[CreateCredito] Creating credito", "fondo", idFondo, "credito", cr)
+	_, err := u.ActiveUserExists(idFondo, id)
 
 	if err != nil {
 		return err
@@ -87,8 +87,8 @@ func (u *UserService) CreateCredito(id int, cr *Credito) error {
 	}
 
 	valorTotal := valorIntereses + float64(cr.TotalCapital)
-	_, err = u.DB.Exec("INSERT INTO creditos (fechaInicio, descripcion, valorCuota, tiempo, idUsuario, totalIntereses, porcentajeInteres, totalCapital, valorTotalCredito, activo, visible) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		cr.FechaInicio, cr.Descripcion, valorCuota, cr.Tiempo, cr.IDUsuario, valorIntereses, cr.PorcentajeIntereses, cr.TotalCapital, valorTotal, true, true)
+	_, err = u.DB.Exec("INSERT INTO creditos (fechaInicio, descripcion, valorCuota, tiempo, idUsuario, totalIntereses, porcentajeInteres, totalCapital, valorTotalCredito, activo, visible, idFondo) VALUES ( ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		cr.FechaInicio, cr.Descripcion, valorCuota, cr.Tiempo, cr.IDUsuario, valorIntereses, cr.PorcentajeIntereses, cr.TotalCapital, valorTotal, true, true, idFondo)
 	if err == nil {
 		return nil
 	}
@@ -116,10 +116,10 @@ func (u *UserService) CalcularCredito(cr *Credito) Cuotas {
 	return calcularCuotas(totalCapital, cr.Tiempo, porcentajeInteres, valorCuota, 0, cuotas, cr.FechaInicio)
 }
 
-// CreatePago creates a payment in the database
-func (u *UserService) CreatePago(p *Pago) error {
-	u.l.Info("[CreatePago] Creating pago from credit", "aporte", p)
-	_, err := u.CreditExists(p.IDCredito)
+// CreatePago creates a payment to a credito of the fondo in the database
+func (u *UserService) CreatePago(idFondo int, p *Pago) error {
+	u.l.Info("[CreatePago] Creating pago from credit", "fondo", idFondo, "aporte", p)
+	_, err := u.CreditExists(idFondo, p.IDCredito)
 
 	if err != nil {
 		return err
@@ -131,10 +131,10 @@ func (u *UserService) CreatePago(p *Pago) error {
 	return err
 }
 
-// CreatePagoInteres creates a interes payment in the database
-func (u *UserService) CreatePagoInteres(p *Pago) error {
-	u.l.Info("[CreatePagoInteres] Creating pago from credit", "aporte", p)
-	_, err := u.CreditExists(p.IDCredito)
+// CreatePagoInteres creates a interes payment to a credito of the fondo in the database
+func (u *UserService) CreatePagoInteres(idFondo int, p *Pago) error {
+	u.l.Info("[CreatePagoInteres] Creating pago from credit", "fondo", idFondo, "aporte", p)
+	_, err := u.CreditExists(idFondo, p.IDCredito)
 
 	if err != nil {
 		return err
@@ -151,8 +151,8 @@ func (u *UserService) darTotalPrestado(cr *Credito) Informe {
 }
 
 // GetAllCreditos gives all the aportes in the Fondo
-func (u *UserService) GetAllCreditos() (Creditos, error) {
-	u.l.Info("[GetAllCreditos] Getting all creditos from database")
+func (u *UserService) GetAllCreditos(idFondo int) (Creditos, error) {
+	u.l.Info("[GetAllCreditos] Getting all creditos from database", "fondo", idFondo)
 
 	creditos := Creditos{}
 	rows, err := u.DB.Query(`SELECT fechaInicio, descripcion, valorCuota, tiempo, id, idUsuario, totalIntereses, porcentajeInteres, totalCapital, valorTotalCredito,
@@ -166,7 +166,8 @@ func (u *UserService) GetAllCreditos() (Creditos, error) {
 				FROM creditos 
 				LEFT JOIN (SELECT idCredito, sum(valor) as valor FROM creditos_intereses GROUP BY idCredito) as interes ON creditos.id = interes.idCredito
 				LEFT JOIN (SELECT idCredito, sum(valor) as valor FROM creditos_cuotas GROUP BY idCredito) as pagos ON creditos.id = pagos.idCredito
-				GROUP BY creditos.id`)
+				WHERE idFondo = ?
+				GROUP BY creditos.id`, idFondo)
 	if err != nil {
 		return creditos, err
 	}
@@ -185,8 +186,8 @@ func (u *UserService) GetAllCreditos() (Creditos, error) {
 }
 
 // GetAllCreditosByUserID gives all the creditos in the Fondo given an user id
-func (u *UserService) GetAllCreditosByUserID(id int) (Creditos, error) {
-	u.l.Info("[GetAllCreditos] Getting all creditos from database from user", "user", id)
+func (u *UserService) GetAllCreditosByUserID(idFondo int, id int) (Creditos, error) {
+	u.l.Info("[GetAllCreditos] Getting all creditos from database from user", "fondo", idFondo, "user", id)
 
 	creditos := Creditos{}
 	rows, err := u.DB.Query(`SELECT fechaInicio, descripcion, valorCuota, tiempo, id, idUsuario, totalIntereses, porcentajeInteres, totalCapital, valorTotalCredito,
@@ -200,8 +201,8 @@ func (u *UserService) GetAllCreditosByUserID(id int) (Creditos, error) {
 				FROM creditos 
 				LEFT JOIN (SELECT idCredito, sum(valor) as valor FROM creditos_intereses GROUP BY idCredito) as interes ON creditos.id = interes.idCredito
 				LEFT JOIN (SELECT idCredito, sum(valor) as valor FROM creditos_cuotas GROUP BY idCredito) as pagos ON creditos.id = pagos.idCredito
-				WHERE idUsuario = ?
-				GROUP BY creditos.id`, id)
+				WHERE idFondo = ? AND idUsuario = ?
+				GROUP BY creditos.id`, idFondo, id)
 	if err != nil {
 		return creditos, err
 	}
@@ -219,25 +220,24 @@ func (u *UserService) GetAllCreditosByUserID(id int) (Creditos, error) {
 	return creditos, nil
 }
 
-// GetCreditoByID returns a credit given an id
-func (u *UserService) GetCreditoByID(id int) (Credito, error) {
-	u.l.Info("[GetCreditoByID] Getting credit", "id", id)
+// GetCreditoByID returns a credit of the fondo given an id
+func (u *UserService) GetCreditoByID(idFondo int, id int) (Credito, error) {
+	u.l.Info("[GetCreditoByID] Getting credit", "fondo", idFondo, "id", id)
 
 	credito := Credito{}
-	rows, err := u.DB.Query(`SELECT valorCuota, id, idUsuario FROM fondofamiliar_dev.creditos WHERE id = ?`, id)
+	rows, err := u.DB.Query(`SELECT valorCuota, id, idUsuario FROM creditos WHERE idFondo = ? AND id = ?`, idFondo, id)
 	if err != nil {
 		return Credito{}, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		credito := &Credito{}
-		err = rows.Scan(&credito.FechaInicio, &credito.Descripcion, &credito.ValorCuota, &credito.Tiempo, &credito.ID, &credito.IDUsuario, &credito.TotalIntereses, &credito.PorcentajeIntereses, &credito.TotalCapital, &credito.ValorTotalCredito, &credito.CapitalPagado, &credito.InteresPagado, &credito.TotalPagado, &credito.PorcentajePagado)
-		if err != nil {
-			return Credito{}, err
-		}
+		err = rows.Scan(&credito.ValorCuota, &credito.ID, &credito.IDUsuario)
+
+		return credito, err
 	}
 
-	return credito, nil
+	return credito, ErrCreditNotFound
 }
 
 func calcularCuotas(pValorTotal float64, pTiempo int, pPorcentajeInteres float64, pValorCuota float64, pNumeroCuota int, pCuotas Cuotas, pFechaInicio time.Time) Cuotas {
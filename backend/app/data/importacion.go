@@ -0,0 +1,584 @@
+package data
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrImportacionFormato is raised when the file is not a CSV nor an XLSX file
+var ErrImportacionFormato = fmt.Errorf("The file must be a CSV or XLSX file")
+
+// ErrImportacionColumnas is raised when the file does not have the expected columns
+var ErrImportacionColumnas = fmt.Errorf("The file must have the columns identificacion, fecha, valor and concepto")
+
+// ErrImportacionVacia is raised when the file has no rows
+var ErrImportacionVacia = fmt.Errorf("The file has no rows")
+
+// ErrImportacionFilas is raised when the file has too many rows
+var ErrImportacionFilas = fmt.Errorf("The file can't have more than %d rows", maxFilasImportacion)
+
+// ErrImportacionConErrores is raised when an importacion with invalid rows is confirmed
+var ErrImportacionConErrores = fmt.Errorf("The file has rows with errors, nothing was imported")
+
+// maxFilasImportacion is the most rows a file can have
+const maxFilasImportacion = 1000
+
+// Tipos of the rows of an importacion
+const (
+	// FilaAporte is an aporte of the member
+	FilaAporte = "aporte"
+	// FilaPago is a payment to a credito of the member
+	FilaPago = "pago"
+)
+
+// columnasImportacion maps the accepted headers of the file to its columns
+var columnasImportacion = map[string]string{
+	"identificacion": "identificacion",
+	"cedula":         "identificacion",
+	"email":          "identificacion",
+	"fecha":          "fecha",
+	"valor":          "valor",
+	"monto":          "valor",
+	"concepto":       "concepto",
+	"descripcion":    "concepto",
+}
+
+// conceptoCredito takes the credito of a pago from concepts like "pago credito 12"
+var conceptoCredito = regexp.MustCompile(`(\d+)\s*$`)
+
+// miles are amounts written with thousands separators like 1.250.000 or 1,250,000
+var miles = regexp.MustCompile(`^\d{1,3}([.,]\d{3})+$`)
+
+// Importacion is a CSV or XLSX file of aportes and pagos. Every row is checked before anything
+// is stored and the whole file is imported in a single transaction, only once. Duplicadas are the
+// rows skipped because they were already imported
+type Importacion struct {
+	ID         int              `json:"id,omitempty"`
+	Nombre     string           `json:"nombre"`
+	Hash       string           `json:"hash"`
+	Importada  bool             `json:"importada"`
+	Filas      FilasImportacion `json:"filas,omitempty"`
+	Aportes    int              `json:"aportes"`
+	Pagos      int              `json:"pagos"`
+	Total      int              `json:"total"`
+	Errores    int              `json:"errores"`
+	Duplicadas int              `json:"duplicadas"`
+	Fecha      time.Time        `json:"fecha"`
+	Actor
+}
+
+// Importaciones is a list of Importacion
+type Importaciones []*Importacion
+
+// FilaImportacion is a row of an importacion, the concepto tells if it is an aporte or a pago
+// and may end with the id of the credito paid. Pagos are split between the intereses and
+// the capital of the credito in the proportion of its totals. A row with the member, fecha, valor
+// and concepto of a row already imported, by this file or another one, is Duplicada and skipped
+type FilaImportacion struct {
+	Fila           int      `json:"fila"`
+	Identificacion string   `json:"identificacion" validate:"required,max=255"`
+	Fecha          string   `json:"fecha" validate:"required,fecha"`
+	Valor          int      `json:"valor" validate:"required,min=1"`
+	Concepto       string   `json:"concepto" validate:"required,max=255"`
+	Tipo           string   `json:"tipo,omitempty"`
+	IDUsuario      int      `json:"idUsuario,omitempty"`
+	IDCredito      int      `json:"idCredito,omitempty"`
+	ValorCapital   int      `json:"valorCapital,omitempty"`
+	ValorIntereses int      `json:"valorIntereses,omitempty"`
+	Duplicada      bool     `json:"duplicada,omitempty"`
+	Errores        []string `json:"errores,omitempty"`
+	hash           string
+}
+
+// FilasImportacion is a list of FilaImportacion
+type FilasImportacion []*FilaImportacion
+
+// creditoImportacion is what is owed on an active credito while the rows are matched
+type creditoImportacion struct {
+	id             int
+	idUsuario      int
+	totalIntereses int
+	valorTotal     int
+	debeCapital    int
+	debeInteres    int
+}
+
+// validateFecha is registered as the fecha tag of the validator, it takes YYYY-MM-DD dates up to today
+func validateFecha(fl validator.FieldLevel) bool {
+	t, err := time.Parse("2006-01-02", fl.Field().String())
+	if err != nil {
+		return false
+	}
+
+	return t.Year() >= 2000 && t.Before(time.Now())
+}
+
+// ReadImportacion reads the rows of a CSV or XLSX file, the first row must have the headers.
+// Values that can't be read are left empty so the validation of the row reports them
+func ReadImportacion(nombre string, b []byte) (Importacion, error) {
+	sum := sha256.Sum256(b)
+	imp := Importacion{Nombre: nombre, Hash: hex.EncodeToString(sum[:]), Filas: FilasImportacion{}}
+
+	var (
+		rows [][]string
+		err  error
+	)
+	switch {
+	case strings.HasSuffix(strings.ToLower(nombre), ".xlsx"):
+		rows, err = readXLSX(b)
+	case strings.HasSuffix(strings.ToLower(nombre), ".csv"):
+		rows, err = readCSV(b)
+	default:
+		err = ErrImportacionFormato
+	}
+	if err != nil {
+		return imp, err
+	}
+	if len(rows) < 2 {
+		return imp, ErrImportacionVacia
+	}
+
+	columnas := map[string]int{}
+	for i, h := range rows[0] {
+		if c, ok := columnasImportacion[strings.ToLower(strings.TrimSpace(h))]; ok {
+			columnas[c] = i
+		}
+	}
+	if len(columnas) != 4 {
+		return imp, ErrImportacionColumnas
+	}
+
+	for i, row := range rows[1:] {
+		cell := func(c string) string {
+			if columnas[c] < len(row) {
+				return strings.TrimSpace(row[columnas[c]])
+			}
+			return ""
+		}
+		if strings.Join(row, "") == "" {
+			continue
+		}
+
+		imp.Filas = append(imp.Filas, &FilaImportacion{
+			Fila:           i + 2,
+			Identificacion: cell("identificacion"),
+			Fecha:          parseFechaImportacion(cell("fecha")),
+			Valor:          parseValorImportacion(cell("valor")),
+			Concepto:       cell("concepto"),
+		})
+		if len(imp.Filas) > maxFilasImportacion {
+			return imp, ErrImportacionFilas
+		}
+	}
+	if len(imp.Filas) == 0 {
+		return imp, ErrImportacionVacia
+	}
+
+	return imp, nil
+}
+
+// PreviewImportacion matches the rows of an importacion to the members and creditos of the fondo
+// without storing them, the rows that can't be imported get their errors
+func (u *UserService) PreviewImportacion(idFondo int, imp *Importacion) error {
+	u.l.Info("[PreviewImportacion] Checking importacion", "fondo", idFondo, "nombre", imp.Nombre, "filas", len(imp.Filas))
+
+	err := u.DB.QueryRow("SELECT id FROM importaciones WHERE idFondo = ? AND hash = ?", idFondo, imp.Hash).Scan(&imp.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	imp.Importada = err == nil
+
+	return prepararImportacion(u.DB, idFondo, imp)
+}
+
+// CreateImportacion imports every row of the file in a single transaction. A file that was already
+// imported is not imported again, the first importacion is returned with Importada set, and the
+// rows already imported by other files are skipped
+func (u *UserService) CreateImportacion(idFondo int, imp *Importacion, actor Actor) (Importacion, error) {
+	u.l.Info("[CreateImportacion] Importing", "fondo", idFondo, "nombre", imp.Nombre, "filas", len(imp.Filas), "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return *imp, err
+	}
+	defer tx.Rollback()
+
+	// members and creditos can't change while the rows are stored, liquidaciones lock the fondo too
+	var fondo int
+	err = tx.QueryRow("SELECT id FROM fondo WHERE id = ? FOR UPDATE", idFondo).Scan(&fondo)
+	if err != nil {
+		return *imp, err
+	}
+
+	previa := Importacion{}
+	var previaActor, previaKey sql.NullInt64
+	err = tx.QueryRow("SELECT id, nombre, hash, aportes, pagos, total, idActor, idAPIKey, fecha FROM importaciones WHERE idFondo = ? AND hash = ?", idFondo, imp.Hash).
+		Scan(&previa.ID, &previa.Nombre, &previa.Hash, &previa.Aportes, &previa.Pagos, &previa.Total, &previaActor, &previaKey, &previa.Fecha)
+	if err == nil {
+		u.l.Info("[CreateImportacion] File already imported", "fondo", idFondo, "importacion", previa.ID)
+		previa.Actor = scanActor(previaActor, previaKey)
+		previa.Importada = true
+		return previa, nil
+	}
+	if err != sql.ErrNoRows {
+		return *imp, err
+	}
+
+	err = prepararImportacion(tx, idFondo, imp)
+	if err != nil {
+		return *imp, err
+	}
+	if imp.Errores > 0 {
+		return *imp, ErrImportacionConErrores
+	}
+
+	imp.Actor = actor
+	imp.Fecha = time.Now()
+	idActor, idAPIKey := actor.values()
+	res, err := tx.Exec("INSERT INTO importaciones (idFondo, nombre, hash, filas, aportes, pagos, total, idActor, idAPIKey, fecha) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		idFondo, imp.Nombre, imp.Hash, len(imp.Filas), imp.Aportes, imp.Pagos, imp.Total, idActor, idAPIKey, imp.Fecha)
+	if err != nil {
+		return *imp, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return *imp, err
+	}
+	imp.ID = int(id)
+
+	for _, f := range imp.Filas {
+		if f.Duplicada {
+			continue
+		}
+
+		switch f.Tipo {
+		case FilaAporte:
+			_, err = tx.Exec("INSERT INTO aportes (valor, idUsuario, fecha, idFondo, idImportacion) VALUES (?, ?, ?, ?, ?)", f.Valor, f.IDUsuario, f.Fecha, idFondo, imp.ID)
+		case FilaPago:
+			if f.ValorCapital > 0 {
+				_, err = tx.Exec("INSERT INTO creditos_cuotas (valor, idCredito, fecha, idImportacion) VALUES (?, ?, ?, ?)", f.ValorCapital, f.IDCredito, f.Fecha, imp.ID)
+			}
+			if err == nil && f.ValorIntereses > 0 {
+				_, err = tx.Exec("INSERT INTO creditos_intereses (valor, idCredito, fecha, idImportacion) VALUES (?, ?, ?, ?)", f.ValorIntereses, f.IDCredito, f.Fecha, imp.ID)
+			}
+		}
+		if err == nil {
+			_, err = tx.Exec("INSERT INTO importaciones_filas (idImportacion, idFondo, fila, hash) VALUES (?, ?, ?, ?)", imp.ID, idFondo, f.Fila, f.hash)
+		}
+		if err != nil {
+			return *imp, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return *imp, err
+	}
+
+	// late aportes of the file get the multas of the fondo
+	evaluados := map[int]bool{}
+	for _, f := range imp.Filas {
+		if f.Tipo == FilaAporte && !f.Duplicada && !evaluados[f.IDUsuario] {
+			evaluados[f.IDUsuario] = true
+			u.evaluarMultasAporte(idFondo, f.IDUsuario)
+		}
+	}
+
+	return *imp, nil
+}
+
+// GetImportaciones returns the importaciones of the fondo, newest first
+func (u *UserService) GetImportaciones(idFondo int) (Importaciones, error) {
+	u.l.Info("[GetImportaciones] Getting importaciones", "fondo", idFondo)
+
+	importaciones := Importaciones{}
+	rows, err := u.DB.Query("SELECT id, nombre, hash, aportes, pagos, total, idActor, idAPIKey, fecha FROM importaciones WHERE idFondo = ? ORDER BY fecha DESC, id DESC", idFondo)
+	if err != nil {
+		return importaciones, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		imp := &Importacion{Importada: true}
+		var actor, key sql.NullInt64
+		err = rows.Scan(&imp.ID, &imp.Nombre, &imp.Hash, &imp.Aportes, &imp.Pagos, &imp.Total, &actor, &key, &imp.Fecha)
+		if err != nil {
+			return importaciones, err
+		}
+		imp.Actor = scanActor(actor, key)
+
+		importaciones = append(importaciones, imp)
+	}
+
+	return importaciones, rows.Err()
+}
+
+// prepararImportacion matches the valid rows to the active members, by cedula or email, and the
+// pagos to their creditos, the debt of the creditos goes down with every pago of the file. Rows
+// already imported are marked as duplicadas
+func prepararImportacion(q querier, idFondo int, imp *Importacion) error {
+	miembros := map[string]int{}
+	rows, err := q.Query(`SELECT u.id, COALESCE(u.cedula, ''), u.email FROM usuario u
+		JOIN fondo_usuario fu ON fu.idUsuario = u.id WHERE fu.idFondo = ? AND fu.activo = 1`, idFondo)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		var (
+			id            int
+			cedula, email string
+		)
+		err = rows.Scan(&id, &cedula, &email)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		if cedula != "" {
+			miembros[cedula] = id
+		}
+		miembros[strings.ToLower(email)] = id
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	creditos := map[int]*creditoImportacion{}
+	rows, err = q.Query(`SELECT c.id, c.idUsuario, c.totalIntereses, c.valorTotalCredito,
+		c.totalCapital - COALESCE((SELECT SUM(valor) FROM creditos_cuotas WHERE idCredito = c.id), 0),
+		c.totalIntereses - COALESCE((SELECT SUM(valor) FROM creditos_intereses WHERE idCredito = c.id), 0)
+		FROM creditos c WHERE c.idFondo = ? AND c.activo = 1`, idFondo)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		c := &creditoImportacion{}
+		err = rows.Scan(&c.id, &c.idUsuario, &c.totalIntereses, &c.valorTotal, &c.debeCapital, &c.debeInteres)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		creditos[c.id] = c
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, f := range imp.Filas {
+		f.hash = ""
+		if id, ok := miembros[strings.ToLower(f.Identificacion)]; ok && len(f.Errores) == 0 {
+			f.hash = hashFila(id, f)
+		}
+	}
+	importadas, err := getFilasImportadas(q, idFondo, imp.Filas)
+	if err != nil {
+		return err
+	}
+
+	imp.Aportes, imp.Pagos, imp.Total, imp.Errores, imp.Duplicadas = 0, 0, 0, 0, 0
+	for _, f := range imp.Filas {
+		f.Duplicada = f.hash != "" && importadas[f.hash]
+		if f.Duplicada {
+			imp.Duplicadas++
+			continue
+		}
+		if f.hash != "" {
+			importadas[f.hash] = true
+		}
+
+		if len(f.Errores) == 0 {
+			f.Errores = matchFila(f, miembros, creditos)
+		}
+
+		if len(f.Errores) != 0 {
+			imp.Errores++
+			continue
+		}
+		if f.Tipo == FilaAporte {
+			imp.Aportes++
+		} else {
+			imp.Pagos++
+		}
+		imp.Total += f.Valor
+	}
+
+	return nil
+}
+
+// hashFila identifies a row of the member by its fecha, valor and concepto
+func hashFila(idUsuario int, f *FilaImportacion) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%d|%s|%d|%s", idUsuario, f.Fecha, f.Valor, strings.ToLower(f.Concepto))))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// getFilasImportadas returns which of the hashes of the rows were already imported in the fondo
+func getFilasImportadas(q querier, idFondo int, filas FilasImportacion) (map[string]bool, error) {
+	importadas := map[string]bool{}
+	args := []interface{}{idFondo}
+	for _, f := range filas {
+		if f.hash != "" {
+			args = append(args, f.hash)
+		}
+	}
+	if len(args) == 1 {
+		return importadas, nil
+	}
+
+	rows, err := q.Query("SELECT hash FROM importaciones_filas WHERE idFondo = ? AND hash IN (?"+strings.Repeat(", ?", len(args)-2)+")", args...)
+	if err != nil {
+		return importadas, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		err = rows.Scan(&hash)
+		if err != nil {
+			return importadas, err
+		}
+		importadas[hash] = true
+	}
+
+	return importadas, rows.Err()
+}
+
+// matchFila sets the member, the tipo and the credito of a row and returns why it can't be imported
+func matchFila(f *FilaImportacion, miembros map[string]int, creditos map[int]*creditoImportacion) []string {
+	id, ok := miembros[strings.ToLower(f.Identificacion)]
+	if !ok {
+		return []string{fmt.Sprintf("No active member of the fondo has the identificacion %s", f.Identificacion)}
+	}
+	f.IDUsuario = id
+
+	concepto := strings.ToLower(f.Concepto)
+	switch {
+	case strings.HasPrefix(concepto, FilaAporte):
+		f.Tipo = FilaAporte
+		return nil
+	case strings.HasPrefix(concepto, FilaPago), strings.HasPrefix(concepto, "credito"):
+		f.Tipo = FilaPago
+	default:
+		return []string{fmt.Sprintf("The concepto %q is not an aporte nor a pago", f.Concepto)}
+	}
+
+	var c *creditoImportacion
+	if m := conceptoCredito.FindStringSubmatch(concepto); m != nil {
+		idCredito, _ := strconv.Atoi(m[1])
+		c = creditos[idCredito]
+		if c == nil || c.idUsuario != id {
+			return []string{fmt.Sprintf("The member has no active credito %d", idCredito)}
+		}
+	} else {
+		for _, cr := range creditos {
+			if cr.idUsuario != id || cr.debeCapital+cr.debeInteres <= 0 {
+				continue
+			}
+			if c != nil {
+				return []string{"The member has several active creditos, the concepto must end with the id of the credito"}
+			}
+			c = cr
+		}
+		if c == nil {
+			return []string{"The member has no active credito to pay"}
+		}
+	}
+	f.IDCredito = c.id
+
+	if f.Valor > c.debeCapital+c.debeInteres {
+		return []string{fmt.Sprintf("The pago is greater than the %d owed on the credito %d", c.debeCapital+c.debeInteres, c.id)}
+	}
+
+	intereses := 0
+	if c.valorTotal > 0 {
+		intereses = int(int64(f.Valor) * int64(c.totalIntereses) / int64(c.valorTotal))
+	}
+	if intereses > c.debeInteres {
+		intereses = c.debeInteres
+	}
+	if f.Valor-intereses > c.debeCapital {
+		intereses = f.Valor - c.debeCapital
+	}
+
+	f.ValorIntereses = intereses
+	f.ValorCapital = f.Valor - intereses
+	c.debeInteres -= f.ValorIntereses
+	c.debeCapital -= f.ValorCapital
+
+	return nil
+}
+
+// readCSV reads a CSV file separated by commas or, as spreadsheets with a decimal comma export it, by semicolons
+func readCSV(b []byte) ([][]string, error) {
+	b = bytes.TrimPrefix(b, []byte("\xef\xbb\xbf"))
+
+	header := b
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		header = b[:i]
+	}
+
+	r := csv.NewReader(bytes.NewReader(b))
+	r.FieldsPerRecord = -1
+	if bytes.Count(header, []byte(";")) > bytes.Count(header, []byte(",")) {
+		r.Comma = ';'
+	}
+
+	rows := [][]string{}
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, ErrImportacionFormato
+		}
+
+		rows = append(rows, row)
+	}
+}
+
+// parseFechaImportacion returns the date as YYYY-MM-DD, it takes DD/MM/YYYY dates and the
+// day numbers of XLSX dates. Other values are returned as they are
+func parseFechaImportacion(s string) string {
+	for _, layout := range []string{"2006-01-02", "02/01/2006", "2/1/2006"} {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+
+	if n, err := strconv.ParseFloat(s, 64); err == nil && n >= 1 && n < 100000 {
+		return time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(n)).Format("2006-01-02")
+	}
+
+	return s
+}
+
+// parseValorImportacion returns the amount without the currency sign and the thousands separators,
+// amounts with cents or that can't be read are 0
+func parseValorImportacion(s string) int {
+	s = strings.TrimSpace(strings.TrimPrefix(s, "$"))
+	if miles.MatchString(s) {
+		s = strings.NewReplacer(".", "", ",", "").Replace(s)
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n != math.Trunc(n) || n > math.MaxInt32 {
+		return 0
+	}
+
+	return int(n)
+}
@@ -0,0 +1,320 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadImportacionCSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		file  string
+		filas []FilaImportacion
+		err   error
+	}{
+		{
+			name: "comma separated",
+			file: "identificacion,fecha,valor,concepto\n123,2021-03-15,50000,aporte marzo\n",
+			filas: []FilaImportacion{
+				{Fila: 2, Identificacion: "123", Fecha: "2021-03-15", Valor: 50000, Concepto: "aporte marzo"},
+			},
+		},
+		{
+			name: "semicolon separated with a BOM and header aliases",
+			file: "\xef\xbb\xbfCedula;Fecha;Monto;Descripcion\r\n123;15/03/2021;$1.250.000;Pago credito 4\r\n",
+			filas: []FilaImportacion{
+				{Fila: 2, Identificacion: "123", Fecha: "2021-03-15", Valor: 1250000, Concepto: "Pago credito 4"},
+			},
+		},
+		{
+			name: "columns in any order and blank rows skipped",
+			file: "concepto,valor,email,fecha\n,,,\naporte, 100 ,ana@x.co,2021-01-02\nAporte,200\n",
+			filas: []FilaImportacion{
+				{Fila: 3, Identificacion: "ana@x.co", Fecha: "2021-01-02", Valor: 100, Concepto: "aporte"},
+				{Fila: 4, Valor: 200, Concepto: "Aporte"},
+			},
+		},
+		{
+			name: "values that can't be read are left empty",
+			file: "identificacion,fecha,valor,concepto\n123,ayer,mucho,aporte\n",
+			filas: []FilaImportacion{
+				{Fila: 2, Identificacion: "123", Fecha: "ayer", Concepto: "aporte"},
+			},
+		},
+		{name: "missing column", file: "identificacion,fecha,valor\n123,2021-03-15,100\n", err: ErrImportacionColumnas},
+		{name: "only headers", file: "identificacion,fecha,valor,concepto\n", err: ErrImportacionVacia},
+		{name: "only blank rows", file: "identificacion,fecha,valor,concepto\n,,,\n", err: ErrImportacionVacia},
+		{name: "unclosed quote", file: "identificacion,fecha,valor,concepto\n\"123,2021-03-15,100,aporte\n", err: ErrImportacionFormato},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imp, err := ReadImportacion("aportes.csv", []byte(tt.file))
+			if err != tt.err {
+				t.Fatalf("ReadImportacion error = %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+
+			filas := []FilaImportacion{}
+			for _, f := range imp.Filas {
+				filas = append(filas, *f)
+			}
+			if !reflect.DeepEqual(filas, tt.filas) {
+				t.Errorf("ReadImportacion filas = %+v, want %+v", filas, tt.filas)
+			}
+		})
+	}
+}
+
+func TestReadImportacionFile(t *testing.T) {
+	file := []byte("identificacion,fecha,valor,concepto\n123,2021-03-15,100,aporte\n")
+
+	a, err := ReadImportacion("Aportes.CSV", file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := ReadImportacion("otro.csv", file)
+	if a.Hash != b.Hash || len(a.Hash) != 64 {
+		t.Errorf("hash of the same file = %q and %q, want the same sha256", a.Hash, b.Hash)
+	}
+
+	_, err = ReadImportacion("aportes.txt", file)
+	if err != ErrImportacionFormato {
+		t.Errorf("ReadImportacion of a txt file error = %v, want %v", err, ErrImportacionFormato)
+	}
+
+	_, err = ReadImportacion("aportes.xlsx", file)
+	if err != ErrImportacionFormato {
+		t.Errorf("ReadImportacion of a CSV named xlsx error = %v, want %v", err, ErrImportacionFormato)
+	}
+
+	large := "identificacion,fecha,valor,concepto\n" + strings.Repeat("123,2021-03-15,100,aporte\n", maxFilasImportacion+1)
+	_, err = ReadImportacion("aportes.csv", []byte(large))
+	if err != ErrImportacionFilas {
+		t.Errorf("ReadImportacion of %d rows error = %v, want %v", maxFilasImportacion+1, err, ErrImportacionFilas)
+	}
+}
+
+func TestReadImportacionXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"xl/sharedStrings.xml": `<sst><si><t>identificacion</t></si><si><t>fecha</t></si><si><t>valor</t></si>` +
+			`<si><t>concepto</t></si><si><r><t>apor</t></r><r><t>te</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` +
+			`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>3</v></c></row>` +
+			`<row r="2"><c r="A2"><v>123</v></c><c r="B2"><v>44270</v></c><c r="C2"><v>50000</v></c><c r="D2" t="s"><v>4</v></c></row>` +
+			`<row r="3"><c r="A3" t="inlineStr"><is><t>ana@x.co</t></is></c><c r="C3"><v>100</v></c><c r="D3" t="inlineStr"><is><t>pago</t></is></c></row>` +
+			`</sheetData></worksheet>`,
+	}
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	imp, err := ReadImportacion("aportes.xlsx", buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []FilaImportacion{
+		{Fila: 2, Identificacion: "123", Fecha: "2021-03-15", Valor: 50000, Concepto: "aporte"},
+		{Fila: 3, Identificacion: "ana@x.co", Valor: 100, Concepto: "pago"},
+	}
+	filas := []FilaImportacion{}
+	for _, f := range imp.Filas {
+		filas = append(filas, *f)
+	}
+	if !reflect.DeepEqual(filas, want) {
+		t.Errorf("ReadImportacion filas = %+v, want %+v", filas, want)
+	}
+}
+
+func TestXLSXColumn(t *testing.T) {
+	tests := map[string]int{"A1": 0, "D12": 3, "Z3": 25, "AA3": 26, "AB12": 27, "": -1, "12": -1}
+
+	for ref, want := range tests {
+		if got := xlsxColumn(ref); got != want {
+			t.Errorf("xlsxColumn(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
+
+func TestParseFechaImportacion(t *testing.T) {
+	tests := map[string]string{
+		"2021-03-15": "2021-03-15",
+		"15/03/2021": "2021-03-15",
+		"5/3/2021":   "2021-03-05",
+		"44270":      "2021-03-15",
+		"44270.5":    "2021-03-15",
+		"0":          "0",
+		"ayer":       "ayer",
+		"":           "",
+	}
+
+	for s, want := range tests {
+		if got := parseFechaImportacion(s); got != want {
+			t.Errorf("parseFechaImportacion(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestParseValorImportacion(t *testing.T) {
+	tests := map[string]int{
+		"50000":         50000,
+		"$50000":        50000,
+		"$ 1.250.000":   1250000,
+		"1,250,000":     1250000,
+		"1.500":         1500,
+		"50000.00":      50000,
+		"1250.50":       0,
+		"1,5":           0,
+		"3000000000":    0,
+		"cincuenta mil": 0,
+		"":              0,
+	}
+
+	for s, want := range tests {
+		if got := parseValorImportacion(s); got != want {
+			t.Errorf("parseValorImportacion(%q) = %d, want %d", s, got, want)
+		}
+	}
+}
+
+// creditosPrueba are the creditos of the matchFila tests, member 1 has one credito and member 3 two
+func creditosPrueba() map[int]*creditoImportacion {
+	return map[int]*creditoImportacion{
+		10: {id: 10, idUsuario: 1, totalIntereses: 200, valorTotal: 1200, debeCapital: 1000, debeInteres: 200},
+		11: {id: 11, idUsuario: 3, totalIntereses: 100, valorTotal: 1100, debeCapital: 100, debeInteres: 200},
+		12: {id: 12, idUsuario: 3, totalIntereses: 0, valorTotal: 500, debeCapital: 500},
+	}
+}
+
+func TestMatchFila(t *testing.T) {
+	miembros := map[string]int{"123": 1, "ana@x.co": 2, "456": 3}
+
+	tests := []struct {
+		name           string
+		fila           FilaImportacion
+		tipo           string
+		idUsuario      int
+		idCredito      int
+		valorCapital   int
+		valorIntereses int
+		err            bool
+	}{
+		{name: "aporte by cedula", fila: FilaImportacion{Identificacion: "123", Valor: 100, Concepto: "Aporte marzo"}, tipo: FilaAporte, idUsuario: 1},
+		{name: "aporte by email", fila: FilaImportacion{Identificacion: "ANA@X.CO", Valor: 100, Concepto: "aporte"}, tipo: FilaAporte, idUsuario: 2},
+		{name: "unknown member", fila: FilaImportacion{Identificacion: "999", Valor: 100, Concepto: "aporte"}, err: true},
+		{name: "unknown concepto", fila: FilaImportacion{Identificacion: "123", Valor: 100, Concepto: "varios"}, idUsuario: 1, err: true},
+		{
+			name: "pago split in the proportion of the credito",
+			fila: FilaImportacion{Identificacion: "123", Valor: 600, Concepto: "pago credito 10"},
+			tipo: FilaPago, idUsuario: 1, idCredito: 10, valorCapital: 500, valorIntereses: 100,
+		},
+		{
+			name: "pago to the only credito",
+			fila: FilaImportacion{Identificacion: "123", Valor: 1200, Concepto: "Credito"},
+			tipo: FilaPago, idUsuario: 1, idCredito: 10, valorCapital: 1000, valorIntereses: 200,
+		},
+		{
+			name: "capital of the pago limited to what is owed",
+			fila: FilaImportacion{Identificacion: "456", Valor: 300, Concepto: "pago 11"},
+			tipo: FilaPago, idUsuario: 3, idCredito: 11, valorCapital: 100, valorIntereses: 200,
+		},
+		{name: "several creditos", fila: FilaImportacion{Identificacion: "456", Valor: 100, Concepto: "pago"}, tipo: FilaPago, idUsuario: 3, err: true},
+		{name: "no credito", fila: FilaImportacion{Identificacion: "ana@x.co", Valor: 100, Concepto: "pago"}, tipo: FilaPago, idUsuario: 2, err: true},
+		{name: "credito of another member", fila: FilaImportacion{Identificacion: "123", Valor: 100, Concepto: "pago 11"}, tipo: FilaPago, idUsuario: 1, err: true},
+		{name: "credito that does not exist", fila: FilaImportacion{Identificacion: "123", Valor: 100, Concepto: "pago 99"}, tipo: FilaPago, idUsuario: 1, err: true},
+		{
+			name: "pago greater than the debt",
+			fila: FilaImportacion{Identificacion: "123", Valor: 1201, Concepto: "pago 10"},
+			tipo: FilaPago, idUsuario: 1, idCredito: 10, err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tt.fila
+			errs := matchFila(&f, miembros, creditosPrueba())
+			if (len(errs) != 0) != tt.err {
+				t.Fatalf("matchFila errors = %v, want errors %v", errs, tt.err)
+			}
+			if f.Tipo != tt.tipo || f.IDUsuario != tt.idUsuario || f.IDCredito != tt.idCredito {
+				t.Errorf("matchFila tipo, usuario, credito = %q, %d, %d, want %q, %d, %d",
+					f.Tipo, f.IDUsuario, f.IDCredito, tt.tipo, tt.idUsuario, tt.idCredito)
+			}
+			if f.ValorCapital != tt.valorCapital || f.ValorIntereses != tt.valorIntereses {
+				t.Errorf("matchFila capital, intereses = %d, %d, want %d, %d", f.ValorCapital, f.ValorIntereses, tt.valorCapital, tt.valorIntereses)
+			}
+		})
+	}
+}
+
+func TestMatchFilaDebt(t *testing.T) {
+	miembros := map[string]int{"123": 1, "456": 3}
+	creditos := creditosPrueba()
+
+	// the pagos of the file pay the debt of the credito down
+	filas := []struct {
+		fila FilaImportacion
+		err  bool
+	}{
+		{FilaImportacion{Identificacion: "123", Valor: 600, Concepto: "pago"}, false},
+		{FilaImportacion{Identificacion: "123", Valor: 700, Concepto: "pago"}, true},
+		{FilaImportacion{Identificacion: "123", Valor: 600, Concepto: "pago"}, false},
+		// creditos already paid are not picked when the concepto has no credito
+		{FilaImportacion{Identificacion: "456", Valor: 500, Concepto: "pago 12"}, false},
+		{FilaImportacion{Identificacion: "456", Valor: 100, Concepto: "pago"}, false},
+	}
+	for i, tt := range filas {
+		f := tt.fila
+		errs := matchFila(&f, miembros, creditos)
+		if (len(errs) != 0) != tt.err {
+			t.Errorf("fila %d errors = %v, want errors %v", i, errs, tt.err)
+		}
+	}
+
+	if c := creditos[10]; c.debeCapital != 0 || c.debeInteres != 0 {
+		t.Errorf("credito 10 owes %d capital and %d intereses, want 0", c.debeCapital, c.debeInteres)
+	}
+	if c := creditos[11]; c.debeCapital+c.debeInteres != 200 {
+		t.Errorf("credito 11 owes %d, want 200", c.debeCapital+c.debeInteres)
+	}
+}
+
+func TestHashFila(t *testing.T) {
+	f := &FilaImportacion{Identificacion: "123", Fecha: "2021-03-15", Valor: 100, Concepto: "Aporte marzo"}
+	hash := hashFila(1, f)
+
+	tests := []struct {
+		name      string
+		idUsuario int
+		fila      FilaImportacion
+		same      bool
+	}{
+		{"same row by email", 1, FilaImportacion{Identificacion: "ana@x.co", Fecha: "2021-03-15", Valor: 100, Concepto: "Aporte marzo"}, true},
+		{"concepto in other case", 1, FilaImportacion{Identificacion: "123", Fecha: "2021-03-15", Valor: 100, Concepto: "APORTE MARZO"}, true},
+		{"other member", 2, *f, false},
+		{"other fecha", 1, FilaImportacion{Identificacion: "123", Fecha: "2021-03-16", Valor: 100, Concepto: "Aporte marzo"}, false},
+		{"other valor", 1, FilaImportacion{Identificacion: "123", Fecha: "2021-03-15", Valor: 101, Concepto: "Aporte marzo"}, false},
+		{"other concepto", 1, FilaImportacion{Identificacion: "123", Fecha: "2021-03-15", Valor: 100, Concepto: "Aporte abril"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashFila(tt.idUsuario, &tt.fila) == hash; got != tt.same {
+				t.Errorf("hashFila is the same = %v, want %v", got, tt.same)
+			}
+		})
+	}
+}
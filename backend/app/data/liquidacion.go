@@ -0,0 +1,294 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrMiembroInactivo is raised when the member already left the fondo
+var ErrMiembroInactivo = fmt.Errorf("The member already left the fondo")
+
+// ErrLiquidacionCambio is raised when the neto confirmed is not the neto of the liquidacion anymore
+var ErrLiquidacionCambio = fmt.Errorf("The liquidacion changed since it was quoted")
+
+// ErrLiquidacionNegativa is raised when the member owes more than it gets, the debt has to be paid first
+var ErrLiquidacionNegativa = fmt.Errorf("The member owes more than its liquidacion, the debt must be paid first")
+
+// Liquidacion is the settlement of the account of a member that leaves the fondo. The member gets
+// its aportes and its share of the intereses of the fondo, minus what it owes on its creditos, its
+// multas and the comision. A negative Neto is what the member still owes
+type Liquidacion struct {
+	ID            int                `json:"id,omitempty"`
+	IDUsuario     int                `json:"idUsuario"`
+	Aportes       int                `json:"aportes"`
+	Participacion float64            `json:"participacion"`
+	Intereses     int                `json:"intereses"`
+	Creditos      CreditosLiquidados `json:"creditos,omitempty"`
+	DebeCapital   int                `json:"debeCapital"`
+	DebeInteres   int                `json:"debeInteres"`
+	Multas        Multas             `json:"multas,omitempty"`
+	DebeMultas    int                `json:"debeMultas"`
+	Comision      int                `json:"comision"`
+	Neto          int                `json:"neto"`
+	Fecha         time.Time          `json:"fecha"`
+	Actor
+}
+
+// Liquidaciones is a list of Liquidacion
+type Liquidaciones []*Liquidacion
+
+// CreditoLiquidado is what a member owes on an active credito, it is paid from the liquidacion
+type CreditoLiquidado struct {
+	ID          int `json:"id"`
+	DebeCapital int `json:"debeCapital"`
+	DebeInteres int `json:"debeInteres"`
+}
+
+// CreditosLiquidados is a list of CreditoLiquidado
+type CreditosLiquidados []*CreditoLiquidado
+
+// LiquidacionConfirm is the body sent to confirm a liquidacion, neto is the one of the quote
+// so the liquidacion is not applied if something changed after it was quoted
+type LiquidacionConfirm struct {
+	Comision int  `json:"comision" validate:"min=0"`
+	Neto     *int `json:"neto" validate:"required"`
+}
+
+// querier is satisfied by *sql.DB and *sql.Tx
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// GetLiquidacion returns the liquidacion of an active member of the fondo without applying it
+func (u *UserService) GetLiquidacion(idFondo int, idUsuario int, comision int) (Liquidacion, error) {
+	u.l.Info("[GetLiquidacion] Quoting liquidacion", "fondo", idFondo, "user", idUsuario, "comision", comision)
+
+	return calcularLiquidacion(u.DB, idFondo, idUsuario, comision)
+}
+
+// CreateLiquidacion applies the liquidacion of a member in a single transaction: the debt of each
+// active credito is paid from the aportes and the credito closed, the remaining aportes are
+// taken with ajustes and the member is deactivated in the fondo and signed out of it. Its aportes and creditos can still be read
+func (u *UserService) CreateLiquidacion(idFondo int, idUsuario int, c *LiquidacionConfirm, actor Actor) (Liquidacion, error) {
+	u.l.Info("[CreateLiquidacion] Applying liquidacion", "fondo", idFondo, "user", idUsuario, "actor", actor.IDActor, "key", actor.IDAPIKey, "comision", c.Comision)
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return Liquidacion{}, err
+	}
+	defer tx.Rollback()
+
+	// one liquidacion at a time per fondo, the share of intereses depends on the previous ones
+	var fondo int
+	err = tx.QueryRow("SELECT id FROM fondo WHERE id = ? FOR UPDATE", idFondo).Scan(&fondo)
+	if err != nil {
+		return Liquidacion{}, err
+	}
+
+	l, err := calcularLiquidacion(tx, idFondo, idUsuario, c.Comision)
+	if err != nil {
+		return l, err
+	}
+	if l.Neto != *c.Neto {
+		return l, ErrLiquidacionCambio
+	}
+	if l.Neto < 0 {
+		return l, ErrLiquidacionNegativa
+	}
+
+	l.Actor = actor
+	l.Fecha = time.Now()
+	for _, cr := range l.Creditos {
+		if cr.DebeCapital > 0 {
+			_, err = tx.Exec("INSERT INTO creditos_cuotas (valor, idCredito, fecha) VALUES (?, ?, ?)", cr.DebeCapital, cr.ID, l.Fecha)
+			if err != nil {
+				return l, err
+			}
+		}
+		if cr.DebeInteres > 0 {
+			_, err = tx.Exec("INSERT INTO creditos_intereses (valor, idCredito, fecha) VALUES (?, ?, ?)", cr.DebeInteres, cr.ID, l.Fecha)
+			if err != nil {
+				return l, err
+			}
+		}
+
+		_, err = tx.Exec("UPDATE creditos SET activo = 0 WHERE id = ?", cr.ID)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	for _, m := range l.Multas {
+		err = insertPagoMulta(tx, m.ID, m.Saldo, l.Fecha.Format("2006-01-02"), actor)
+		if err != nil {
+			return l, err
+		}
+	}
+
+	antes, err := descontarAportes(tx, idFondo, idUsuario, l.Aportes, AjusteLiquidacion, "Liquidacion", actor)
+	if err != nil {
+		return l, err
+	}
+	if antes != l.Aportes {
+		return l, ErrLiquidacionCambio
+	}
+
+	idActor, idAPIKey := actor.values()
+	res, err := tx.Exec(`INSERT INTO liquidaciones (idFondo, idUsuario, aportes, intereses, debeCapital, debeInteres, debeMultas, comision, neto, idActor, idAPIKey, fecha)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		idFondo, idUsuario, l.Aportes, l.Intereses, l.DebeCapital, l.DebeInteres, l.DebeMultas, l.Comision, l.Neto, idActor, idAPIKey, l.Fecha)
+	if err != nil {
+		return l, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return l, err
+	}
+	l.ID = int(id)
+
+	// the member leaves the fondo, its tokens and sesiones on it stop being accepted
+	_, err = tx.Exec("UPDATE fondo_usuario SET activo = 0, tokenVersion = tokenVersion + 1 WHERE idFondo = ? AND idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return l, err
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET revocado = 1 WHERE familia IN (SELECT familia FROM sesiones WHERE idUsuario = ? AND idFondo = ?)", idUsuario, idFondo)
+	if err != nil {
+		return l, err
+	}
+
+	_, err = tx.Exec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = ? AND idFondo = ?", idUsuario, idFondo)
+	if err != nil {
+		return l, err
+	}
+
+	return l, tx.Commit()
+}
+
+// GetLiquidaciones returns the liquidaciones applied in the fondo, newest first
+func (u *UserService) GetLiquidaciones(idFondo int) (Liquidaciones, error) {
+	u.l.Info("[GetLiquidaciones] Getting liquidaciones", "fondo", idFondo)
+
+	liquidaciones := Liquidaciones{}
+	rows, err := u.DB.Query(`SELECT id, idUsuario, aportes, intereses, debeCapital, debeInteres, debeMultas, comision, neto, idActor, idAPIKey, fecha
+		FROM liquidaciones WHERE idFondo = ? ORDER BY fecha DESC, id DESC`, idFondo)
+	if err != nil {
+		return liquidaciones, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		l := &Liquidacion{}
+		var actor, key sql.NullInt64
+		err = rows.Scan(&l.ID, &l.IDUsuario, &l.Aportes, &l.Intereses, &l.DebeCapital, &l.DebeInteres, &l.DebeMultas, &l.Comision, &l.Neto, &actor, &key, &l.Fecha)
+		if err != nil {
+			return liquidaciones, err
+		}
+		l.Actor = scanActor(actor, key)
+
+		liquidaciones = append(liquidaciones, l)
+	}
+
+	return liquidaciones, rows.Err()
+}
+
+// calcularLiquidacion computes the liquidacion of an active member. The share of intereses is the
+// part of the fondo capital the member has, applied to the intereses of the general report
+func calcularLiquidacion(q querier, idFondo int, idUsuario int, comision int) (Liquidacion, error) {
+	l := Liquidacion{IDUsuario: idUsuario, Comision: comision, Fecha: time.Now(), Creditos: CreditosLiquidados{}}
+
+	var activo bool
+	err := q.QueryRow("SELECT activo FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ?", idFondo, idUsuario).Scan(&activo)
+	if err == sql.ErrNoRows {
+		return l, ErrUserNotFound
+	}
+	if err != nil {
+		return l, err
+	}
+	if !activo {
+		return l, ErrMiembroInactivo
+	}
+
+	var capital, intereses int
+	err = q.QueryRow(`SELECT
+		COALESCE((SELECT SUM(`+aporteEfectivo+`) FROM aportes a WHERE a.idFondo = ? AND a.idUsuario = ?), 0),
+		COALESCE((SELECT SUM(`+aporteEfectivo+`) FROM aportes a WHERE a.idFondo = ?), 0),
+		`+interesesFondo,
+		idFondo, idUsuario, idFondo, idFondo, idFondo, idFondo).Scan(&l.Aportes, &capital, &intereses)
+	if err != nil {
+		return l, err
+	}
+
+	rows, err := q.Query(`SELECT c.id,
+		c.totalCapital - COALESCE((SELECT SUM(valor) FROM creditos_cuotas WHERE idCredito = c.id), 0),
+		c.totalIntereses - COALESCE((SELECT SUM(valor) FROM creditos_intereses WHERE idCredito = c.id), 0)
+		FROM creditos c WHERE c.idFondo = ? AND c.idUsuario = ? AND c.activo = 1 ORDER BY c.id`, idFondo, idUsuario)
+	if err != nil {
+		return l, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		cr := &CreditoLiquidado{}
+		err = rows.Scan(&cr.ID, &cr.DebeCapital, &cr.DebeInteres)
+		if err != nil {
+			return l, err
+		}
+
+		l.Creditos = append(l.Creditos, cr)
+	}
+	if err = rows.Err(); err != nil {
+		return l, err
+	}
+
+	l.Multas, err = getMultas(q, "m.idFondo = ? AND m.idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return l, err
+	}
+
+	l.liquidar(capital, intereses)
+
+	return l, nil
+}
+
+// liquidar computes the share of intereses and the neto of the liquidacion once its aportes, creditos
+// and multas were read. capital is the effective aportes of the fondo and intereses the ones of its
+// general report, only the multas with saldo are kept
+func (l *Liquidacion) liquidar(capital int, intereses int) {
+	l.Participacion, l.Intereses = 0, 0
+	if capital > 0 && l.Aportes > 0 {
+		l.Participacion = math.Round(float64(l.Aportes)/float64(capital)*10000) / 10000
+		if intereses > 0 {
+			l.Intereses = int(int64(intereses) * int64(l.Aportes) / int64(capital))
+		}
+	}
+
+	l.DebeCapital, l.DebeInteres = 0, 0
+	for _, cr := range l.Creditos {
+		// overpaid creditos don't give money back
+		if cr.DebeCapital < 0 {
+			cr.DebeCapital = 0
+		}
+		if cr.DebeInteres < 0 {
+			cr.DebeInteres = 0
+		}
+
+		l.DebeCapital += cr.DebeCapital
+		l.DebeInteres += cr.DebeInteres
+	}
+
+	l.DebeMultas = 0
+	multas := Multas{}
+	for _, m := range l.Multas {
+		if m.Saldo > 0 {
+			l.DebeMultas += m.Saldo
+			multas = append(multas, m)
+		}
+	}
+	l.Multas = multas
+
+	l.Neto = l.Aportes + l.Intereses - l.DebeCapital - l.DebeInteres - l.DebeMultas - l.Comision
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestLiquidar(t *testing.T) {
+	tests := []struct {
+		name           string
+		aportes        int
+		capital        int
+		intereses      int
+		comision       int
+		creditos       CreditosLiquidados
+		multas         Multas
+		participacion  float64
+		interesMiembro int
+		debeCapital    int
+		debeInteres    int
+		debeMultas     int
+		nMultas        int
+		neto           int
+	}{
+		{
+			name:    "aportes without intereses",
+			aportes: 1000, capital: 4000,
+			participacion: 0.25, neto: 1000,
+		},
+		{
+			name:    "share of the intereses is truncated",
+			aportes: 1000, capital: 3000, intereses: 1000,
+			participacion: 0.3333, interesMiembro: 333, neto: 1333,
+		},
+		{
+			name:    "the only member gets every interes",
+			aportes: 2000, capital: 2000, intereses: 500,
+			participacion: 1, interesMiembro: 500, neto: 2500,
+		},
+		{
+			name:    "losses of the fondo are not shared",
+			aportes: 1000, capital: 2000, intereses: -300,
+			participacion: 0.5, neto: 1000,
+		},
+		{
+			name:    "member without aportes",
+			capital: 2000, intereses: 500,
+		},
+		{
+			name:    "comision is kept by the fondo",
+			aportes: 1000, capital: 2000, intereses: 200, comision: 50,
+			participacion: 0.5, interesMiembro: 100, neto: 1050,
+		},
+		{
+			name:    "debt of every credito",
+			aportes: 1000, capital: 2000,
+			creditos:      CreditosLiquidados{{ID: 1, DebeCapital: 500, DebeInteres: 50}, {ID: 2, DebeCapital: 200, DebeInteres: 20}},
+			participacion: 0.5, debeCapital: 700, debeInteres: 70, neto: 230,
+		},
+		{
+			name:    "overpaid credito does not give money back",
+			aportes: 1000, capital: 2000,
+			creditos:      CreditosLiquidados{{ID: 1, DebeCapital: -100, DebeInteres: 30}},
+			participacion: 0.5, debeInteres: 30, neto: 970,
+		},
+		{
+			name:    "only multas with saldo",
+			aportes: 1000, capital: 2000,
+			multas:        Multas{{ID: 1, Valor: 50, Saldo: 50}, {ID: 2, Valor: 40, Pagado: 40}, {ID: 3, Valor: 30, Anulada: true}},
+			participacion: 0.5, debeMultas: 50, nMultas: 1, neto: 950,
+		},
+		{
+			name:    "member owes more than its aportes",
+			aportes: 100, capital: 1000,
+			creditos:      CreditosLiquidados{{ID: 1, DebeCapital: 500}},
+			participacion: 0.1, debeCapital: 500, neto: -400,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Liquidacion{Aportes: tt.aportes, Comision: tt.comision, Creditos: tt.creditos, Multas: tt.multas}
+			l.liquidar(tt.capital, tt.intereses)
+
+			if l.Participacion != tt.participacion || l.Intereses != tt.interesMiembro {
+				t.Errorf("participacion, intereses = %v, %d, want %v, %d", l.Participacion, l.Intereses, tt.participacion, tt.interesMiembro)
+			}
+			if l.DebeCapital != tt.debeCapital || l.DebeInteres != tt.debeInteres || l.DebeMultas != tt.debeMultas {
+				t.Errorf("debe capital, interes, multas = %d, %d, %d, want %d, %d, %d",
+					l.DebeCapital, l.DebeInteres, l.DebeMultas, tt.debeCapital, tt.debeInteres, tt.debeMultas)
+			}
+			if len(l.Multas) != tt.nMultas {
+				t.Errorf("multas = %d, want %d", len(l.Multas), tt.nMultas)
+			}
+			if l.Neto != tt.neto {
+				t.Errorf("neto = %d, want %d", l.Neto, tt.neto)
+			}
+		})
+	}
+}
+
+func TestLiquidarTwice(t *testing.T) {
+	// quoting and confirming compute the same liquidacion
+	l := Liquidacion{Aportes: 1000, Creditos: CreditosLiquidados{{ID: 1, DebeCapital: 200}}, Multas: Multas{{ID: 1, Saldo: 50}}}
+	l.liquidar(2000, 100)
+	neto := l.Neto
+	l.liquidar(2000, 100)
+
+	if l.Neto != neto || l.DebeCapital != 200 || l.DebeMultas != 50 {
+		t.Errorf("liquidar twice = neto %d, capital %d, multas %d, want %d, 200, 50", l.Neto, l.DebeCapital, l.DebeMultas, neto)
+	}
+}
+
+func TestCreateLiquidacionSignsOut(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM fondo WHERE id = \\? FOR UPDATE").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(2))
+	mock.ExpectQuery("SELECT activo FROM fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"activo"}).AddRow(true))
+	mock.ExpectQuery("SELECT").
+		WillReturnRows(sqlmock.NewRows([]string{"aportes", "capital", "intereses"}).AddRow(1000, 2000, 0))
+	mock.ExpectQuery("FROM creditos c").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "debeCapital", "debeInteres"}))
+	mock.ExpectQuery("FROM multas m").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idUsuario", "idRegla", "periodo", "vencimiento", "valor", "pagado", "motivo", "anulada", "fecha"}))
+	mock.ExpectQuery("FROM aportes a WHERE a.idFondo = \\? AND a.idUsuario = \\? ORDER BY a.fecha, a.id FOR UPDATE").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "valor"}).AddRow(10, 1000))
+	mock.ExpectExec("INSERT INTO aportes_ajustes").
+		WithArgs(10, -1000, AjusteLiquidacion, "Liquidacion", 1, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO liquidaciones").WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("UPDATE fondo_usuario SET activo = 0, tokenVersion = tokenVersion \\+ 1 WHERE idFondo = \\? AND idUsuario = \\?").
+		WithArgs(2, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE familia IN \\(SELECT familia FROM sesiones WHERE idUsuario = \\? AND idFondo = \\?\\)").
+		WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = \\? AND idFondo = \\?").
+		WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	neto := 1000
+	l, err := s.CreateLiquidacion(2, 7, &LiquidacionConfirm{Neto: &neto}, Actor{IDActor: 1})
+	if err != nil {
+		t.Fatalf("CreateLiquidacion error = %v", err)
+	}
+	if l.ID != 4 || l.Neto != 1000 {
+		t.Errorf("CreateLiquidacion = id %d, neto %d, want 4, 1000", l.ID, l.Neto)
+	}
+}
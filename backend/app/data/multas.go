@@ -0,0 +1,465 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+)
+
+// ErrReglaMultaNotFound is raised when a regla de multa can not be found in the fondo
+var ErrReglaMultaNotFound = fmt.Errorf("Regla de multa not found")
+
+// ErrReglaMultaSinValor is raised when a regla de multa has no valorFijo nor porcentaje
+var ErrReglaMultaSinValor = fmt.Errorf("A regla de multa needs a valorFijo or a porcentaje")
+
+// ErrMultaNotFound is raised when a multa can not be found in the fondo
+var ErrMultaNotFound = fmt.Errorf("Multa not found")
+
+// ErrMultaAnulada is raised when a multa that was waived is paid
+var ErrMultaAnulada = fmt.Errorf("The multa was anulada")
+
+// ErrPagoMultaMayor is raised when a pago is greater than what is owed on the multa
+var ErrPagoMultaMayor = fmt.Errorf("The pago is greater than the saldo of the multa")
+
+// ReglaMulta fines the members that did not pay the aporte of a period of their plan when the
+// DiasGracia after its start are over. The multa is ValorFijo plus the Porcentaje of the aporte
+// expected on the period, up to Tope when it is not 0. Periods due before Desde are not fined
+type ReglaMulta struct {
+	ID         int       `json:"id"`
+	Nombre     string    `json:"nombre"`
+	ValorFijo  int       `json:"valorFijo"`
+	Porcentaje float64   `json:"porcentaje"`
+	DiasGracia int       `json:"diasGracia"`
+	Tope       int       `json:"tope"`
+	Activa     bool      `json:"activa"`
+	Desde      time.Time `json:"desde"`
+	Creada     time.Time `json:"creada"`
+	Actor
+}
+
+// ReglasMulta is a list of ReglaMulta
+type ReglasMulta []*ReglaMulta
+
+// ReglaMultaCreate is the body sent to create a regla de multa
+type ReglaMultaCreate struct {
+	Nombre     string  `json:"nombre" validate:"required,max=255"`
+	ValorFijo  int     `json:"valorFijo" validate:"min=0"`
+	Porcentaje float64 `json:"porcentaje" validate:"min=0,max=100"`
+	DiasGracia int     `json:"diasGracia" validate:"min=0,max=90"`
+	Tope       int     `json:"tope" validate:"min=0"`
+}
+
+// Multa is a fine owed by a member for the aporte of a period, Saldo is what is still owed
+type Multa struct {
+	ID          int       `json:"id"`
+	IDUsuario   int       `json:"idUsuario"`
+	IDRegla     int       `json:"idRegla"`
+	Periodo     time.Time `json:"periodo"`
+	Vencimiento time.Time `json:"vencimiento"`
+	Valor       int       `json:"valor"`
+	Pagado      int       `json:"pagado"`
+	Saldo       int       `json:"saldo"`
+	Motivo      string    `json:"motivo"`
+	Anulada     bool      `json:"anulada"`
+	Fecha       time.Time `json:"fecha"`
+}
+
+// Multas is a list of Multa
+type Multas []*Multa
+
+// PagoMultaCreate is the body sent to pay a multa
+type PagoMultaCreate struct {
+	Valor int    `json:"valor" validate:"required,min=1"`
+	Fecha string `json:"fecha" validate:"required,fecha"`
+}
+
+// CreateReglaMulta stores a new regla de multa of the fondo, it fines the periods due from today
+func (u *UserService) CreateReglaMulta(idFondo int, r *ReglaMultaCreate, actor Actor) (ReglaMulta, error) {
+	u.l.Info("[CreateReglaMulta] Creating regla de multa", "fondo", idFondo, "nombre", r.Nombre, "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	regla := ReglaMulta{
+		Nombre:     r.Nombre,
+		ValorFijo:  r.ValorFijo,
+		Porcentaje: math.Round(r.Porcentaje*100) / 100,
+		DiasGracia: r.DiasGracia,
+		Tope:       r.Tope,
+		Activa:     true,
+		Desde:      hoy(),
+		Actor:      actor,
+		Creada:     time.Now(),
+	}
+	if regla.ValorFijo == 0 && regla.Porcentaje == 0 {
+		return regla, ErrReglaMultaSinValor
+	}
+
+	idActor, idAPIKey := actor.values()
+	res, err := u.DB.Exec(`INSERT INTO multas_reglas (idFondo, nombre, valorFijo, porcentaje, diasGracia, tope, activa, desde, idActor, idAPIKey, creada)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)`,
+		idFondo, regla.Nombre, regla.ValorFijo, regla.Porcentaje, regla.DiasGracia, regla.Tope, regla.Desde.Format("2006-01-02"), idActor, idAPIKey, regla.Creada)
+	if err != nil {
+		return regla, err
+	}
+
+	id, err := res.LastInsertId()
+	regla.ID = int(id)
+
+	return regla, err
+}
+
+// GetReglasMulta returns the reglas de multa of the fondo
+func (u *UserService) GetReglasMulta(idFondo int) (ReglasMulta, error) {
+	return getReglasMulta(u.DB, "idFondo = ?", idFondo)
+}
+
+// DeactivateReglaMulta stops a regla de multa of the fondo from giving new multas
+func (u *UserService) DeactivateReglaMulta(idFondo int, id int) error {
+	u.l.Info("[DeactivateReglaMulta] Deactivating regla de multa", "fondo", idFondo, "regla", id)
+
+	reglas, err := getReglasMulta(u.DB, "idFondo = ? AND id = ?", idFondo, id)
+	if err != nil {
+		return err
+	}
+	if len(reglas) == 0 {
+		return ErrReglaMultaNotFound
+	}
+
+	_, err = u.DB.Exec("UPDATE multas_reglas SET activa = 0 WHERE id = ?", id)
+
+	return err
+}
+
+// EvaluarMultas fines the active members of the fondo, or only idUsuario when it is not 0, for the
+// periods of their planes that were not paid when the diasGracia of each active regla ended. A member
+// gets one multa of each regla per period, so it is safe to evaluate as often as needed
+func (u *UserService) EvaluarMultas(idFondo int, idUsuario int) (Multas, error) {
+	multas := Multas{}
+	reglas, err := getReglasMulta(u.DB, "idFondo = ? AND activa = 1", idFondo)
+	if err != nil || len(reglas) == 0 {
+		return multas, err
+	}
+
+	wherePlanes := `p.idFondo = ? AND EXISTS (SELECT 1 FROM fondo_usuario fu
+		WHERE fu.idFondo = p.idFondo AND fu.idUsuario = p.idUsuario AND fu.activo = 1)`
+	whereAportes := "a.idFondo = ?"
+	args := []interface{}{idFondo}
+	if idUsuario != 0 {
+		wherePlanes += " AND p.idUsuario = ?"
+		whereAportes += " AND a.idUsuario = ?"
+		args = append(args, idUsuario)
+	}
+
+	planes, err := getPlanes(u.DB, wherePlanes, args...)
+	if err != nil {
+		return multas, err
+	}
+	aportes, err := getAportesPagados(u.DB, whereAportes, args...)
+	if err != nil {
+		return multas, err
+	}
+
+	planesUsuario := map[int]PlanesAporte{}
+	for _, p := range planes {
+		planesUsuario[p.IDUsuario] = append(planesUsuario[p.IDUsuario], p)
+	}
+	aportesUsuario := map[int][]aporteFecha{}
+	for _, a := range aportes {
+		aportesUsuario[a.idUsuario] = append(aportesUsuario[a.idUsuario], a)
+	}
+
+	h := hoy()
+	for id, p := range planesUsuario {
+		estado := calcularEstado(id, p, aportesUsuario[id], h)
+		for _, regla := range reglas {
+			for _, periodo := range estado.Periodos {
+				m := multaPeriodo(regla, periodo, aportesUsuario[id], h)
+				if m == nil {
+					continue
+				}
+				m.IDUsuario = id
+
+				creada, err := insertMulta(u.DB, idFondo, m)
+				if err != nil {
+					return multas, err
+				}
+				if creada {
+					multas = append(multas, m)
+				}
+			}
+		}
+	}
+
+	if len(multas) > 0 {
+		u.l.Info("[EvaluarMultas] Multas given", "fondo", idFondo, "user", idUsuario, "multas", len(multas))
+	}
+
+	return multas, nil
+}
+
+// EvaluarMultasFondos evaluates the multas of every fondo with active reglas, it is the scheduled check.
+// A fondo that fails does not stop the others, the error tells how many failed
+func (u *UserService) EvaluarMultasFondos() (int, error) {
+	rows, err := u.DB.Query("SELECT DISTINCT idFondo FROM multas_reglas WHERE activa = 1")
+	if err != nil {
+		return 0, err
+	}
+
+	fondos := []int{}
+	for rows.Next() {
+		var id int
+		err = rows.Scan(&id)
+		if err != nil {
+			rows.Close()
+			return 0, err
+		}
+		fondos = append(fondos, id)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	total, fallidos := 0, 0
+	for _, id := range fondos {
+		multas, err := u.EvaluarMultas(id, 0)
+		total += len(multas)
+		if err != nil {
+			u.l.Error("[EvaluarMultasFondos] Error evaluating multas", "fondo", id, "error", err)
+			fallidos++
+		}
+	}
+	if fallidos > 0 {
+		return total, fmt.Errorf("The multas of %d of %d fondos could not be evaluated", fallidos, len(fondos))
+	}
+
+	return total, nil
+}
+
+// GetMultas returns the multas of the fondo, only the ones with saldo when pendientes is true
+func (u *UserService) GetMultas(idFondo int, pendientes bool) (Multas, error) {
+	multas, err := getMultas(u.DB, "m.idFondo = ?", idFondo)
+	if err != nil || !pendientes {
+		return multas, err
+	}
+
+	conSaldo := Multas{}
+	for _, m := range multas {
+		if m.Saldo > 0 {
+			conSaldo = append(conSaldo, m)
+		}
+	}
+
+	return conSaldo, nil
+}
+
+// GetMultasUsuario returns the multas of a member of the fondo
+func (u *UserService) GetMultasUsuario(idFondo int, idUsuario int) (Multas, error) {
+	_, err := u.UserExists(idFondo, idUsuario)
+	if err != nil {
+		return Multas{}, err
+	}
+
+	return getMultas(u.DB, "m.idFondo = ? AND m.idUsuario = ?", idFondo, idUsuario)
+}
+
+// CreatePagoMulta pays a multa of the fondo, up to its saldo
+func (u *UserService) CreatePagoMulta(idFondo int, idMulta int, p *PagoMultaCreate, actor Actor) (Multa, error) {
+	u.l.Info("[CreatePagoMulta] Paying multa", "fondo", idFondo, "multa", idMulta, "valor", p.Valor, "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return Multa{}, err
+	}
+	defer tx.Rollback()
+
+	var (
+		valor   int
+		anulada bool
+	)
+	err = tx.QueryRow("SELECT valor, anulada FROM multas WHERE id = ? AND idFondo = ? FOR UPDATE", idMulta, idFondo).Scan(&valor, &anulada)
+	if err == sql.ErrNoRows {
+		return Multa{}, ErrMultaNotFound
+	}
+	if err != nil {
+		return Multa{}, err
+	}
+	if anulada {
+		return Multa{}, ErrMultaAnulada
+	}
+
+	var pagado int
+	err = tx.QueryRow("SELECT COALESCE(SUM(valor), 0) FROM multas_pagos WHERE idMulta = ?", idMulta).Scan(&pagado)
+	if err != nil {
+		return Multa{}, err
+	}
+	if p.Valor > valor-pagado {
+		return Multa{}, ErrPagoMultaMayor
+	}
+
+	err = insertPagoMulta(tx, idMulta, p.Valor, p.Fecha, actor)
+	if err != nil {
+		return Multa{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Multa{}, err
+	}
+
+	return u.getMulta(idFondo, idMulta)
+}
+
+// AnularMulta waives a multa of the fondo, what was paid on it is kept
+func (u *UserService) AnularMulta(idFondo int, idMulta int) (Multa, error) {
+	u.l.Info("[AnularMulta] Waiving multa", "fondo", idFondo, "multa", idMulta)
+
+	_, err := u.getMulta(idFondo, idMulta)
+	if err != nil {
+		return Multa{}, err
+	}
+
+	_, err = u.DB.Exec("UPDATE multas SET anulada = 1 WHERE id = ? AND idFondo = ?", idMulta, idFondo)
+	if err != nil {
+		return Multa{}, err
+	}
+
+	return u.getMulta(idFondo, idMulta)
+}
+
+// evaluarMultasAporte evaluates the multas of a member after its aportes change, an error is only
+// logged so the aporte is kept
+func (u *UserService) evaluarMultasAporte(idFondo int, idUsuario int) {
+	_, err := u.EvaluarMultas(idFondo, idUsuario)
+	if err != nil {
+		u.l.Error("[evaluarMultasAporte] Error evaluating multas", "fondo", idFondo, "user", idUsuario, "error", err)
+	}
+}
+
+// multaPeriodo returns the multa of a regla for a period of a plan, or nil when the aportes paid
+// from the start of the period until its vencimiento cover the aporte expected
+func multaPeriodo(regla *ReglaMulta, periodo *PeriodoAporte, aportes []aporteFecha, hoy time.Time) *Multa {
+	vencimiento := periodo.Inicio.AddDate(0, 0, regla.DiasGracia)
+	if !vencimiento.Before(hoy) || vencimiento.Before(regla.Desde) {
+		return nil
+	}
+
+	pagado := 0
+	for _, a := range aportes {
+		if !a.fecha.Before(periodo.Inicio) && !a.fecha.After(vencimiento) {
+			pagado += a.valor
+		}
+	}
+	if pagado >= periodo.Esperado {
+		return nil
+	}
+
+	valor := regla.ValorFijo + int(math.Round(float64(periodo.Esperado)*regla.Porcentaje/100))
+	if regla.Tope > 0 && valor > regla.Tope {
+		valor = regla.Tope
+	}
+	if valor <= 0 {
+		return nil
+	}
+
+	return &Multa{
+		IDRegla:     regla.ID,
+		Periodo:     periodo.Inicio,
+		Vencimiento: vencimiento,
+		Valor:       valor,
+		Saldo:       valor,
+		Motivo: fmt.Sprintf("%s: aporte of the period of %s paid %d of %d by %s", regla.Nombre,
+			periodo.Inicio.Format("2006-01-02"), pagado, periodo.Esperado, vencimiento.Format("2006-01-02")),
+		Fecha: time.Now(),
+	}
+}
+
+// insertMulta stores a multa unless the member already has the multa of the regla for the period
+func insertMulta(db *sql.DB, idFondo int, m *Multa) (bool, error) {
+	res, err := db.Exec(`INSERT INTO multas (idFondo, idUsuario, idRegla, periodo, vencimiento, valor, motivo, fecha)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) ON DUPLICATE KEY UPDATE id = id`,
+		idFondo, m.IDUsuario, m.IDRegla, m.Periodo.Format("2006-01-02"), m.Vencimiento.Format("2006-01-02"), m.Valor, m.Motivo, m.Fecha)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil || n != 1 {
+		return false, err
+	}
+
+	id, err := res.LastInsertId()
+	m.ID = int(id)
+
+	return true, err
+}
+
+// insertPagoMulta stores a pago of a multa made by the actor
+func insertPagoMulta(tx *sql.Tx, idMulta int, valor int, fecha string, actor Actor) error {
+	idActor, idAPIKey := actor.values()
+	_, err := tx.Exec("INSERT INTO multas_pagos (idMulta, valor, fecha, idActor, idAPIKey, creado) VALUES (?, ?, ?, ?, ?, ?)",
+		idMulta, valor, fecha, idActor, idAPIKey, time.Now())
+
+	return err
+}
+
+func (u *UserService) getMulta(idFondo int, id int) (Multa, error) {
+	multas, err := getMultas(u.DB, "m.idFondo = ? AND m.id = ?", idFondo, id)
+	if err != nil {
+		return Multa{}, err
+	}
+	if len(multas) == 0 {
+		return Multa{}, ErrMultaNotFound
+	}
+
+	return *multas[0], nil
+}
+
+func getMultas(q querier, where string, args ...interface{}) (Multas, error) {
+	multas := Multas{}
+	rows, err := q.Query(`SELECT m.id, m.idUsuario, m.idRegla, m.periodo, m.vencimiento, m.valor,
+		COALESCE((SELECT SUM(mp.valor) FROM multas_pagos mp WHERE mp.idMulta = m.id), 0), m.motivo, m.anulada, m.fecha
+		FROM multas m WHERE `+where+` ORDER BY m.periodo, m.id`, args...)
+	if err != nil {
+		return multas, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		m := &Multa{}
+		err = rows.Scan(&m.ID, &m.IDUsuario, &m.IDRegla, &m.Periodo, &m.Vencimiento, &m.Valor, &m.Pagado, &m.Motivo, &m.Anulada, &m.Fecha)
+		if err != nil {
+			return multas, err
+		}
+		if !m.Anulada {
+			m.Saldo = m.Valor - m.Pagado
+		}
+
+		multas = append(multas, m)
+	}
+
+	return multas, rows.Err()
+}
+
+func getReglasMulta(q querier, where string, args ...interface{}) (ReglasMulta, error) {
+	reglas := ReglasMulta{}
+	rows, err := q.Query(`SELECT id, nombre, valorFijo, porcentaje, diasGracia, tope, activa, desde, idActor, idAPIKey, creada
+		FROM multas_reglas WHERE `+where+` ORDER BY id`, args...)
+	if err != nil {
+		return reglas, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &ReglaMulta{}
+		var actor, key sql.NullInt64
+		err = rows.Scan(&r.ID, &r.Nombre, &r.ValorFijo, &r.Porcentaje, &r.DiasGracia, &r.Tope, &r.Activa, &r.Desde, &actor, &key, &r.Creada)
+		if err != nil {
+			return reglas, err
+		}
+		r.Actor = scanActor(actor, key)
+
+		reglas = append(reglas, r)
+	}
+
+	return reglas, rows.Err()
+}
@@ -0,0 +1,85 @@
+package data
+
+import (
+	"testing"
+)
+
+func TestMultaPeriodo(t *testing.T) {
+	marzo := &PeriodoAporte{Inicio: dia("2021-03-01"), Fin: dia("2021-03-31"), Esperado: 100}
+	regla := ReglaMulta{ID: 1, Nombre: "Mora", ValorFijo: 10, DiasGracia: 5, Desde: dia("2021-01-01")}
+
+	conRegla := func(f func(r *ReglaMulta)) *ReglaMulta {
+		r := regla
+		f(&r)
+		return &r
+	}
+
+	tests := []struct {
+		name    string
+		regla   *ReglaMulta
+		periodo *PeriodoAporte
+		aportes []aporteFecha
+		hoy     string
+		valor   int
+	}{
+		{"paid on time", &regla, marzo, []aporteFecha{{fecha: dia("2021-03-05"), valor: 100}}, "2021-04-20", 0},
+		{"paid on the last day of grace", &regla, marzo, []aporteFecha{{fecha: dia("2021-03-06"), valor: 100}}, "2021-04-20", 0},
+		{"paid after the grace", &regla, marzo, []aporteFecha{{fecha: dia("2021-03-07"), valor: 100}}, "2021-04-20", 10},
+		{"paid before the period", &regla, marzo, []aporteFecha{{fecha: dia("2021-02-28"), valor: 100}}, "2021-04-20", 10},
+		{"partly paid", &regla, marzo, []aporteFecha{{fecha: dia("2021-03-02"), valor: 50}, {fecha: dia("2021-03-03"), valor: 49}}, "2021-04-20", 10},
+		{"grace not over", &regla, marzo, nil, "2021-03-06", 0},
+		{"grace over", &regla, marzo, nil, "2021-03-07", 10},
+		{"due before the regla", conRegla(func(r *ReglaMulta) { r.Desde = dia("2021-03-07") }), marzo, nil, "2021-04-20", 0},
+		{"due the day the regla was created", conRegla(func(r *ReglaMulta) { r.Desde = dia("2021-03-06") }), marzo, nil, "2021-04-20", 10},
+		{"porcentaje of the aporte", conRegla(func(r *ReglaMulta) { r.Porcentaje = 5.5 }), marzo, nil, "2021-04-20", 16},
+		{"only porcentaje", conRegla(func(r *ReglaMulta) { r.ValorFijo, r.Porcentaje = 0, 2 }), marzo, nil, "2021-04-20", 2},
+		{"up to tope", conRegla(func(r *ReglaMulta) { r.Porcentaje, r.Tope = 5, 12 }), marzo, nil, "2021-04-20", 12},
+		{"multa that rounds to 0", conRegla(func(r *ReglaMulta) { r.ValorFijo, r.Porcentaje = 0, 0.4 }), marzo, nil, "2021-04-20", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := multaPeriodo(tt.regla, tt.periodo, tt.aportes, dia(tt.hoy))
+			if tt.valor == 0 {
+				if m != nil {
+					t.Errorf("multaPeriodo = %d, want no multa", m.Valor)
+				}
+				return
+			}
+
+			if m == nil {
+				t.Fatalf("multaPeriodo = no multa, want %d", tt.valor)
+			}
+			if m.Valor != tt.valor || m.Saldo != tt.valor || m.IDRegla != tt.regla.ID {
+				t.Errorf("multaPeriodo valor, saldo, regla = %d, %d, %d, want %d, %d, %d", m.Valor, m.Saldo, m.IDRegla, tt.valor, tt.valor, tt.regla.ID)
+			}
+			if !m.Periodo.Equal(tt.periodo.Inicio) || !m.Vencimiento.Equal(dia("2021-03-06")) {
+				t.Errorf("multaPeriodo periodo, vencimiento = %s, %s, want 2021-03-01, 2021-03-06",
+					m.Periodo.Format("2006-01-02"), m.Vencimiento.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestMultaPeriodoMonthEnd(t *testing.T) {
+	regla := &ReglaMulta{ID: 1, Nombre: "Mora", ValorFijo: 10, DiasGracia: 3, Desde: dia("2021-01-01")}
+	planes := PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-31")}}
+	aportes := []aporteFecha{
+		{fecha: dia("2021-01-31"), valor: 100},
+		{fecha: dia("2021-03-02"), valor: 100},
+	}
+	hoy := dia("2021-05-10")
+
+	// the aporte of february is due on the 28th, so the one paid on march 2nd is inside the grace
+	multas := []string{}
+	for _, periodo := range calcularEstado(1, planes, aportes, hoy).Periodos {
+		if m := multaPeriodo(regla, periodo, aportes, hoy); m != nil {
+			multas = append(multas, m.Periodo.Format("2006-01-02")+" "+m.Vencimiento.Format("2006-01-02"))
+		}
+	}
+
+	want := []string{"2021-03-31 2021-04-03", "2021-04-30 2021-05-03"}
+	if len(multas) != len(want) || multas[0] != want[0] || multas[1] != want[1] {
+		t.Errorf("multas = %v, want %v", multas, want)
+	}
+}
@@ -0,0 +1,129 @@
+package data
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// Permissions checked by the app, API keys use the same names as scopes. The scopes an API key
+// can be given are listed on APIKeyScopes of authentication-api/data/apikeys.go
+const (
+	// PermAportesRead reads the aportes of every member of the fondo
+	PermAportesRead = "aportes:read"
+	// PermAportesWrite creates aportes
+	PermAportesWrite = "aportes:write"
+	// PermAportesAdjust corrects and reverts aportes with ajustes
+	PermAportesAdjust = "aportes:adjust"
+	// PermPlanesWrite sets and ends the planes of aporte of the members
+	PermPlanesWrite = "planes:write"
+	// PermCreditosRead reads the creditos of every member of the fondo
+	PermCreditosRead = "creditos:read"
+	// PermCreditosWrite creates creditos
+	PermCreditosWrite = "creditos:write"
+	// PermCreditosSimulate calculates the cuotas of a credito without creating it
+	PermCreditosSimulate = "creditos:simulate"
+	// PermPagosWrite registers payments to creditos
+	PermPagosWrite = "pagos:write"
+	// PermDescuentosWrite discounts money from the aportes of a member
+	PermDescuentosWrite = "descuentos:write"
+	// PermReporteRead reads the general report of the fondo
+	PermReporteRead = "reporte:read"
+	// PermCuentaRead reads the aportes and creditos of the user itself
+	PermCuentaRead = "cuenta:read"
+	// PermLiquidacionesRead quotes the liquidacion of a member and reads the applied ones
+	PermLiquidacionesRead = "liquidaciones:read"
+	// PermLiquidacionesWrite applies the liquidacion of a member that leaves the fondo
+	PermLiquidacionesWrite = "liquidaciones:write"
+	// PermMultasRead reads the reglas de multa and the multas of every member of the fondo
+	PermMultasRead = "multas:read"
+	// PermMultasWrite manages the reglas de multa, pays and waives multas
+	PermMultasWrite = "multas:write"
+	// PermMultasEvaluate fines the members that did not pay their aportes on time, used by scheduled checks
+	PermMultasEvaluate = "multas:evaluate"
+	// PermImportacionesRead reads the importaciones of files of aportes and pagos
+	PermImportacionesRead = "importaciones:read"
+	// PermImportacionesWrite checks and imports files of aportes and pagos
+	PermImportacionesWrite = "importaciones:write"
+)
+
+// permissions is every permission known by the app
+var permissions = []string{
+	PermAportesRead,
+	PermAportesWrite,
+	PermAportesAdjust,
+	PermPlanesWrite,
+	PermCreditosRead,
+	PermCreditosWrite,
+	PermCreditosSimulate,
+	PermPagosWrite,
+	PermDescuentosWrite,
+	PermReporteRead,
+	PermCuentaRead,
+	PermLiquidacionesRead,
+	PermLiquidacionesWrite,
+	PermImportacionesRead,
+	PermImportacionesWrite,
+	PermMultasRead,
+	PermMultasWrite,
+	PermMultasEvaluate,
+}
+
+// Policy maps each rol to the permissions it has
+type Policy map[int][]string
+
+// DefaultPolicy returns the policy used when no policy file is configured,
+// rol 1 administers the fondo, rol 2 audits it reading every member and rol 3 is a member
+func DefaultPolicy() Policy {
+	return Policy{
+		1: permissions,
+		2: {PermAportesRead, PermCreditosRead, PermCreditosSimulate, PermReporteRead, PermCuentaRead, PermLiquidacionesRead, PermImportacionesRead, PermMultasRead},
+		3: {PermCuentaRead, PermReporteRead, PermCreditosSimulate},
+	}
+}
+
+// LoadPolicy reads a policy from a JSON file like {"1": ["aportes:read", ...], "3": [...]},
+// roles that are not in the file have no permissions
+func LoadPolicy(path string) (Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := Policy{}
+	err = json.NewDecoder(f).Decode(&p)
+	if err != nil {
+		return nil, err
+	}
+
+	for rol, perms := range p {
+		for _, perm := range perms {
+			if !contains(permissions, perm) {
+				return nil, fmt.Errorf("Unknown permission %q for rol %d", perm, rol)
+			}
+		}
+	}
+
+	return p, nil
+}
+
+// Permissions returns the permissions of a rol
+func (p Policy) Permissions(rol int) []string {
+	return p[rol]
+}
+
+// HasPermission returns true if the user, or the API key, was given the permission
+func (u User) HasPermission(perm string) bool {
+	return contains(u.Permisos, perm)
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
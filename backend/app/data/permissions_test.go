@@ -0,0 +1,81 @@
+package data
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		want   Policy
+		ok     bool
+	}{
+		{"policy", `{"1": ["aportes:read", "aportes:write"], "3": ["cuenta:read"]}`, Policy{1: {PermAportesRead, PermAportesWrite}, 3: {PermCuentaRead}}, true},
+		{"rol without permissions", `{"2": []}`, Policy{2: {}}, true},
+		{"unknown permission", `{"1": ["aportes:delete"]}`, nil, false},
+		{"rol that is not a number", `{"admin": ["aportes:read"]}`, nil, false},
+		{"invalid json", `{"1": `, nil, false},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, "policy.json")
+			err := ioutil.WriteFile(path, []byte(tt.policy), 0600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := LoadPolicy(path)
+			if (err == nil) != tt.ok {
+				t.Fatalf("LoadPolicy error = %v, want ok %v", err, tt.ok)
+			}
+			if !reflect.DeepEqual(p, tt.want) {
+				t.Errorf("LoadPolicy = %v, want %v", p, tt.want)
+			}
+		})
+	}
+
+	_, err := LoadPolicy(filepath.Join(dir, "missing.json"))
+	if err == nil {
+		t.Error("LoadPolicy of a missing file did not fail")
+	}
+}
+
+func TestDefaultPolicy(t *testing.T) {
+	p := DefaultPolicy()
+
+	tests := []struct {
+		rol  int
+		perm string
+		want bool
+	}{
+		{1, PermAportesWrite, true},
+		{1, PermMultasEvaluate, true},
+		{2, PermAportesRead, true},
+		{2, PermAportesWrite, false},
+		{2, PermLiquidacionesWrite, false},
+		{3, PermCuentaRead, true},
+		{3, PermAportesRead, false},
+		{4, PermCuentaRead, false},
+	}
+
+	for _, tt := range tests {
+		u := User{Rol: tt.rol, Permisos: p.Permissions(tt.rol)}
+		if got := u.HasPermission(tt.perm); got != tt.want {
+			t.Errorf("rol %d HasPermission(%q) = %v, want %v", tt.rol, tt.perm, got, tt.want)
+		}
+	}
+
+	for rol, perms := range p {
+		for _, perm := range perms {
+			if !contains(permissions, perm) {
+				t.Errorf("rol %d has the unknown permission %q", rol, perm)
+			}
+		}
+	}
+}
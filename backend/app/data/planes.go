@@ -0,0 +1,419 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrPlanInicio is raised when a plan does not start after the previous planes of the member
+var ErrPlanInicio = fmt.Errorf("The plan must start after the start of the current plan and the end of the previous ones")
+
+// ErrPlanNotFound is raised when the member has no current plan
+var ErrPlanNotFound = fmt.Errorf("The member has no current plan")
+
+// mesesFrecuencia are the months of each frecuencia of the planes
+var mesesFrecuencia = map[string]int{
+	"mensual":    1,
+	"bimestral":  2,
+	"trimestral": 3,
+	"semestral":  6,
+	"anual":      12,
+}
+
+// aportePagado is what the member paid on the aporte aliased a, corrections and reversals change
+// it but the descuentos and liquidaciones taken later from the aporte don't
+const aportePagado = "a.valor + COALESCE((SELECT SUM(aj.valor) FROM aportes_ajustes aj WHERE aj.idAporte = a.id AND aj.tipo IN ('" +
+	AjusteCorreccion + "', '" + AjusteReversion + "')), 0)"
+
+// PlanAporte is the aporte a member is expected to make on every period since FechaInicio
+type PlanAporte struct {
+	ID          int        `json:"id"`
+	IDUsuario   int        `json:"idUsuario"`
+	Valor       int        `json:"valor"`
+	Frecuencia  string     `json:"frecuencia"`
+	FechaInicio time.Time  `json:"fechaInicio"`
+	FechaFin    *time.Time `json:"fechaFin"`
+	Creado      time.Time  `json:"creado"`
+	Actor
+}
+
+// PlanesAporte is a list of PlanAporte
+type PlanesAporte []*PlanAporte
+
+// PlanAporteCreate is the body sent to set the plan of a member
+type PlanAporteCreate struct {
+	Valor       int    `json:"valor" validate:"required,min=1"`
+	Frecuencia  string `json:"frecuencia" validate:"required,oneof=mensual bimestral trimestral semestral anual"`
+	FechaInicio string `json:"fechaInicio" validate:"required,dia"`
+}
+
+// PeriodoAporte is a period of a plan, Saldo is what was paid minus what was expected
+// up to the period, a negative saldo is owed
+type PeriodoAporte struct {
+	Inicio   time.Time `json:"inicio"`
+	Fin      time.Time `json:"fin"`
+	Esperado int       `json:"esperado"`
+	Pagado   int       `json:"pagado"`
+	Saldo    int       `json:"saldo"`
+	Vencido  bool      `json:"vencido"`
+}
+
+// EstadoAportes compares the aportes of a member with its planes. Esperado only counts the periods
+// that already ended, Atraso is what is owed on them and PeriodosAtrasados how many aportes of the
+// current plan that is
+type EstadoAportes struct {
+	IDUsuario         int              `json:"idUsuario"`
+	Plan              *PlanAporte      `json:"plan"`
+	Periodos          []*PeriodoAporte `json:"periodos,omitempty"`
+	Esperado          int              `json:"esperado"`
+	Pagado            int              `json:"pagado"`
+	Atraso            int              `json:"atraso"`
+	PeriodosAtrasados int              `json:"periodosAtrasados"`
+}
+
+// aporteFecha is the value paid by a member on a date
+type aporteFecha struct {
+	idUsuario int
+	fecha     time.Time
+	valor     int
+}
+
+// validateDia is registered as the dia tag of the validator, it takes any YYYY-MM-DD date since 2000
+func validateDia(fl validator.FieldLevel) bool {
+	t, err := time.Parse("2006-01-02", fl.Field().String())
+	if err != nil {
+		return false
+	}
+
+	return t.Year() >= 2000
+}
+
+// SetPlanAporte sets the plan of an active member of the fondo, the current plan ends the day before
+func (u *UserService) SetPlanAporte(idFondo int, idUsuario int, p *PlanAporteCreate, actor Actor) (PlanAporte, error) {
+	u.l.Info("[SetPlanAporte] Setting plan", "fondo", idFondo, "user", idUsuario, "valor", p.Valor, "frecuencia", p.Frecuencia, "actor", actor.IDActor, "key", actor.IDAPIKey)
+
+	inicio, _ := time.Parse("2006-01-02", p.FechaInicio)
+	plan := PlanAporte{IDUsuario: idUsuario, Valor: p.Valor, Frecuencia: p.Frecuencia, FechaInicio: inicio, Actor: actor, Creado: time.Now()}
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return plan, err
+	}
+	defer tx.Rollback()
+
+	err = lockMiembroActivo(tx, idFondo, idUsuario)
+	if err != nil {
+		return plan, err
+	}
+
+	planes, err := getPlanes(tx, "p.idFondo = ? AND p.idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return plan, err
+	}
+	if solapaPlanes(planes, inicio) {
+		return plan, ErrPlanInicio
+	}
+
+	_, err = tx.Exec("UPDATE planes_aporte SET fechaFin = ? WHERE idFondo = ? AND idUsuario = ? AND fechaFin IS NULL",
+		inicio.AddDate(0, 0, -1).Format("2006-01-02"), idFondo, idUsuario)
+	if err != nil {
+		return plan, err
+	}
+
+	idActor, idAPIKey := actor.values()
+	res, err := tx.Exec("INSERT INTO planes_aporte (idFondo, idUsuario, valor, frecuencia, fechaInicio, idActor, idAPIKey, creado) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		idFondo, idUsuario, plan.Valor, plan.Frecuencia, p.FechaInicio, idActor, idAPIKey, plan.Creado)
+	if err != nil {
+		return plan, err
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return plan, err
+	}
+	plan.ID = int(id)
+
+	return plan, tx.Commit()
+}
+
+// EndPlanAporte ends the planes of a member today, no more aportes are expected from it. Planes
+// that had not started yet are removed
+func (u *UserService) EndPlanAporte(idFondo int, idUsuario int) error {
+	u.l.Info("[EndPlanAporte] Ending plan", "fondo", idFondo, "user", idUsuario)
+
+	tx, err := u.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("DELETE FROM planes_aporte WHERE idFondo = ? AND idUsuario = ? AND fechaInicio > CURDATE()", idFondo, idUsuario)
+	if err != nil {
+		return err
+	}
+	borrados, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	res, err = tx.Exec("UPDATE planes_aporte SET fechaFin = CURDATE() WHERE idFondo = ? AND idUsuario = ? AND (fechaFin IS NULL OR fechaFin > CURDATE())", idFondo, idUsuario)
+	if err != nil {
+		return err
+	}
+	terminados, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if borrados+terminados == 0 {
+		return ErrPlanNotFound
+	}
+
+	return tx.Commit()
+}
+
+// GetPlanesAporte returns the planes of a member of the fondo, the current one last
+func (u *UserService) GetPlanesAporte(idFondo int, idUsuario int) (PlanesAporte, error) {
+	_, err := u.UserExists(idFondo, idUsuario)
+	if err != nil {
+		return PlanesAporte{}, err
+	}
+
+	return getPlanes(u.DB, "p.idFondo = ? AND p.idUsuario = ?", idFondo, idUsuario)
+}
+
+// GetEstadoAportes compares the aportes of a member of the fondo with its planes period by period
+func (u *UserService) GetEstadoAportes(idFondo int, idUsuario int) (EstadoAportes, error) {
+	u.l.Info("[GetEstadoAportes] Getting estado of aportes", "fondo", idFondo, "user", idUsuario)
+
+	_, err := u.UserExists(idFondo, idUsuario)
+	if err != nil {
+		return EstadoAportes{IDUsuario: idUsuario}, err
+	}
+
+	planes, err := getPlanes(u.DB, "p.idFondo = ? AND p.idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return EstadoAportes{IDUsuario: idUsuario}, err
+	}
+
+	aportes, err := getAportesPagados(u.DB, "a.idFondo = ? AND a.idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return EstadoAportes{IDUsuario: idUsuario}, err
+	}
+
+	return calcularEstado(idUsuario, planes, aportes, hoy()), nil
+}
+
+// GetMorosidad returns the active members of the fondo that owe aportes of their planes, the ones
+// that owe the most first
+func (u *UserService) GetMorosidad(idFondo int) ([]*EstadoAportes, error) {
+	u.l.Info("[GetMorosidad] Getting members behind on their aportes", "fondo", idFondo)
+
+	morosos := []*EstadoAportes{}
+	planes, err := getPlanes(u.DB, `p.idFondo = ? AND EXISTS (SELECT 1 FROM fondo_usuario fu
+		WHERE fu.idFondo = p.idFondo AND fu.idUsuario = p.idUsuario AND fu.activo = 1)`, idFondo)
+	if err != nil {
+		return morosos, err
+	}
+
+	aportes, err := getAportesPagados(u.DB, "a.idFondo = ?", idFondo)
+	if err != nil {
+		return morosos, err
+	}
+
+	planesUsuario := map[int]PlanesAporte{}
+	for _, p := range planes {
+		planesUsuario[p.IDUsuario] = append(planesUsuario[p.IDUsuario], p)
+	}
+	aportesUsuario := map[int][]aporteFecha{}
+	for _, a := range aportes {
+		aportesUsuario[a.idUsuario] = append(aportesUsuario[a.idUsuario], a)
+	}
+
+	h := hoy()
+	for id, p := range planesUsuario {
+		e := calcularEstado(id, p, aportesUsuario[id], h)
+		if e.Atraso == 0 {
+			continue
+		}
+
+		e.Periodos = nil
+		morosos = append(morosos, &e)
+	}
+
+	sort.Slice(morosos, func(i, j int) bool {
+		if morosos[i].Atraso != morosos[j].Atraso {
+			return morosos[i].Atraso > morosos[j].Atraso
+		}
+		return morosos[i].IDUsuario < morosos[j].IDUsuario
+	})
+
+	return morosos, nil
+}
+
+// calcularEstado splits the planes in periods up to today and adds the aportes paid on each one,
+// aportes made outside of the periods of a plan are not counted
+func calcularEstado(idUsuario int, planes PlanesAporte, aportes []aporteFecha, hoy time.Time) EstadoAportes {
+	e := EstadoAportes{IDUsuario: idUsuario, Periodos: []*PeriodoAporte{}}
+
+	for _, p := range planes {
+		if p.FechaFin == nil {
+			e.Plan = p
+		}
+
+		meses := mesesFrecuencia[p.Frecuencia]
+		if meses == 0 {
+			continue
+		}
+
+		for k := 0; ; k++ {
+			inicio := sumarMeses(p.FechaInicio, k*meses)
+			if inicio.After(hoy) || (p.FechaFin != nil && inicio.After(*p.FechaFin)) {
+				break
+			}
+
+			fin := sumarMeses(p.FechaInicio, (k+1)*meses).AddDate(0, 0, -1)
+			if p.FechaFin != nil && fin.After(*p.FechaFin) {
+				fin = *p.FechaFin
+			}
+
+			periodo := &PeriodoAporte{Inicio: inicio, Fin: fin, Esperado: p.Valor, Vencido: fin.Before(hoy)}
+			for _, a := range aportes {
+				if !a.fecha.Before(inicio) && !a.fecha.After(fin) {
+					periodo.Pagado += a.valor
+				}
+			}
+
+			e.Pagado += periodo.Pagado
+			if periodo.Vencido {
+				e.Esperado += periodo.Esperado
+			}
+			e.Periodos = append(e.Periodos, periodo)
+		}
+	}
+
+	saldo := 0
+	for _, periodo := range e.Periodos {
+		saldo += periodo.Pagado - periodo.Esperado
+		periodo.Saldo = saldo
+	}
+
+	if e.Esperado > e.Pagado {
+		e.Atraso = e.Esperado - e.Pagado
+
+		valor := planes[len(planes)-1].Valor
+		if e.Plan != nil {
+			valor = e.Plan.Valor
+		}
+		e.PeriodosAtrasados = (e.Atraso + valor - 1) / valor
+	}
+
+	return e
+}
+
+// solapaPlanes returns true if a plan starting on inicio overlaps the planes of the member, it has to
+// start after the current plan starts and after the ended ones end
+func solapaPlanes(planes PlanesAporte, inicio time.Time) bool {
+	for _, p := range planes {
+		ultimo := p.FechaInicio
+		if p.FechaFin != nil {
+			ultimo = *p.FechaFin
+		}
+		if !inicio.After(ultimo) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// sumarMeses adds months to a date keeping its day, or the last day of the month when it has less
+// days, so a plan that starts on the 31st has its periods on the 28th of February and the 30th of April
+func sumarMeses(t time.Time, meses int) time.Time {
+	primero := time.Date(t.Year(), t.Month()+time.Month(meses), 1, 0, 0, 0, 0, t.Location())
+	ultimo := primero.AddDate(0, 1, -1).Day()
+	if t.Day() < ultimo {
+		ultimo = t.Day()
+	}
+
+	return time.Date(primero.Year(), primero.Month(), ultimo, 0, 0, 0, 0, t.Location())
+}
+
+func getPlanes(q querier, where string, args ...interface{}) (PlanesAporte, error) {
+	planes := PlanesAporte{}
+	rows, err := q.Query(`SELECT p.id, p.idUsuario, p.valor, p.frecuencia, p.fechaInicio, p.fechaFin, p.idActor, p.idAPIKey, p.creado
+		FROM planes_aporte p WHERE `+where+` ORDER BY p.idUsuario, p.fechaInicio`, args...)
+	if err != nil {
+		return planes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		p := &PlanAporte{}
+		var (
+			fin        sql.NullTime
+			actor, key sql.NullInt64
+		)
+		err = rows.Scan(&p.ID, &p.IDUsuario, &p.Valor, &p.Frecuencia, &p.FechaInicio, &fin, &actor, &key, &p.Creado)
+		if err != nil {
+			return planes, err
+		}
+		p.Actor = scanActor(actor, key)
+		if fin.Valid {
+			p.FechaFin = &fin.Time
+		}
+
+		planes = append(planes, p)
+	}
+
+	return planes, rows.Err()
+}
+
+func getAportesPagados(q querier, where string, args ...interface{}) ([]aporteFecha, error) {
+	aportes := []aporteFecha{}
+	rows, err := q.Query("SELECT a.idUsuario, a.fecha, "+aportePagado+" FROM aportes a WHERE "+where, args...)
+	if err != nil {
+		return aportes, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		a := aporteFecha{}
+		err = rows.Scan(&a.idUsuario, &a.fecha, &a.valor)
+		if err != nil {
+			return aportes, err
+		}
+		a.fecha = time.Date(a.fecha.Year(), a.fecha.Month(), a.fecha.Day(), 0, 0, 0, 0, time.UTC)
+
+		aportes = append(aportes, a)
+	}
+
+	return aportes, rows.Err()
+}
+
+// lockMiembroActivo locks the membership of an user in the fondo until the transaction ends
+func lockMiembroActivo(tx *sql.Tx, idFondo int, idUsuario int) error {
+	var activo bool
+	err := tx.QueryRow("SELECT activo FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ? FOR UPDATE", idFondo, idUsuario).Scan(&activo)
+	if err == sql.ErrNoRows {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if !activo {
+		return ErrMiembroInactivo
+	}
+
+	return nil
+}
+
+// hoy returns the current date, dates of the database are read as UTC days
+func hoy() time.Time {
+	now := time.Now()
+
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
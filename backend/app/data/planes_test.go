@@ -0,0 +1,250 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+// dia parses a YYYY-MM-DD date of the tests
+func dia(s string) time.Time {
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t
+}
+
+// diaFin parses the fechaFin of a plan of the tests
+func diaFin(s string) *time.Time {
+	t := dia(s)
+
+	return &t
+}
+
+func TestSumarMeses(t *testing.T) {
+	tests := []struct {
+		fecha string
+		meses int
+		want  string
+	}{
+		{"2021-01-15", 1, "2021-02-15"},
+		{"2021-01-31", 0, "2021-01-31"},
+		{"2021-01-31", 1, "2021-02-28"},
+		{"2021-01-31", 2, "2021-03-31"},
+		{"2021-01-31", 3, "2021-04-30"},
+		{"2024-01-31", 1, "2024-02-29"},
+		{"2021-03-30", 11, "2022-02-28"},
+		{"2021-12-31", 2, "2022-02-28"},
+		{"2021-08-31", 6, "2022-02-28"},
+		{"2020-02-29", 12, "2021-02-28"},
+	}
+
+	for _, tt := range tests {
+		if got := sumarMeses(dia(tt.fecha), tt.meses); !got.Equal(dia(tt.want)) {
+			t.Errorf("sumarMeses(%s, %d) = %s, want %s", tt.fecha, tt.meses, got.Format("2006-01-02"), tt.want)
+		}
+	}
+}
+
+func TestSolapaPlanes(t *testing.T) {
+	consecutivos := PlanesAporte{
+		{FechaInicio: dia("2021-01-01"), FechaFin: diaFin("2021-02-28")},
+		{FechaInicio: dia("2021-03-01")},
+	}
+	terminado := PlanesAporte{
+		{FechaInicio: dia("2021-01-01"), FechaFin: diaFin("2021-06-30")},
+	}
+
+	tests := []struct {
+		name   string
+		planes PlanesAporte
+		inicio string
+		want   bool
+	}{
+		{"no planes", PlanesAporte{}, "2021-01-01", false},
+		{"after the current plan starts", consecutivos, "2021-03-02", false},
+		{"same day as the current plan", consecutivos, "2021-03-01", true},
+		{"before the current plan", consecutivos, "2021-02-15", true},
+		{"inside an ended plan", terminado, "2021-03-01", true},
+		{"on the last day of an ended plan", terminado, "2021-06-30", true},
+		{"after an ended plan", terminado, "2021-07-01", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := solapaPlanes(tt.planes, dia(tt.inicio)); got != tt.want {
+				t.Errorf("solapaPlanes(%s) = %v, want %v", tt.inicio, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalcularEstado(t *testing.T) {
+	type periodo struct {
+		inicio, fin string
+		pagado      int
+		saldo       int
+		vencido     bool
+	}
+
+	tests := []struct {
+		name     string
+		planes   PlanesAporte
+		aportes  []aporteFecha
+		hoy      string
+		periodos []periodo
+		esperado int
+		pagado   int
+		atraso   int
+		atrasos  int
+	}{
+		{
+			name:    "mensual behind one period",
+			planes:  PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-15")}},
+			aportes: []aporteFecha{{fecha: dia("2021-01-20"), valor: 100}, {fecha: dia("2021-02-16"), valor: 100}},
+			hoy:     "2021-04-20",
+			periodos: []periodo{
+				{"2021-01-15", "2021-02-14", 100, 0, true},
+				{"2021-02-15", "2021-03-14", 100, 0, true},
+				{"2021-03-15", "2021-04-14", 0, -100, true},
+				{"2021-04-15", "2021-05-14", 0, -200, false},
+			},
+			esperado: 300, pagado: 200, atraso: 100, atrasos: 1,
+		},
+		{
+			name:    "plan starting at month end keeps a period in february",
+			planes:  PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-31")}},
+			aportes: []aporteFecha{{fecha: dia("2021-01-31"), valor: 100}, {fecha: dia("2021-02-28"), valor: 100}, {fecha: dia("2021-03-31"), valor: 100}},
+			hoy:     "2021-05-15",
+			periodos: []periodo{
+				{"2021-01-31", "2021-02-27", 100, 0, true},
+				{"2021-02-28", "2021-03-30", 100, 0, true},
+				{"2021-03-31", "2021-04-29", 100, 0, true},
+				{"2021-04-30", "2021-05-30", 0, -100, false},
+			},
+			esperado: 300, pagado: 300,
+		},
+		{
+			name:   "month end on a leap year",
+			planes: PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2024-01-30")}},
+			hoy:    "2024-03-01",
+			periodos: []periodo{
+				{"2024-01-30", "2024-02-28", 0, -100, true},
+				{"2024-02-29", "2024-03-29", 0, -200, false},
+			},
+			esperado: 100, atraso: 100, atrasos: 1,
+		},
+		{
+			name:   "trimestral starting at month end",
+			planes: PlanesAporte{{Valor: 300, Frecuencia: "trimestral", FechaInicio: dia("2020-11-30")}},
+			hoy:    "2021-06-01",
+			aportes: []aporteFecha{
+				{fecha: dia("2020-12-01"), valor: 300},
+				{fecha: dia("2021-02-28"), valor: 300},
+				{fecha: dia("2021-05-29"), valor: 300},
+			},
+			periodos: []periodo{
+				{"2020-11-30", "2021-02-27", 300, 0, true},
+				{"2021-02-28", "2021-05-29", 600, 300, true},
+				{"2021-05-30", "2021-08-29", 0, 0, false},
+			},
+			esperado: 600, pagado: 900,
+		},
+		{
+			name: "consecutive planes don't overlap",
+			planes: PlanesAporte{
+				{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-01"), FechaFin: diaFin("2021-02-28")},
+				{Valor: 200, Frecuencia: "mensual", FechaInicio: dia("2021-03-01")},
+			},
+			aportes: []aporteFecha{
+				{fecha: dia("2021-01-05"), valor: 100},
+				{fecha: dia("2021-02-28"), valor: 100},
+				{fecha: dia("2021-03-01"), valor: 100},
+			},
+			hoy: "2021-04-15",
+			periodos: []periodo{
+				{"2021-01-01", "2021-01-31", 100, 0, true},
+				{"2021-02-01", "2021-02-28", 100, 0, true},
+				{"2021-03-01", "2021-03-31", 100, -100, true},
+				{"2021-04-01", "2021-04-30", 0, -300, false},
+			},
+			esperado: 400, pagado: 300, atraso: 100, atrasos: 1,
+		},
+		{
+			name: "plan ended in the middle of a period",
+			planes: PlanesAporte{
+				{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-01"), FechaFin: diaFin("2021-02-10")},
+			},
+			aportes: []aporteFecha{
+				{fecha: dia("2020-12-31"), valor: 100},
+				{fecha: dia("2021-02-11"), valor: 100},
+			},
+			hoy: "2021-04-15",
+			periodos: []periodo{
+				{"2021-01-01", "2021-01-31", 0, -100, true},
+				{"2021-02-01", "2021-02-10", 0, -200, true},
+			},
+			esperado: 200, atraso: 200, atrasos: 2,
+		},
+		{
+			name:     "plan that has not started",
+			planes:   PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-05-01")}},
+			hoy:      "2021-04-15",
+			periodos: []periodo{},
+		},
+		{
+			name:    "part of an aporte rounds up the periods behind",
+			planes:  PlanesAporte{{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-01")}},
+			aportes: []aporteFecha{{fecha: dia("2021-01-10"), valor: 50}},
+			hoy:     "2021-03-01",
+			periodos: []periodo{
+				{"2021-01-01", "2021-01-31", 50, -50, true},
+				{"2021-02-01", "2021-02-28", 0, -150, true},
+				{"2021-03-01", "2021-03-31", 0, -250, false},
+			},
+			esperado: 200, pagado: 50, atraso: 150, atrasos: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := calcularEstado(1, tt.planes, tt.aportes, dia(tt.hoy))
+
+			if len(e.Periodos) != len(tt.periodos) {
+				t.Fatalf("calcularEstado periodos = %d, want %d", len(e.Periodos), len(tt.periodos))
+			}
+			for i, want := range tt.periodos {
+				p := e.Periodos[i]
+				if !p.Inicio.Equal(dia(want.inicio)) || !p.Fin.Equal(dia(want.fin)) {
+					t.Errorf("periodo %d = %s to %s, want %s to %s", i, p.Inicio.Format("2006-01-02"), p.Fin.Format("2006-01-02"), want.inicio, want.fin)
+				}
+				if p.Pagado != want.pagado || p.Saldo != want.saldo || p.Vencido != want.vencido {
+					t.Errorf("periodo %d pagado, saldo, vencido = %d, %d, %v, want %d, %d, %v", i, p.Pagado, p.Saldo, p.Vencido, want.pagado, want.saldo, want.vencido)
+				}
+			}
+
+			if e.Esperado != tt.esperado || e.Pagado != tt.pagado || e.Atraso != tt.atraso || e.PeriodosAtrasados != tt.atrasos {
+				t.Errorf("calcularEstado esperado, pagado, atraso, periodos atrasados = %d, %d, %d, %d, want %d, %d, %d, %d",
+					e.Esperado, e.Pagado, e.Atraso, e.PeriodosAtrasados, tt.esperado, tt.pagado, tt.atraso, tt.atrasos)
+			}
+		})
+	}
+}
+
+func TestCalcularEstadoPlan(t *testing.T) {
+	actual := &PlanAporte{Valor: 200, Frecuencia: "mensual", FechaInicio: dia("2021-03-01")}
+	planes := PlanesAporte{
+		{Valor: 100, Frecuencia: "mensual", FechaInicio: dia("2021-01-01"), FechaFin: diaFin("2021-02-28")},
+		actual,
+	}
+
+	if e := calcularEstado(1, planes, nil, dia("2021-04-15")); e.Plan != actual {
+		t.Errorf("calcularEstado plan = %+v, want the current plan", e.Plan)
+	}
+
+	// a member whose planes ended has no current plan
+	if e := calcularEstado(1, planes[:1], nil, dia("2021-04-15")); e.Plan != nil || e.PeriodosAtrasados != 2 {
+		t.Errorf("calcularEstado of ended planes = plan %+v and %d periodos atrasados, want no plan and 2", e.Plan, e.PeriodosAtrasados)
+	}
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrAgrupacion is raised when the summary is grouped by an unknown period
+var ErrAgrupacion = fmt.Errorf("group must be month, quarter or year")
+
+// ErrRangoFechas is raised when the dates of a summary are not YYYY-MM-DD dates
+var ErrRangoFechas = fmt.Errorf("startDate and endDate must be YYYY-MM-DD dates")
+
+// agrupaciones are the SQL expressions of the periods of the summaries, %[1]s is the date. The
+// periods sort as text so they can be compared with the period of the dates of the range
+var agrupaciones = map[string]string{
+	"month":   "DATE_FORMAT(%[1]s, '%%Y-%%m')",
+	"quarter": "CONCAT(YEAR(%[1]s), '-Q', QUARTER(%[1]s))",
+	"year":    "CAST(YEAR(%[1]s) AS CHAR)",
+}
+
+// ResumenAportes is what was contributed on a period, Aportes counts the corrections and reversals
+// of the aportes but not the descuentos taken later from them. Acumulado is the running balance
+// since the first aporte, including the periods before the range
+type ResumenAportes struct {
+	Periodo   string `json:"periodo"`
+	Aportes   int    `json:"aportes"`
+	Cantidad  int    `json:"cantidad"`
+	Miembros  int    `json:"miembros"`
+	Acumulado int    `json:"acumulado"`
+}
+
+// ResumenesAportes is a list of ResumenAportes
+type ResumenesAportes []*ResumenAportes
+
+// GetResumenAportes sums the aportes of the fondo, or of a member when idUsuario is not 0, by month,
+// quarter or year. The range takes the whole periods of its dates and either date can be empty
+func (u *UserService) GetResumenAportes(idFondo int, idUsuario int, group string, startDate string, endDate string) (ResumenesAportes, error) {
+	u.l.Info("[GetResumenAportes] Getting resumen of aportes", "fondo", idFondo, "user", idUsuario, "group", group, "startDate", startDate, "endDate", endDate)
+
+	resumen := ResumenesAportes{}
+	agrupacion, ok := agrupaciones[group]
+	if !ok {
+		return resumen, ErrAgrupacion
+	}
+	for _, f := range []string{startDate, endDate} {
+		if _, err := time.Parse("2006-01-02", f); f != "" && err != nil {
+			return resumen, ErrRangoFechas
+		}
+	}
+
+	periodo := func(fecha string) string {
+		return fmt.Sprintf(agrupacion, fecha)
+	}
+	// the period of a date of the range uses it once for each placeholder
+	periodoFecha := func(fecha string) (string, []interface{}) {
+		p := periodo("?")
+		args := []interface{}{}
+		for i := 0; i < strings.Count(p, "?"); i++ {
+			args = append(args, fecha)
+		}
+		return p, args
+	}
+
+	where := "a.idFondo = ?"
+	args := []interface{}{idFondo}
+	if idUsuario != 0 {
+		_, err := u.UserExists(idFondo, idUsuario)
+		if err != nil {
+			return resumen, err
+		}
+
+		where += " AND a.idUsuario = ?"
+		args = append(args, idUsuario)
+	}
+	if endDate != "" {
+		p, a := periodoFecha(endDate)
+		where += " AND " + periodo("a.fecha") + " <= " + p
+		args = append(args, a...)
+	}
+
+	desde := ""
+	if startDate != "" {
+		p, a := periodoFecha(startDate)
+		desde = " WHERE r.periodo >= " + p
+		args = append(args, a...)
+	}
+
+	// the running balance is computed over every period up to the end of the range, the
+	// periods before its start are dropped afterwards
+	rows, err := u.DB.Query(`SELECT r.periodo, r.aportes, r.cantidad, r.miembros, r.acumulado FROM (
+		SELECT `+periodo("a.fecha")+` AS periodo,
+			COALESCE(SUM(`+aportePagado+`), 0) AS aportes,
+			COUNT(*) AS cantidad,
+			COUNT(DISTINCT a.idUsuario) AS miembros,
+			SUM(SUM(`+aportePagado+`)) OVER (ORDER BY `+periodo("a.fecha")+`) AS acumulado
+		FROM aportes a WHERE `+where+`
+		GROUP BY periodo) r`+desde+` ORDER BY r.periodo`, args...)
+	if err != nil {
+		return resumen, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		r := &ResumenAportes{}
+		err = rows.Scan(&r.Periodo, &r.Aportes, &r.Cantidad, &r.Miembros, &r.Acumulado)
+		if err != nil {
+			return resumen, err
+		}
+
+		resumen = append(resumen, r)
+	}
+
+	return resumen, rows.Err()
+}
@@ -0,0 +1,81 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// resumenColumns are the columns read by GetResumenAportes
+var resumenColumns = []string{"periodo", "aportes", "cantidad", "miembros", "acumulado"}
+
+func TestGetResumenAportes(t *testing.T) {
+	tests := []struct {
+		name    string
+		group   string
+		start   string
+		end     string
+		periodo string
+		args    []driver.Value
+	}{
+		{"month of the whole fondo", "month", "", "", "DATE_FORMAT\\(a.fecha, '%Y-%m'\\)", []driver.Value{2}},
+		// every placeholder of the period of a date takes the date
+		{"quarter in a range", "quarter", "2021-02-10", "2021-12-31", "CONCAT\\(YEAR\\(a.fecha\\), '-Q', QUARTER\\(a.fecha\\)\\)",
+			[]driver.Value{2, "2021-12-31", "2021-12-31", "2021-02-10", "2021-02-10"}},
+		{"year from a date", "year", "2020-06-01", "", "CAST\\(YEAR\\(a.fecha\\) AS CHAR\\)", []driver.Value{2, "2020-06-01"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT " + tt.periodo + " AS periodo").WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows(resumenColumns).AddRow("2021-Q1", 300, 3, 2, 1300).AddRow("2021-Q2", 100, 1, 1, 1400))
+
+			resumen, err := s.GetResumenAportes(2, 0, tt.group, tt.start, tt.end)
+			if err != nil {
+				t.Fatalf("GetResumenAportes error = %v", err)
+			}
+			if len(resumen) != 2 || *resumen[1] != (ResumenAportes{Periodo: "2021-Q2", Aportes: 100, Cantidad: 1, Miembros: 1, Acumulado: 1400}) {
+				t.Errorf("GetResumenAportes = %d periodos, last %+v, want 2 ending in 2021-Q2", len(resumen), resumen[len(resumen)-1])
+			}
+		})
+	}
+}
+
+func TestGetResumenAportesOfMember(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT idUsuario from fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+	mock.ExpectQuery("FROM aportes a WHERE a.idFondo = \\? AND a.idUsuario = \\?").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows(resumenColumns))
+
+	resumen, err := s.GetResumenAportes(2, 7, "month", "", "")
+	if err != nil || len(resumen) != 0 {
+		t.Errorf("GetResumenAportes = %v, %v, want an empty resumen", resumen, err)
+	}
+}
+
+func TestGetResumenAportesInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		group string
+		start string
+		end   string
+		err   error
+	}{
+		{"unknown group", "week", "", "", ErrAgrupacion},
+		{"start that is not a date", "month", "01/02/2021", "", ErrRangoFechas},
+		{"end that is not a date", "year", "", "2021-13-01", ErrRangoFechas},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newMockService(t)
+
+			if _, err := s.GetResumenAportes(2, 0, tt.group, tt.start, tt.end); err != tt.err {
+				t.Errorf("GetResumenAportes error = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
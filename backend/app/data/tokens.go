@@ -0,0 +1,54 @@
+package data
+
+// IsTokenRevoked returns true if the jti of an access token was revoked by the authentication api
+func (u *UserService) IsTokenRevoked(jti string) (bool, error) {
+	rows, err := u.DB.Query("SELECT jti FROM revoked_tokens WHERE jti = ?", jti)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// IsSessionRevoked returns true if the session of the given familia (sid claim) was revoked
+func (u *UserService) IsSessionRevoked(sid string) (bool, error) {
+	rows, err := u.DB.Query("SELECT familia FROM sesiones WHERE familia = ? AND revocada = 1", sid)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// EstadoAprobado is the estado of the users approved by an administrator
+const EstadoAprobado = "aprobado"
+
+// TokenState is the current token version of an user and of its membership to the fondo of the token,
+// its approval estado in the fondo and whether it is still an active member of it
+type TokenState struct {
+	Version       int
+	FondoVersion  int
+	Estado        string
+	MiembroActivo bool
+}
+
+// GetTokenState returns the token versions of an user, tokens issued with other versions are not accepted
+func (u *UserService) GetTokenState(id int, idFondo int) (TokenState, error) {
+	t := TokenState{}
+	rows, err := u.DB.Query(`SELECT u.tokenVersion, COALESCE(fu.tokenVersion, 0), COALESCE(fu.estado, ''), COALESCE(fu.activo, 0) FROM usuario u
+		LEFT JOIN fondo_usuario fu ON fu.idUsuario = u.id AND fu.idFondo = ? WHERE u.id = ?`, idFondo, id)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&t.Version, &t.FondoVersion, &t.Estado, &t.MiembroActivo)
+
+		return t, err
+	}
+
+	return t, ErrUserNotFound
+}
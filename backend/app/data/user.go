@@ -13,11 +13,38 @@ var ErrUserNotFound = fmt.Errorf("User not found")
 // ErrCreditNotFound is raised when a user is not found
 var ErrCreditNotFound = fmt.Errorf("Credit not found")
 
-// User describes a user
+// User describes a user, Rol is its rol in the active Fondo and Permisos the permissions of that rol.
+// Requests made with an API key have no ID nor Rol, APIKey holds the id of the key and Permisos its scopes
 type User struct {
-	ID    int
-	Rol   int
-	Email string
+	ID       int
+	Rol      int
+	Email    string
+	Fondo    int
+	APIKey   int
+	Permisos []string
+}
+
+// Actor is who made a change to the fondo, an user or, for the requests made with an API key, the key.
+// Only one of them is set, the other one is stored as NULL
+type Actor struct {
+	IDActor  int `json:"idActor,omitempty"`
+	IDAPIKey int `json:"idApiKey,omitempty"`
+}
+
+// Actor returns who makes the changes of the request
+func (u *User) Actor() Actor {
+	return Actor{IDActor: u.ID, IDAPIKey: u.APIKey}
+}
+
+// values returns the idActor and idAPIKey columns of the Actor
+func (a Actor) values() (sql.NullInt64, sql.NullInt64) {
+	return sql.NullInt64{Int64: int64(a.IDActor), Valid: a.IDActor != 0},
+		sql.NullInt64{Int64: int64(a.IDAPIKey), Valid: a.IDAPIKey != 0}
+}
+
+// scanActor returns the Actor stored on the idActor and idAPIKey columns
+func scanActor(actor sql.NullInt64, key sql.NullInt64) Actor {
+	return Actor{IDActor: int(actor.Int64), IDAPIKey: int(key.Int64)}
 }
 
 // UserService does
@@ -31,13 +58,15 @@ func NewUserService(db *sql.DB, l hclog.Logger) *UserService {
 	return &UserService{db, l}
 }
 
-// UserExists return true if an specific user id exists
-func (u *UserService) UserExists(id int) (bool, error) {
-	rows, err := u.DB.Query("SELECT * from usuario where id = ?", id)
+// UserExists return true if an specific user id exists and belongs to the fondo
+func (u *UserService) UserExists(idFondo int, id int) (bool, error) {
+	rows, err := u.DB.Query("SELECT idUsuario from fondo_usuario where idFondo = ? AND idUsuario = ?", idFondo, id)
 	if err != nil {
 		return false, ErrUserNotFound
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		return true, nil
 	}
@@ -45,13 +74,33 @@ func (u *UserService) UserExists(id int) (bool, error) {
 	return false, ErrUserNotFound
 }
 
-// CreditExists return true if an specific credit id exists
-func (u *UserService) CreditExists(id int) (bool, error) {
-	rows, err := u.DB.Query("SELECT * from creditos where id = ?", id)
+// ActiveUserExists returns true if an specific user id is an active member of the fondo,
+// members that left the fondo through a liquidacion can't get new aportes nor creditos
+func (u *UserService) ActiveUserExists(idFondo int, id int) (bool, error) {
+	var activo bool
+	err := u.DB.QueryRow("SELECT activo FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ?", idFondo, id).Scan(&activo)
+	if err == sql.ErrNoRows {
+		return false, ErrUserNotFound
+	}
+	if err != nil {
+		return false, err
+	}
+	if !activo {
+		return false, ErrMiembroInactivo
+	}
+
+	return true, nil
+}
+
+// CreditExists return true if an specific credit id exists in the fondo
+func (u *UserService) CreditExists(idFondo int, id int) (bool, error) {
+	rows, err := u.DB.Query("SELECT id from creditos where idFondo = ? AND id = ?", idFondo, id)
 	if err != nil {
 		return false, ErrCreditNotFound
 	}
 
+	defer rows.Close()
+
 	for rows.Next() {
 		return true, nil
 	}
@@ -0,0 +1,90 @@
+package data
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hashicorp/go-hclog"
+)
+
+// newMockService returns an UserService on a mocked database, the expectations are checked when the test ends
+func newMockService(t *testing.T) (*UserService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	return NewUserService(db, hclog.NewNullLogger()), mock
+}
+
+func TestActor(t *testing.T) {
+	tests := []struct {
+		name     string
+		user     User
+		idActor  sql.NullInt64
+		idAPIKey sql.NullInt64
+	}{
+		{"user", User{ID: 7, Rol: 1, Fondo: 2}, sql.NullInt64{Int64: 7, Valid: true}, sql.NullInt64{}},
+		{"API key", User{Fondo: 2, APIKey: 3}, sql.NullInt64{}, sql.NullInt64{Int64: 3, Valid: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := tt.user.Actor()
+			idActor, idAPIKey := actor.values()
+			if idActor != tt.idActor || idAPIKey != tt.idAPIKey {
+				t.Errorf("values = %+v, %+v, want %+v, %+v", idActor, idAPIKey, tt.idActor, tt.idAPIKey)
+			}
+
+			if back := scanActor(idActor, idAPIKey); back != actor {
+				t.Errorf("scanActor = %+v, want %+v", back, actor)
+			}
+		})
+	}
+}
+
+func TestInsertAjusteAPIKey(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO aportes_ajustes \\(idAporte, valor, tipo, motivo, idActor, idAPIKey, fecha\\)").
+		WithArgs(5, -100, AjusteDescuento, "Descuento", nil, 3, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	// the ajuste made with an API key is attributed to the key
+	user := User{Fondo: 2, APIKey: 3}
+	if err := insertAjuste(tx, 5, -100, AjusteDescuento, "Descuento", user.Actor(), hoy()); err != nil {
+		t.Errorf("insertAjuste error = %v", err)
+	}
+}
+
+func TestUserExistsOfFondo(t *testing.T) {
+	s, mock := newMockService(t)
+	// a member of another fondo is not found
+	mock.ExpectQuery("SELECT idUsuario from fondo_usuario where idFondo = \\? AND idUsuario = \\?").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}))
+	mock.ExpectQuery("SELECT idUsuario from fondo_usuario where idFondo = \\? AND idUsuario = \\?").WithArgs(5, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+
+	if exists, err := s.UserExists(2, 7); exists || err != ErrUserNotFound {
+		t.Errorf("UserExists(2, 7) = %v, %v, want false, %v", exists, err, ErrUserNotFound)
+	}
+	if exists, err := s.UserExists(5, 7); !exists || err != nil {
+		t.Errorf("UserExists(5, 7) = %v, %v, want true", exists, err)
+	}
+}
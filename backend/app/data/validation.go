@@ -43,6 +43,8 @@ type Validation struct {
 func NewValidation() *Validation {
 	validate := validator.New()
 	//validate.RegisterValidation("sku", validateSKU)
+	validate.RegisterValidation("fecha", validateFecha)
+	validate.RegisterValidation("dia", validateDia)
 
 	return &Validation{validate}
 }
@@ -0,0 +1,156 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// xlsxStrings is xl/sharedStrings.xml, text cells keep an index to one of its items
+type xlsxStrings struct {
+	Items []xlsxText `xml:"si"`
+}
+
+// xlsxText is the text of a cell, rich text is split in runs
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+
+	s := ""
+	for _, r := range t.Runs {
+		s += r.T
+	}
+
+	return s
+}
+
+// xlsxSheet is a worksheet, empty cells are not stored so cells are placed by their reference
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the cells of the first worksheet of an XLSX file as text
+func readXLSX(b []byte) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, ErrImportacionFormato
+	}
+
+	files := map[string]*zip.File{}
+	sheets := []string{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+		if strings.HasPrefix(f.Name, "xl/worksheets/") && strings.HasSuffix(f.Name, ".xml") {
+			sheets = append(sheets, f.Name)
+		}
+	}
+	if len(sheets) == 0 {
+		return nil, ErrImportacionFormato
+	}
+	sort.Strings(sheets)
+	sheet := sheets[0]
+	if _, ok := files["xl/worksheets/sheet1.xml"]; ok {
+		sheet = "xl/worksheets/sheet1.xml"
+	}
+
+	shared := xlsxStrings{}
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		err = readXML(f, &shared)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	ws := xlsxSheet{}
+	err = readXML(files[sheet], &ws)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := [][]string{}
+	for _, r := range ws.Rows {
+		row := []string{}
+		for i, c := range r.Cells {
+			col := xlsxColumn(c.Ref)
+			if col < 0 {
+				col = i
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err != nil || n < 0 || n >= len(shared.Items) {
+					return nil, ErrImportacionFormato
+				}
+				row[col] = shared.Items[n].String()
+			case "inlineStr":
+				row[col] = c.Inline.String()
+			default:
+				row[col] = c.Value
+			}
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+func readXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return ErrImportacionFormato
+	}
+	defer rc.Close()
+
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return ErrImportacionFormato
+	}
+
+	if xml.Unmarshal(b, v) != nil {
+		return ErrImportacionFormato
+	}
+
+	return nil
+}
+
+// xlsxColumn returns the index of the column of a cell reference like AB12, or -1 without a reference
+func xlsxColumn(ref string) int {
+	col := 0
+	n := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		col = col*26 + int(c-'A') + 1
+		n++
+	}
+	if n == 0 {
+		return -1
+	}
+
+	return col - 1
+}
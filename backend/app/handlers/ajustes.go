@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+// CreateAjusteAporte handles the request to correct or revert an aporte, the aporte itself is not changed
+func (h *UsersHandler) CreateAjusteAporte(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	var a = (context.Get(r, "aj")).(*data.AjusteCreate)
+	id := getID(r)
+
+	h.l.Info("[CreateAjusteAporte] Recieving call to adjust aporte", "aporte", id, "actor", us.ID)
+	ap, err := h.UserService.CreateAjusteAporte(us.Fondo, id, a, us.Actor())
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		data.ToJSON(&ap, w)
+		return
+	case data.ErrAporteNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case data.ErrMiembroInactivo:
+		w.WriteHeader(http.StatusConflict)
+	case data.ErrAjusteSinValor, data.ErrAjusteNegativo, data.ErrAporteRevertido:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		h.l.Error("[CreateAjusteAporte] Error adjusting aporte", "aporte", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	data.ToJSON(&GenericError{Message: err.Error()}, w)
+}
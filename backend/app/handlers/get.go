@@ -12,7 +12,7 @@ func (h *UsersHandler) GetAllAportes(w http.ResponseWriter, r *http.Request) {
 	var us = (context.Get(r, "us")).(data.User)
 
 	h.l.Info("[GetAllAportes] Recieving call to get all aportes from", "user", us)
-	aportes, err := h.UserService.GetAllAportes()
+	aportes, err := h.UserService.GetAllAportes(us.Fondo)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -26,7 +26,7 @@ func (h *UsersHandler) GetAllCreditos(w http.ResponseWriter, r *http.Request) {
 	var us = (context.Get(r, "us")).(data.User)
 
 	h.l.Info("[GetAllCreditos] Recieving call to get all creditos from", "user", us)
-	creditos, err := h.UserService.GetAllCreditos()
+	creditos, err := h.UserService.GetAllCreditos(us.Fondo)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -40,7 +40,7 @@ func (h *UsersHandler) GetReporteGeneral(w http.ResponseWriter, r *http.Request)
 	var us = (context.Get(r, "us")).(data.User)
 
 	h.l.Info("[GetReporteGeneral] Recieving call to get a general report from ", "user", us)
-	reporte, err := h.UserService.GetReporteGeneral()
+	reporte, err := h.UserService.GetReporteGeneral(us.Fondo)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -55,7 +55,7 @@ func (h *UsersHandler) GetAllCreditosByUserID(w http.ResponseWriter, r *http.Req
 	id := getID(r)
 
 	h.l.Info("[GetAllCreditosByUserID] Recieving request to get all credits from", "user", us)
-	creditos, err := h.UserService.GetAllCreditosByUserID(id)
+	creditos, err := h.UserService.GetAllCreditosByUserID(us.Fondo, id)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -73,7 +73,7 @@ func (h *UsersHandler) GetAllAportesByID(w http.ResponseWriter, r *http.Request)
 	startDate := r.URL.Query().Get("startDate")
 	endDate := r.URL.Query().Get("endDate")
 
-	aportes, err := h.UserService.GetAllAportesByID(id, startDate, endDate)
+	aportes, err := h.UserService.GetAllAportesByID(us.Fondo, id, startDate, endDate)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -88,7 +88,7 @@ func (h *UsersHandler) GetSumAportesByID(w http.ResponseWriter, r *http.Request)
 	id := getID(r)
 
 	h.l.Info("[GetSumAportesByID] Recieving call to get sum of aportes from", "user", us)
-	aportes, err := h.UserService.GetSumAportesByID(id)
+	aportes, err := h.UserService.GetSumAportesByID(us.Fondo, id)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+// maxImportacion is the largest file accepted by an importacion
+const maxImportacion = 5 << 20
+
+// PreviewImportacion checks every row of an uploaded CSV or XLSX file without importing it
+func (h *UsersHandler) PreviewImportacion(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	imp, ok := h.readImportacion(w, r)
+	if !ok {
+		return
+	}
+
+	h.l.Info("[PreviewImportacion] Recieving call to check importacion", "nombre", imp.Nombre, "actor", us.ID)
+	err := h.UserService.PreviewImportacion(us.Fondo, &imp)
+	if err != nil {
+		h.l.Error("[PreviewImportacion] Error checking importacion", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	data.ToJSON(&imp, w)
+}
+
+// CreateImportacion imports every row of an uploaded CSV or XLSX file, or none of them if a row has
+// errors. Uploading a file that was already imported returns the first importacion and the rows
+// already imported by other files are skipped
+func (h *UsersHandler) CreateImportacion(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	imp, ok := h.readImportacion(w, r)
+	if !ok {
+		return
+	}
+
+	h.l.Info("[CreateImportacion] Recieving call to import", "nombre", imp.Nombre, "actor", us.ID)
+	res, err := h.UserService.CreateImportacion(us.Fondo, &imp, us.Actor())
+	switch {
+	case err == data.ErrImportacionConErrores:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	case err != nil:
+		h.l.Error("[CreateImportacion] Error importing", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	case !res.Importada:
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	data.ToJSON(&res, w)
+}
+
+// GetImportaciones returns the importaciones of the fondo
+func (h *UsersHandler) GetImportaciones(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[GetImportaciones] Recieving call to get the importaciones from", "user", us)
+	importaciones, err := h.UserService.GetImportaciones(us.Fondo)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	data.ToJSON(&importaciones, w)
+}
+
+// readImportacion reads the file sent on the archivo field of a multipart form and validates each
+// of its rows, it writes the error and returns false when the file can't be read
+func (h *UsersHandler) readImportacion(w http.ResponseWriter, r *http.Request) (data.Importacion, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportacion)
+
+	f, fh, err := r.FormFile("archivo")
+	if err != nil {
+		h.l.Error("[readImportacion] Reading archivo", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: "The file must be sent on the archivo field of a multipart form of up to 5MB"}, w)
+		return data.Importacion{}, false
+	}
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return data.Importacion{}, false
+	}
+
+	imp, err := data.ReadImportacion(fh.Filename, b)
+	if err != nil {
+		h.l.Error("[readImportacion] Reading importacion", "nombre", fh.Filename, "error", err)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return imp, false
+	}
+
+	for _, fila := range imp.Filas {
+		errs := h.v.Validate(fila)
+		if len(errs) != 0 {
+			fila.Errores = errs.Errors()
+		}
+	}
+
+	return imp, true
+}
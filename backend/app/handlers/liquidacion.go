@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/context"
+)
+
+// GetLiquidacion returns the liquidacion quote of a member, the comision is an optional query parameter
+func (h *UsersHandler) GetLiquidacion(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	comision := 0
+	if c := r.URL.Query().Get("comision"); c != "" {
+		var err error
+		comision, err = strconv.Atoi(c)
+		if err != nil || comision < 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: "comision must be a positive number"}, w)
+			return
+		}
+	}
+
+	h.l.Info("[GetLiquidacion] Recieving call to quote the liquidacion of", "user", id, "actor", us.ID)
+	l, err := h.UserService.GetLiquidacion(us.Fondo, id, comision)
+	if err != nil {
+		h.writeLiquidacionError(w, err)
+		return
+	}
+
+	data.ToJSON(&l, w)
+}
+
+// CreateLiquidacion applies the liquidacion of a member and deactivates it in the fondo
+func (h *UsersHandler) CreateLiquidacion(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	var c = (context.Get(r, "liq")).(*data.LiquidacionConfirm)
+	id := getID(r)
+
+	h.l.Info("[CreateLiquidacion] Recieving call to apply the liquidacion of", "user", id, "actor", us.ID)
+	l, err := h.UserService.CreateLiquidacion(us.Fondo, id, c, us.Actor())
+	if err == data.ErrLiquidacionCambio {
+		// the client gets the new quote to confirm it again
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&l, w)
+		return
+	}
+	if err != nil {
+		h.writeLiquidacionError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&l, w)
+}
+
+// GetLiquidaciones returns the liquidaciones applied in the fondo
+func (h *UsersHandler) GetLiquidaciones(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[GetLiquidaciones] Recieving call to get the liquidaciones from", "user", us)
+	liquidaciones, err := h.UserService.GetLiquidaciones(us.Fondo)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	data.ToJSON(&liquidaciones, w)
+}
+
+func (h *UsersHandler) writeLiquidacionError(w http.ResponseWriter, err error) {
+	switch err {
+	case data.ErrUserNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case data.ErrMiembroInactivo:
+		w.WriteHeader(http.StatusConflict)
+	case data.ErrLiquidacionNegativa:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		h.l.Error("[writeLiquidacionError] Error with liquidacion", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	data.ToJSON(&GenericError{Message: err.Error()}, w)
+}
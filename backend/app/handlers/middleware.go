@@ -3,6 +3,7 @@ package handlers
 import (
 	"fondo-mod/data"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/context"
 )
@@ -125,15 +126,168 @@ func (h *UsersHandler) MiddlewareValidateCredito(next http.Handler) http.Handler
 	})
 }
 
-//MiddlewareCheckUserIDCall verifies that the id sent from the user is the same as the speciefied on the token
+//MiddlewareValidateLiquidacion  verificacion para los request
+func (h *UsersHandler) MiddlewareValidateLiquidacion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		liquidacion := &data.LiquidacionConfirm{}
+
+		err := data.FromJSON(liquidacion, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateLiquidacion] Deserializing liquidacion", "error", err)
+
+			rw.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, rw)
+			return
+		}
+		h.l.Debug("[MiddlewareValidateLiquidacion] Serialized liquidacion", "liquidacion", liquidacion)
+		errs := h.v.Validate(liquidacion)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateLiquidacion] Validating liquidacion", "errors:", errs)
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, rw)
+			return
+		}
+
+		// add the liquidacion to the context
+		context.Set(r, "liq", liquidacion)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
+
+//MiddlewareValidateAjuste  verificacion para los request
+func (h *UsersHandler) MiddlewareValidateAjuste(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		ajuste := &data.AjusteCreate{}
+
+		err := data.FromJSON(ajuste, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateAjuste] Deserializing ajuste", "error", err)
+
+			rw.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, rw)
+			return
+		}
+		h.l.Debug("[MiddlewareValidateAjuste] Serialized ajuste", "ajuste", ajuste)
+		errs := h.v.Validate(ajuste)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateAjuste] Validating ajuste", "errors:", errs)
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, rw)
+			return
+		}
+
+		// add the ajuste to the context
+		context.Set(r, "aj", ajuste)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
+
+//MiddlewareValidatePlan  verificacion para los request
+func (h *UsersHandler) MiddlewareValidatePlan(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		plan := &data.PlanAporteCreate{}
+
+		err := data.FromJSON(plan, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidatePlan] Deserializing plan", "error", err)
+
+			rw.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, rw)
+			return
+		}
+		h.l.Debug("[MiddlewareValidatePlan] Serialized plan", "plan", plan)
+		errs := h.v.Validate(plan)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidatePlan] Validating plan", "errors:", errs)
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, rw)
+			return
+		}
+
+		// add the plan to the context
+		context.Set(r, "plan", plan)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
+
+//MiddlewareValidateReglaMulta  verificacion para los request
+func (h *UsersHandler) MiddlewareValidateReglaMulta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		regla := &data.ReglaMultaCreate{}
+
+		err := data.FromJSON(regla, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateReglaMulta] Deserializing regla de multa", "error", err)
+
+			rw.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, rw)
+			return
+		}
+		h.l.Debug("[MiddlewareValidateReglaMulta] Serialized regla de multa", "regla", regla)
+		errs := h.v.Validate(regla)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateReglaMulta] Validating regla de multa", "errors:", errs)
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, rw)
+			return
+		}
+
+		// add the regla de multa to the context
+		context.Set(r, "rm", regla)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
+
+//MiddlewareValidatePagoMulta  verificacion para los request
+func (h *UsersHandler) MiddlewareValidatePagoMulta(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		pago := &data.PagoMultaCreate{}
+
+		err := data.FromJSON(pago, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidatePagoMulta] Deserializing pago de multa", "error", err)
+
+			rw.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, rw)
+			return
+		}
+		h.l.Debug("[MiddlewareValidatePagoMulta] Serialized pago de multa", "pago", pago)
+		errs := h.v.Validate(pago)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidatePagoMulta] Validating pago de multa", "errors:", errs)
+			rw.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, rw)
+			return
+		}
+
+		// add the pago de multa to the context
+		context.Set(r, "pm", pago)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(rw, r)
+	})
+}
+
+//MiddlewareCheckUserIDCall verifies that the id sent from the user is the same as the speciefied on the token,
+//users allowed to read the aportes, creditos or multas of every member can read those of another user
 func (h *UsersHandler) MiddlewareCheckUserIDCall(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		aporte := &data.Aporte{}
 		var us = (context.Get(r, "us")).(data.User)
 		id := getID(r)
 
-		if us.Rol != 1 {
-			if us.Rol != id {
+		perm := data.PermAportesRead
+		if strings.HasSuffix(r.URL.Path, "/creditos") {
+			perm = data.PermCreditosRead
+		} else if strings.HasSuffix(r.URL.Path, "/multas") {
+			perm = data.PermMultasRead
+		}
+
+		if !us.HasPermission(perm) {
+			if us.ID != id {
 				h.l.Error("User trying to access data from another user", "User Origin", us, "id", id)
 				rw.WriteHeader(http.StatusUnauthorized)
 				data.ToJSON(&ValidationError{Messages: []string{"User trying to access data from another user"}}, rw)
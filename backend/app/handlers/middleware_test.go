@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestMiddlewareCheckUserIDCall(t *testing.T) {
+	p := data.DefaultPolicy()
+	h := New(nil, hclog.NewNullLogger(), nil)
+
+	admin := data.User{ID: 1, Rol: 1, Fondo: 1, Permisos: p.Permissions(1)}
+	auditor := data.User{ID: 2, Rol: 2, Fondo: 1, Permisos: p.Permissions(2)}
+	miembro := data.User{ID: 3, Rol: 3, Fondo: 1, Permisos: p.Permissions(3)}
+	apiKey := data.User{Fondo: 1, APIKey: 7, Permisos: []string{data.PermCreditosRead}}
+
+	tests := []struct {
+		name   string
+		us     data.User
+		id     string
+		path   string
+		status int
+	}{
+		{"admin reads another member", admin, "3", "/usuarios/3/aportes", http.StatusOK},
+		{"auditor reads aportes of another member", auditor, "3", "/usuarios/3/aportes", http.StatusOK},
+		{"auditor reads creditos of another member", auditor, "3", "/usuarios/3/creditos", http.StatusOK},
+		{"auditor reads multas of another member", auditor, "3", "/usuarios/3/multas", http.StatusOK},
+		{"member reads its own aportes", miembro, "3", "/usuarios/3/aportes", http.StatusOK},
+		{"member can't read another member", miembro, "2", "/usuarios/2/aportes", http.StatusUnauthorized},
+		{"api key reads creditos", apiKey, "3", "/usuarios/3/creditos", http.StatusOK},
+		{"api key without the scope", apiKey, "3", "/usuarios/3/aportes", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r = mux.SetURLVars(r, map[string]string{"id": tt.id})
+			context.Set(r, "us", tt.us)
+			defer context.Clear(r)
+
+			rw := httptest.NewRecorder()
+			h.MiddlewareCheckUserIDCall(next).ServeHTTP(rw, r)
+			if rw.Code != tt.status {
+				t.Errorf("GET %s = %d, want %d", tt.path, rw.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/context"
+)
+
+// GetReglasMulta returns the reglas de multa of the fondo
+func (h *UsersHandler) GetReglasMulta(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[GetReglasMulta] Recieving call to get the reglas de multa from", "user", us)
+	reglas, err := h.UserService.GetReglasMulta(us.Fondo)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	data.ToJSON(&reglas, w)
+}
+
+// CreateReglaMulta creates a regla de multa in the fondo
+func (h *UsersHandler) CreateReglaMulta(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	var rm = (context.Get(r, "rm")).(*data.ReglaMultaCreate)
+
+	h.l.Info("[CreateReglaMulta] Recieving call to create a regla de multa from", "user", us)
+	regla, err := h.UserService.CreateReglaMulta(us.Fondo, rm, us.Actor())
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&regla, w)
+}
+
+// DeactivateReglaMulta stops a regla de multa from giving new multas
+func (h *UsersHandler) DeactivateReglaMulta(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[DeactivateReglaMulta] Recieving call to deactivate regla de multa", "regla", id, "actor", us.ID)
+	err := h.UserService.DeactivateReglaMulta(us.Fondo, id)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// EvaluarMultas fines the members of the fondo that did not pay the aportes of their planes on time,
+// it returns the new multas
+func (h *UsersHandler) EvaluarMultas(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[EvaluarMultas] Recieving call to evaluate the multas from", "user", us.ID, "key", us.APIKey)
+	multas, err := h.UserService.EvaluarMultas(us.Fondo, 0)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	data.ToJSON(&multas, w)
+}
+
+// GetMultas returns the multas of the fondo, ?pendientes=true only returns the ones owed
+func (h *UsersHandler) GetMultas(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	pendientes, _ := strconv.ParseBool(r.URL.Query().Get("pendientes"))
+
+	h.l.Info("[GetMultas] Recieving call to get the multas from", "user", us)
+	multas, err := h.UserService.GetMultas(us.Fondo, pendientes)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	data.ToJSON(&multas, w)
+}
+
+// GetMultasUsuario returns the multas of a member
+func (h *UsersHandler) GetMultasUsuario(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[GetMultasUsuario] Recieving call to get the multas of", "user", id, "actor", us.ID)
+	multas, err := h.UserService.GetMultasUsuario(us.Fondo, id)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	data.ToJSON(&multas, w)
+}
+
+// CreatePagoMulta pays a multa
+func (h *UsersHandler) CreatePagoMulta(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	var p = (context.Get(r, "pm")).(*data.PagoMultaCreate)
+	id := getID(r)
+
+	h.l.Info("[CreatePagoMulta] Recieving call to pay multa", "multa", id, "actor", us.ID)
+	m, err := h.UserService.CreatePagoMulta(us.Fondo, id, p, us.Actor())
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&m, w)
+}
+
+// AnularMulta waives a multa
+func (h *UsersHandler) AnularMulta(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[AnularMulta] Recieving call to waive multa", "multa", id, "actor", us.ID)
+	m, err := h.UserService.AnularMulta(us.Fondo, id)
+	if err != nil {
+		h.writeMultaError(w, err)
+		return
+	}
+
+	data.ToJSON(&m, w)
+}
+
+func (h *UsersHandler) writeMultaError(w http.ResponseWriter, err error) {
+	switch err {
+	case data.ErrUserNotFound, data.ErrMultaNotFound, data.ErrReglaMultaNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case data.ErrMultaAnulada:
+		w.WriteHeader(http.StatusConflict)
+	case data.ErrReglaMultaSinValor, data.ErrPagoMultaMayor:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		h.l.Error("[writeMultaError] Error with multa", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	data.ToJSON(&GenericError{Message: err.Error()}, w)
+}
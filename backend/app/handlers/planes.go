@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+// GetPlanesAporte returns the planes of aporte of a member
+func (h *UsersHandler) GetPlanesAporte(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[GetPlanesAporte] Recieving call to get the planes of", "user", id, "actor", us.ID)
+	planes, err := h.UserService.GetPlanesAporte(us.Fondo, id)
+	if err != nil {
+		h.writePlanError(w, err)
+		return
+	}
+
+	data.ToJSON(&planes, w)
+}
+
+// SetPlanAporte sets the plan of aporte of a member, its current plan ends the day before
+func (h *UsersHandler) SetPlanAporte(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	var p = (context.Get(r, "plan")).(*data.PlanAporteCreate)
+	id := getID(r)
+
+	h.l.Info("[SetPlanAporte] Recieving call to set the plan of", "user", id, "actor", us.ID)
+	plan, err := h.UserService.SetPlanAporte(us.Fondo, id, p, us.Actor())
+	if err != nil {
+		h.writePlanError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&plan, w)
+}
+
+// EndPlanAporte ends the plan of aporte of a member
+func (h *UsersHandler) EndPlanAporte(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[EndPlanAporte] Recieving call to end the plan of", "user", id, "actor", us.ID)
+	err := h.UserService.EndPlanAporte(us.Fondo, id)
+	if err != nil {
+		h.writePlanError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetEstadoAportes returns what a member paid against what its planes expected on each period
+func (h *UsersHandler) GetEstadoAportes(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[GetEstadoAportes] Recieving call to get the estado of the aportes of", "user", id, "actor", us.ID)
+	estado, err := h.UserService.GetEstadoAportes(us.Fondo, id)
+	if err != nil {
+		h.writePlanError(w, err)
+		return
+	}
+
+	data.ToJSON(&estado, w)
+}
+
+// GetMorosidad returns the members that are behind on their aportes and by how much
+func (h *UsersHandler) GetMorosidad(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[GetMorosidad] Recieving call to get the morosidad from", "user", us)
+	morosos, err := h.UserService.GetMorosidad(us.Fondo)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	data.ToJSON(&morosos, w)
+}
+
+func (h *UsersHandler) writePlanError(w http.ResponseWriter, err error) {
+	switch err {
+	case data.ErrUserNotFound, data.ErrPlanNotFound:
+		w.WriteHeader(http.StatusNotFound)
+	case data.ErrMiembroInactivo:
+		w.WriteHeader(http.StatusConflict)
+	case data.ErrPlanInicio:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	default:
+		h.l.Error("[writePlanError] Error with plan", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+
+	data.ToJSON(&GenericError{Message: err.Error()}, w)
+}
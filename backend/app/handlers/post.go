@@ -13,7 +13,7 @@ func (h *UsersHandler) CreateAporte(w http.ResponseWriter, r *http.Request) {
 	var ap = (context.Get(r, "ap")).(*data.Aporte)
 
 	h.l.Info("[CreateAporte] Creating new aporte to user", "user", us)
-	err := h.UserService.CreateAporte(ap.IDUsuario, ap)
+	err := h.UserService.CreateAporte(us.Fondo, ap.IDUsuario, ap)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -22,11 +22,14 @@ func (h *UsersHandler) CreateAporte(w http.ResponseWriter, r *http.Request) {
 
 //CreatePago handles the request to create a pago in the database
 func (h *UsersHandler) CreatePago(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
 	var p = (context.Get(r, "p")).(*data.Pago)
 
 	h.l.Info("[CreateAporte] Creating new pago to credit", "credit", p)
-	err := h.UserService.CreatePago(p)
-	err = h.UserService.CreatePagoInteres(p)
+	err := h.UserService.CreatePago(us.Fondo, p)
+	if err == nil {
+		err = h.UserService.CreatePagoInteres(us.Fondo, p)
+	}
 
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -40,7 +43,7 @@ func (h *UsersHandler) CreateCredito(w http.ResponseWriter, r *http.Request) {
 	var cr = (context.Get(r, "cr")).(*data.Credito)
 
 	h.l.Info("[CreateCredito] Creating new aporte to user", "user", us)
-	err := h.UserService.CreateCredito(cr.IDUsuario, cr)
+	err := h.UserService.CreateCredito(us.Fondo, cr.IDUsuario, cr)
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -49,10 +52,11 @@ func (h *UsersHandler) CreateCredito(w http.ResponseWriter, r *http.Request) {
 
 //CreateDescuentoACapital handles the request to discount money from aportes given an user
 func (h *UsersHandler) CreateDescuentoACapital(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
 	var d = (context.Get(r, "d")).(*data.PostDescuento)
 
 	h.l.Info("[CreateDescuento] Creating new descuento to user")
-	res, err := h.UserService.PostDescontarParaCreditoCapital(d)
+	res, err := h.UserService.PostDescontarParaCreditoCapital(us.Fondo, d, us.Actor())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -62,10 +66,11 @@ func (h *UsersHandler) CreateDescuentoACapital(w http.ResponseWriter, r *http.Re
 
 //PostDescontarAInteres handles the request to discount money from aportes given an user
 func (h *UsersHandler) PostDescontarAInteres(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
 	var d = (context.Get(r, "d")).(*data.PostDescuento)
 
 	h.l.Info("[CreateDescuento] Creating new descuento to user")
-	res, err := h.UserService.PostDescontarParaCreditoIntereses(d)
+	res, err := h.UserService.PostDescontarParaCreditoIntereses(us.Fondo, d, us.Actor())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
@@ -75,10 +80,11 @@ func (h *UsersHandler) PostDescontarAInteres(w http.ResponseWriter, r *http.Requ
 
 //PostDescontar handles the request to discount money from aportes given an user
 func (h *UsersHandler) PostDescontar(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
 	var d = (context.Get(r, "d")).(*data.PostDescuento)
 
 	h.l.Info("[PostDescontar] Creating new descuento to user")
-	res, err := h.UserService.PostDescontar(d)
+	res, err := h.UserService.PostDescontar(us.Fondo, d, us.Actor())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		data.ToJSON(&GenericError{Message: err.Error()}, w)
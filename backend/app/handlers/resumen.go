@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+
+	"github.com/gorilla/context"
+)
+
+// GetResumenAportesByID returns the aportes of a member summed by month, quarter or year
+func (h *UsersHandler) GetResumenAportesByID(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+	id := getID(r)
+
+	h.l.Info("[GetResumenAportesByID] Recieving call to get the resumen of the aportes of", "user", id, "actor", us.ID)
+	h.writeResumenAportes(w, r, us.Fondo, id)
+}
+
+// GetResumenAportes returns the aportes of the fondo summed by month, quarter or year
+func (h *UsersHandler) GetResumenAportes(w http.ResponseWriter, r *http.Request) {
+	var us = (context.Get(r, "us")).(data.User)
+
+	h.l.Info("[GetResumenAportes] Recieving call to get the resumen of the aportes from", "user", us)
+	h.writeResumenAportes(w, r, us.Fondo, 0)
+}
+
+func (h *UsersHandler) writeResumenAportes(w http.ResponseWriter, r *http.Request, idFondo int, idUsuario int) {
+	q := r.URL.Query()
+	group := q.Get("group")
+	if group == "" {
+		group = "month"
+	}
+
+	resumen, err := h.UserService.GetResumenAportes(idFondo, idUsuario, group, q.Get("startDate"), q.Get("endDate"))
+	if err != nil {
+		switch err {
+		case data.ErrAgrupacion, data.ErrRangoFechas:
+			w.WriteHeader(http.StatusBadRequest)
+		case data.ErrUserNotFound:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			h.l.Error("[writeResumenAportes] Error getting resumen", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	data.ToJSON(&resumen, w)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fondo-mod/data"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/context"
+	"github.com/gorilla/mux"
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestGetResumenAportesByID(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		member bool
+		status int
+	}{
+		{"month by default", "", true, http.StatusOK},
+		{"unknown group", "?group=week", true, http.StatusBadRequest},
+		{"date that is not YYYY-MM-DD", "?startDate=2021-1-1", true, http.StatusBadRequest},
+		{"member of another fondo", "", false, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			l := hclog.NewNullLogger()
+			h := New(data.NewUserService(db, l), l, nil)
+
+			if tt.status != http.StatusBadRequest {
+				rows := sqlmock.NewRows([]string{"idUsuario"})
+				if tt.member {
+					rows.AddRow(7)
+				}
+				mock.ExpectQuery("SELECT idUsuario from fondo_usuario").WithArgs(2, 7).WillReturnRows(rows)
+			}
+			if tt.status == http.StatusOK {
+				mock.ExpectQuery("DATE_FORMAT\\(a.fecha, '%Y-%m'\\) AS periodo").WithArgs(2, 7).
+					WillReturnRows(sqlmock.NewRows([]string{"periodo", "aportes", "cantidad", "miembros", "acumulado"}))
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/usuarios/7/aportes/resumen"+tt.query, nil)
+			r = mux.SetURLVars(r, map[string]string{"id": "7"})
+			context.Set(r, "us", data.User{ID: 1, Rol: 1, Fondo: 2})
+			defer context.Clear(r)
+
+			rw := httptest.NewRecorder()
+			h.GetResumenAportesByID(rw, r)
+			if rw.Code != tt.status {
+				t.Errorf("GET resumen%s = %d, want %d", tt.query, rw.Code, tt.status)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
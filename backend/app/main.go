@@ -50,61 +50,142 @@ func main() {
 	// JSON validator
 	v := data.NewValidation()
 
-	// Token validator handler
-	auth := auth.New(authLogger)
-
 	// New user service
 	us := data.NewUserService(db, serviceLogger)
 
-	// New user handler
-	uha := handlers.New(us, handlerLogger, v)
-
-	// Router creationg
-	sm := mux.NewRouter()
-
-	sm.Use(auth.MiddlewareTokenValidationRol3)
-
-	postAportesR1 := sm.Methods(http.MethodPost).Subrouter()
-	postAportesR1.Use(uha.MiddlewareValidateAporte)
-	postAportesR1.Use(auth.MiddlewareTokenValidationRol1)
-	postAportesR1.HandleFunc("/aportes", uha.CreateAporte)
+	// Public keys published by the authentication api
+	ks, err := auth.NewKeySet(authLogger, os.Getenv("jwksURL"), os.Getenv("jwksCAPath"))
+	if err != nil {
+		l.Error("Can't create signing key set", "error", err)
+		os.Exit(1)
+	}
 
-	getAllR3ID := sm.Methods(http.MethodGet).Subrouter()
-	getAllR3ID.Use(uha.MiddlewareCheckUserIDCall)
-	getAllR3ID.HandleFunc("/usuarios/{id:[0-9]+}/aportes", uha.GetAllAportesByID)
-	getAllR3ID.HandleFunc("/usuarios/{id:[0-9]+}/aportes/sum", uha.GetSumAportesByID)
-	getAllR3ID.HandleFunc("/usuarios/{id:[0-9]+}/creditos", uha.GetAllCreditosByUserID)
+	// Permissions of each rol, a JSON file can replace the default policy
+	policy := data.DefaultPolicy()
+	if os.Getenv("permissionsPolicy") != "" {
+		policy, err = data.LoadPolicy(os.Getenv("permissionsPolicy"))
+		if err != nil {
+			l.Error("Can't load permissions policy", "error", err)
+			os.Exit(1)
+		}
+	}
 
-	getAllR3 := sm.Methods(http.MethodGet).Subrouter()
-	getAllR3.HandleFunc("/reporte", uha.GetReporteGeneral)
+	// Scheduled check of the multas, a service account can also call POST /multas/evaluar
+	if os.Getenv("multasInterval") != "" {
+		interval, err := time.ParseDuration(os.Getenv("multasInterval"))
+		if err != nil {
+			l.Error("Can't parse multas interval", "error", err)
+			os.Exit(1)
+		}
 
-	getAllR1 := sm.Methods(http.MethodGet).Subrouter()
-	getAllR1.Use(auth.MiddlewareTokenValidationRol1)
-	getAllR1.HandleFunc("/aportes", uha.GetAllAportes)
-	getAllR1.HandleFunc("/creditos", uha.GetAllCreditos)
+		go func() {
+			for range time.Tick(interval) {
+				n, err := us.EvaluarMultasFondos()
+				if err != nil {
+					l.Error("[main] Error evaluating multas", "multas", n, "error", err)
+					continue
+				}
+				l.Info("[main] Multas evaluated", "multas", n)
+			}
+		}()
+	}
 
-	postCreditosR1 := sm.Methods(http.MethodPost).Subrouter()
-	postCreditosR1.Use(uha.MiddlewareValidateCredito)
-	postCreditosR1.Use(auth.MiddlewareTokenValidationRol1)
-	postCreditosR1.HandleFunc("/creditos", uha.CreateCredito)
+	// Token validator handler
+	auth := auth.New(authLogger, us, ks, policy, os.Getenv("requireAdminMFA") == "true")
 
-	postDescuentosR1 := sm.Methods(http.MethodPost).Subrouter()
-	postDescuentosR1.Use(uha.MiddlewareValidateDescuento)
-	postDescuentosR1.Use(auth.MiddlewareTokenValidationRol1)
-	postDescuentosR1.HandleFunc("/descuentos/capital", uha.CreateDescuentoACapital)
-	postDescuentosR1.HandleFunc("/descuentos/interes", uha.PostDescontarAInteres)
-	postDescuentosR1.HandleFunc("/descuentos", uha.PostDescontar)
+	// New user handler
+	uha := handlers.New(us, handlerLogger, v)
 
-	postCreditosPagosR1 := sm.Methods(http.MethodPost).Subrouter()
-	postCreditosPagosR1.Use(uha.MiddlewareValidatePago)
-	postCreditosPagosR1.HandleFunc("/pago", uha.CreatePago)
+	// Router creationg
+	sm := mux.NewRouter()
 
-	getCreditosR3 := sm.Methods(http.MethodGet).Subrouter()
-	getCreditosR3.Use(uha.MiddlewareValidateCredito)
-	getCreditosR3.HandleFunc("/creditos/proyeccion", uha.GetProyeccionCredito)
+	// every route checks the permission it needs, see auth.routePermissions
+	sm.Use(auth.MiddlewarePermission)
+
+	postAportesR := sm.Methods(http.MethodPost).Subrouter()
+	postAportesR.Use(uha.MiddlewareValidateAporte)
+	postAportesR.HandleFunc("/aportes", uha.CreateAporte)
+
+	postAjustesR := sm.Methods(http.MethodPost).Subrouter()
+	postAjustesR.Use(uha.MiddlewareValidateAjuste)
+	postAjustesR.HandleFunc("/aportes/{id:[0-9]+}/ajustes", uha.CreateAjusteAporte)
+
+	getUserR := sm.Methods(http.MethodGet).Subrouter()
+	getUserR.Use(uha.MiddlewareCheckUserIDCall)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/aportes", uha.GetAllAportesByID)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/aportes/sum", uha.GetSumAportesByID)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/creditos", uha.GetAllCreditosByUserID)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/aportes/estado", uha.GetEstadoAportes)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/aportes/resumen", uha.GetResumenAportesByID)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/planes", uha.GetPlanesAporte)
+	getUserR.HandleFunc("/usuarios/{id:[0-9]+}/multas", uha.GetMultasUsuario)
+
+	getR := sm.Methods(http.MethodGet).Subrouter()
+	getR.HandleFunc("/reporte", uha.GetReporteGeneral)
+	getR.HandleFunc("/aportes", uha.GetAllAportes)
+	getR.HandleFunc("/aportes/resumen", uha.GetResumenAportes)
+	getR.HandleFunc("/creditos", uha.GetAllCreditos)
+	getR.HandleFunc("/importaciones", uha.GetImportaciones)
+	getR.HandleFunc("/morosidad", uha.GetMorosidad)
+	getR.HandleFunc("/multas", uha.GetMultas)
+	getR.HandleFunc("/multas/reglas", uha.GetReglasMulta)
+
+	postCreditosR := sm.Methods(http.MethodPost).Subrouter()
+	postCreditosR.Use(uha.MiddlewareValidateCredito)
+	postCreditosR.HandleFunc("/creditos", uha.CreateCredito)
+
+	postDescuentosR := sm.Methods(http.MethodPost).Subrouter()
+	postDescuentosR.Use(uha.MiddlewareValidateDescuento)
+	postDescuentosR.HandleFunc("/descuentos/capital", uha.CreateDescuentoACapital)
+	postDescuentosR.HandleFunc("/descuentos/interes", uha.PostDescontarAInteres)
+	postDescuentosR.HandleFunc("/descuentos", uha.PostDescontar)
+
+	postPagosR := sm.Methods(http.MethodPost).Subrouter()
+	postPagosR.Use(uha.MiddlewareValidatePago)
+	postPagosR.HandleFunc("/pago", uha.CreatePago)
+
+	getProyeccionR := sm.Methods(http.MethodGet).Subrouter()
+	getProyeccionR.Use(uha.MiddlewareValidateCredito)
+	getProyeccionR.HandleFunc("/creditos/proyeccion", uha.GetProyeccionCredito)
+
+	getLiquidacionesR := sm.Methods(http.MethodGet).Subrouter()
+	getLiquidacionesR.HandleFunc("/usuarios/{id:[0-9]+}/liquidacion", uha.GetLiquidacion)
+	getLiquidacionesR.HandleFunc("/liquidaciones", uha.GetLiquidaciones)
+
+	postLiquidacionR := sm.Methods(http.MethodPost).Subrouter()
+	postLiquidacionR.Use(uha.MiddlewareValidateLiquidacion)
+	postLiquidacionR.HandleFunc("/usuarios/{id:[0-9]+}/liquidacion", uha.CreateLiquidacion)
+
+	putPlanR := sm.Methods(http.MethodPut).Subrouter()
+	putPlanR.Use(uha.MiddlewareValidatePlan)
+	putPlanR.HandleFunc("/usuarios/{id:[0-9]+}/plan", uha.SetPlanAporte)
+
+	deleteR := sm.Methods(http.MethodDelete).Subrouter()
+	deleteR.HandleFunc("/usuarios/{id:[0-9]+}/plan", uha.EndPlanAporte)
+	deleteR.HandleFunc("/multas/{id:[0-9]+}", uha.AnularMulta)
+	deleteR.HandleFunc("/multas/reglas/{id:[0-9]+}", uha.DeactivateReglaMulta)
+
+	postReglasMultaR := sm.Methods(http.MethodPost).Subrouter()
+	postReglasMultaR.Use(uha.MiddlewareValidateReglaMulta)
+	postReglasMultaR.HandleFunc("/multas/reglas", uha.CreateReglaMulta)
+
+	postPagosMultaR := sm.Methods(http.MethodPost).Subrouter()
+	postPagosMultaR.Use(uha.MiddlewareValidatePagoMulta)
+	postPagosMultaR.HandleFunc("/multas/{id:[0-9]+}/pagos", uha.CreatePagoMulta)
+
+	postMultasR := sm.Methods(http.MethodPost).Subrouter()
+	postMultasR.HandleFunc("/multas/evaluar", uha.EvaluarMultas)
+
+	// importaciones are sent as multipart forms, each row is validated by the handler
+	postImportacionesR := sm.Methods(http.MethodPost).Subrouter()
+	postImportacionesR.HandleFunc("/importaciones/preview", uha.PreviewImportacion)
+	postImportacionesR.HandleFunc("/importaciones", uha.CreateImportacion)
 
 	// CORS
-	ch := gohandlers.CORS(gohandlers.AllowedOrigins([]string{"*"}))
+	ch := gohandlers.CORS(
+		gohandlers.AllowedOrigins([]string{"*"}),
+		gohandlers.AllowedMethods([]string{http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodDelete}),
+	)
 
 	s := http.Server{
 		Addr:         os.Getenv("bindAddress"),                         // configure the bind address
@@ -135,6 +216,7 @@ func main() {
 	log.Println("Got signal:", sig)
 
 	// gracefully shutdown the server, waiting max 30 seconds for current operations to complete
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	s.Shutdown(ctx)
 }
@@ -0,0 +1,12 @@
+-- Scopes aportes and creditos to a fondo, runs after 012_fondos.sql of the authentication api
+-- which creates the fondo table. Existing rows belong to fondo 1.
+-- creditos_cuotas and creditos_intereses are scoped through their credito.
+ALTER TABLE aportes
+    ADD COLUMN idFondo INT NOT NULL DEFAULT 1,
+    ADD KEY idx_aportes_fondo_usuario (idFondo, idUsuario),
+    ADD CONSTRAINT fk_aportes_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id);
+
+ALTER TABLE creditos
+    ADD COLUMN idFondo INT NOT NULL DEFAULT 1,
+    ADD KEY idx_creditos_fondo_usuario (idFondo, idUsuario),
+    ADD CONSTRAINT fk_creditos_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id);
@@ -0,0 +1,25 @@
+-- Liquidaciones settle the account of a member that leaves a fondo, runs after
+-- 015_fondo_usuario_activo.sql of the authentication api. intereses is the share of the
+-- fondo intereses paid to the member and comision the fee kept by the fondo, both are
+-- used by the general report. debeCapital and debeInteres were offset against the aportes.
+-- The liquidaciones applied with an API key record it on idAPIKey and have no idActor.
+CREATE TABLE liquidaciones (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    idUsuario INT NOT NULL,
+    aportes INT NOT NULL,
+    intereses INT NOT NULL,
+    debeCapital INT NOT NULL,
+    debeInteres INT NOT NULL,
+    comision INT NOT NULL,
+    neto INT NOT NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_liquidaciones_fondo_usuario (idFondo, idUsuario),
+    CONSTRAINT fk_liquidaciones_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_liquidaciones_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id),
+    CONSTRAINT fk_liquidaciones_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_liquidaciones_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
@@ -0,0 +1,21 @@
+-- Aportes are not updated anymore, corrections, reversals and the descuentos taken from
+-- them are stored as ajustes linked to the aporte. valor is signed and the effective
+-- value of an aporte is its valor plus the valor of its ajustes. The descuentos made
+-- with an API key record it on idAPIKey and have no idActor. Descuentos applied before
+-- this migration overwrote aportes.valor and can't be recovered, those values are kept
+-- as the original ones.
+CREATE TABLE aportes_ajustes (
+    id INT NOT NULL AUTO_INCREMENT,
+    idAporte INT NOT NULL,
+    valor INT NOT NULL,
+    tipo VARCHAR(16) NOT NULL,
+    motivo VARCHAR(255) NOT NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_aportes_ajustes_aporte (idAporte),
+    CONSTRAINT fk_aportes_ajustes_aporte FOREIGN KEY (idAporte) REFERENCES aportes (id),
+    CONSTRAINT fk_aportes_ajustes_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_aportes_ajustes_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
@@ -0,0 +1,48 @@
+-- Importaciones record the CSV and XLSX files of aportes and pagos loaded at once. A file
+-- is identified by the sha256 of its content, so uploading it again does not repeat its rows.
+-- The files imported with an API key record it on idAPIKey and have no idActor.
+CREATE TABLE importaciones (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    hash CHAR(64) NOT NULL,
+    filas INT NOT NULL,
+    aportes INT NOT NULL,
+    pagos INT NOT NULL,
+    total INT NOT NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_importaciones_fondo_hash (idFondo, hash),
+    CONSTRAINT fk_importaciones_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_importaciones_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_importaciones_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
+
+-- Each imported row is identified by the sha256 of its member, fecha, valor and concepto, so
+-- the rows that another file already imported are skipped.
+CREATE TABLE importaciones_filas (
+    id INT NOT NULL AUTO_INCREMENT,
+    idImportacion INT NOT NULL,
+    idFondo INT NOT NULL,
+    fila INT NOT NULL,
+    hash CHAR(64) NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_importaciones_filas_fondo_hash (idFondo, hash),
+    CONSTRAINT fk_importaciones_filas_importacion FOREIGN KEY (idImportacion) REFERENCES importaciones (id),
+    CONSTRAINT fk_importaciones_filas_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id)
+);
+
+-- rows created by an importacion keep a link to it
+ALTER TABLE aportes
+    ADD COLUMN idImportacion INT NULL,
+    ADD CONSTRAINT fk_aportes_importacion FOREIGN KEY (idImportacion) REFERENCES importaciones (id);
+
+ALTER TABLE creditos_cuotas
+    ADD COLUMN idImportacion INT NULL,
+    ADD CONSTRAINT fk_creditos_cuotas_importacion FOREIGN KEY (idImportacion) REFERENCES importaciones (id);
+
+ALTER TABLE creditos_intereses
+    ADD COLUMN idImportacion INT NULL,
+    ADD CONSTRAINT fk_creditos_intereses_importacion FOREIGN KEY (idImportacion) REFERENCES importaciones (id);
@@ -0,0 +1,21 @@
+-- Planes de aporte are the aporte a member is expected to make on each period. A new plan
+-- ends the current one the day before it starts, fechaFin NULL is the current plan.
+-- The planes set with an API key record it on idAPIKey and have no idActor.
+CREATE TABLE planes_aporte (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    idUsuario INT NOT NULL,
+    valor INT NOT NULL,
+    frecuencia VARCHAR(16) NOT NULL,
+    fechaInicio DATE NOT NULL,
+    fechaFin DATE NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    creado DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_planes_aporte_fondo_usuario (idFondo, idUsuario),
+    CONSTRAINT fk_planes_aporte_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_planes_aporte_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id),
+    CONSTRAINT fk_planes_aporte_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_planes_aporte_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
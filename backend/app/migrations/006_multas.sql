@@ -0,0 +1,63 @@
+-- Reglas de multa fine the members that have not paid the aporte of their plan when the
+-- diasGracia after the start of the period are over. A regla charges valorFijo plus the
+-- porcentaje of the aporte expected on the period, up to tope when it is not 0. Periods due
+-- before desde, the day the regla was created, are not fined. The reglas created with
+-- an API key record it on idAPIKey and have no idActor.
+CREATE TABLE multas_reglas (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    valorFijo INT NOT NULL DEFAULT 0,
+    porcentaje DECIMAL(5,2) NOT NULL DEFAULT 0,
+    diasGracia INT NOT NULL DEFAULT 0,
+    tope INT NOT NULL DEFAULT 0,
+    activa TINYINT(1) NOT NULL DEFAULT 1,
+    desde DATE NOT NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    creada DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_multas_reglas_fondo (idFondo),
+    CONSTRAINT fk_multas_reglas_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_multas_reglas_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_multas_reglas_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
+
+-- A member gets at most one multa of each regla per period. Anuladas are waived and not owed.
+CREATE TABLE multas (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    idUsuario INT NOT NULL,
+    idRegla INT NOT NULL,
+    periodo DATE NOT NULL,
+    vencimiento DATE NOT NULL,
+    valor INT NOT NULL,
+    motivo VARCHAR(255) NOT NULL,
+    anulada TINYINT(1) NOT NULL DEFAULT 0,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_multas_regla_usuario_periodo (idRegla, idUsuario, periodo),
+    KEY idx_multas_fondo_usuario (idFondo, idUsuario),
+    CONSTRAINT fk_multas_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_multas_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id),
+    CONSTRAINT fk_multas_regla FOREIGN KEY (idRegla) REFERENCES multas_reglas (id)
+);
+
+-- Payments of the multas are earnings of the fondo. Those made with an API key record it on idAPIKey.
+CREATE TABLE multas_pagos (
+    id INT NOT NULL AUTO_INCREMENT,
+    idMulta INT NOT NULL,
+    valor INT NOT NULL,
+    fecha DATE NOT NULL,
+    idActor INT NULL,
+    idAPIKey INT NULL,
+    creado DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_multas_pagos_multa (idMulta),
+    CONSTRAINT fk_multas_pagos_multa FOREIGN KEY (idMulta) REFERENCES multas (id),
+    CONSTRAINT fk_multas_pagos_actor FOREIGN KEY (idActor) REFERENCES usuario (id),
+    CONSTRAINT fk_multas_pagos_api_key FOREIGN KEY (idAPIKey) REFERENCES api_keys (id)
+);
+
+-- the multas owed by a member that leaves the fondo are paid from its liquidacion
+ALTER TABLE liquidaciones ADD COLUMN debeMultas INT NOT NULL DEFAULT 0 AFTER debeInteres;
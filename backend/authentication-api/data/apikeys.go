@@ -0,0 +1,330 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// ErrServiceAccountNotFound is raised when a service account can not be found in the fondo
+var ErrServiceAccountNotFound = fmt.Errorf("Service account not found")
+
+// ErrAPIKeyNotFound is raised when an API key can not be found in the service account
+var ErrAPIKeyNotFound = fmt.Errorf("API key not found")
+
+// apiKeyPrefix is the start of every API key, so they are easy to spot in logs and scanners
+const apiKeyPrefix = "ffk_"
+
+// APIKeyScopes are the scopes an API key can be given, they are the permissions checked by the app (see
+// the Perm constants of app/data/permissions.go) and must be updated when the app adds one.
+// cuenta:read is not grantable on purpose, it reads the aportes and creditos of the user itself and a
+// key does not belong to a member
+var APIKeyScopes = []string{
+	"aportes:read",
+	"aportes:write",
+	"aportes:adjust",
+	"planes:write",
+	"creditos:read",
+	"creditos:write",
+	"creditos:simulate",
+	"pagos:write",
+	"descuentos:write",
+	"reporte:read",
+	"liquidaciones:read",
+	"liquidaciones:write",
+	"importaciones:read",
+	"importaciones:write",
+	"multas:read",
+	"multas:write",
+	"multas:evaluate",
+}
+
+// validateScope is registered as the scope tag of the validator, it takes the scopes of APIKeyScopes
+func validateScope(fl validator.FieldLevel) bool {
+	scope := fl.Field().String()
+	for _, s := range APIKeyScopes {
+		if s == scope {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ServiceAccount describes a service account of a fondo, used by scripts to call the app
+type ServiceAccount struct {
+	ID        int       `json:"id"`
+	IDFondo   int       `json:"idFondo"`
+	Nombre    string    `json:"nombre"`
+	Activa    bool      `json:"activa"`
+	IDCreador int       `json:"idCreador"`
+	Creada    time.Time `json:"creada"`
+}
+
+// ServiceAccounts is a list of ServiceAccount
+type ServiceAccounts []*ServiceAccount
+
+// ServiceAccountCreate is the body sent to create a service account
+type ServiceAccountCreate struct {
+	Nombre string `json:"nombre" validate:"required,max=255"`
+}
+
+// APIKey describes an API key of a service account, the key itself is only shown when it is created
+type APIKey struct {
+	ID        int        `json:"id"`
+	IDCuenta  int        `json:"idCuenta"`
+	Nombre    string     `json:"nombre"`
+	Clave     string     `json:"clave,omitempty"`
+	Prefijo   string     `json:"prefijo"`
+	Scopes    []string   `json:"scopes"`
+	Expira    *time.Time `json:"expira"`
+	Revocada  bool       `json:"revocada"`
+	UltimoUso *time.Time `json:"ultimoUso"`
+	IDCreador int        `json:"idCreador"`
+	Creada    time.Time  `json:"creada"`
+}
+
+// APIKeys is a list of APIKey
+type APIKeys []*APIKey
+
+// APIKeyCreate is the body sent to create an API key, without dias the key does not expire
+type APIKeyCreate struct {
+	Nombre string   `json:"nombre" validate:"required,max=255"`
+	Scopes []string `json:"scopes" validate:"required,min=1,dive,scope"`
+	Dias   int      `json:"dias" validate:"omitempty,min=1,max=730"`
+}
+
+// APIKeyUse describes a request made by the app with an API key
+type APIKeyUse struct {
+	ID     int       `json:"id"`
+	IDKey  int       `json:"idKey"`
+	Metodo string    `json:"metodo"`
+	Ruta   string    `json:"ruta"`
+	Estado int       `json:"estado"`
+	IP     string    `json:"ip"`
+	Fecha  time.Time `json:"fecha"`
+}
+
+// APIKeyUses is a list of APIKeyUse
+type APIKeyUses []*APIKeyUse
+
+// CreateServiceAccount stores a new service account in the fondo
+func (s *UserService) CreateServiceAccount(c *ServiceAccountCreate, idFondo int, idCreador int) (ServiceAccount, error) {
+	s.l.Info("[CreateServiceAccount] Creating service account", "admin", idCreador, "fondo", idFondo, "nombre", c.Nombre)
+
+	sa := ServiceAccount{IDFondo: idFondo, Nombre: c.Nombre, Activa: true, IDCreador: idCreador, Creada: time.Now()}
+	res, err := s.DB.Exec("INSERT INTO cuentas_servicio (idFondo, nombre, activa, idCreador, creada) VALUES (?, ?, 1, ?, ?)",
+		idFondo, sa.Nombre, idCreador, sa.Creada)
+	if err != nil {
+		return sa, err
+	}
+
+	id, err := res.LastInsertId()
+	sa.ID = int(id)
+
+	return sa, err
+}
+
+// GetServiceAccounts returns every service account of a fondo
+func (s *UserService) GetServiceAccounts(idFondo int) (ServiceAccounts, error) {
+	accounts := ServiceAccounts{}
+	rows, err := s.DB.Query("SELECT id, idFondo, nombre, activa, idCreador, creada FROM cuentas_servicio WHERE idFondo = ? ORDER BY id", idFondo)
+	if err != nil {
+		return accounts, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		sa := &ServiceAccount{}
+		err = rows.Scan(&sa.ID, &sa.IDFondo, &sa.Nombre, &sa.Activa, &sa.IDCreador, &sa.Creada)
+		if err != nil {
+			return accounts, err
+		}
+
+		accounts = append(accounts, sa)
+	}
+
+	return accounts, rows.Err()
+}
+
+// DeactivateServiceAccount deactivates a service account of the fondo and revokes all its keys
+func (s *UserService) DeactivateServiceAccount(id int, idFondo int) error {
+	s.l.Info("[DeactivateServiceAccount] Deactivating", "account", id, "fondo", idFondo)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE cuentas_servicio SET activa = 0 WHERE id = ? AND idFondo = ?", id, idFondo)
+	if err != nil {
+		return err
+	}
+	err = expectOneRow(res, ErrServiceAccountNotFound)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE api_keys SET revocada = 1 WHERE idCuenta = ?", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateAPIKey stores a new key for an active service account of the fondo and returns it
+// with the key, only its hash is stored so it can't be shown again
+func (s *UserService) CreateAPIKey(k *APIKeyCreate, idCuenta int, idFondo int, idCreador int) (APIKey, error) {
+	s.l.Info("[CreateAPIKey] Creating API key", "admin", idCreador, "account", idCuenta, "scopes", k.Scopes)
+
+	key := APIKey{IDCuenta: idCuenta, Nombre: k.Nombre, Scopes: k.Scopes, IDCreador: idCreador, Creada: time.Now()}
+	if k.Dias != 0 {
+		expira := key.Creada.Add(time.Duration(k.Dias) * 24 * time.Hour)
+		key.Expira = &expira
+	}
+
+	active, err := s.isServiceAccountActive(idCuenta, idFondo)
+	if err != nil {
+		return key, err
+	}
+	if !active {
+		return key, ErrServiceAccountNotFound
+	}
+
+	b := make([]byte, 4)
+	_, err = rand.Read(b)
+	if err != nil {
+		return key, err
+	}
+	key.Prefijo = hex.EncodeToString(b)
+
+	secret, err := NewOpaqueToken()
+	if err != nil {
+		return key, err
+	}
+	key.Clave = apiKeyPrefix + key.Prefijo + "_" + secret
+
+	res, err := s.DB.Exec("INSERT INTO api_keys (idCuenta, nombre, prefijo, claveHash, scopes, expira, idCreador, creada) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		idCuenta,
+		key.Nombre,
+		key.Prefijo,
+		HashToken(key.Clave),
+		strings.Join(key.Scopes, ","),
+		key.Expira,
+		idCreador,
+		key.Creada)
+	if err != nil {
+		return key, err
+	}
+
+	id, err := res.LastInsertId()
+	key.ID = int(id)
+
+	return key, err
+}
+
+// GetAPIKeys returns the keys of a service account of the fondo, without the keys themselves
+func (s *UserService) GetAPIKeys(idCuenta int, idFondo int) (APIKeys, error) {
+	keys := APIKeys{}
+	rows, err := s.DB.Query(`SELECT k.id, k.idCuenta, k.nombre, k.prefijo, k.scopes, k.expira, k.revocada, k.ultimoUso, k.idCreador, k.creada
+		FROM api_keys k JOIN cuentas_servicio c ON c.id = k.idCuenta
+		WHERE k.idCuenta = ? AND c.idFondo = ? ORDER BY k.id`, idCuenta, idFondo)
+	if err != nil {
+		return keys, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		k := &APIKey{}
+		var (
+			scopes    string
+			expira    sql.NullTime
+			ultimoUso sql.NullTime
+		)
+		err = rows.Scan(&k.ID, &k.IDCuenta, &k.Nombre, &k.Prefijo, &scopes, &expira, &k.Revocada, &ultimoUso, &k.IDCreador, &k.Creada)
+		if err != nil {
+			return keys, err
+		}
+		k.Scopes = strings.Split(scopes, ",")
+		if expira.Valid {
+			k.Expira = &expira.Time
+		}
+		if ultimoUso.Valid {
+			k.UltimoUso = &ultimoUso.Time
+		}
+
+		keys = append(keys, k)
+	}
+
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey stops a key of a service account of the fondo from being used
+func (s *UserService) RevokeAPIKey(id int, idCuenta int, idFondo int) error {
+	s.l.Info("[RevokeAPIKey] Revoking", "key", id, "account", idCuenta, "fondo", idFondo)
+
+	res, err := s.DB.Exec(`UPDATE api_keys k JOIN cuentas_servicio c ON c.id = k.idCuenta SET k.revocada = 1
+		WHERE k.id = ? AND k.idCuenta = ? AND c.idFondo = ? AND k.revocada = 0`, id, idCuenta, idFondo)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res, ErrAPIKeyNotFound)
+}
+
+// GetAPIKeyUses returns the last requests made with the keys of a service account of the fondo, newest first
+func (s *UserService) GetAPIKeyUses(idCuenta int, idFondo int, limit int) (APIKeyUses, error) {
+	uses := APIKeyUses{}
+	rows, err := s.DB.Query(`SELECT u.id, u.idKey, u.metodo, u.ruta, u.estado, u.ip, u.fecha
+		FROM api_keys_uso u JOIN api_keys k ON k.id = u.idKey JOIN cuentas_servicio c ON c.id = k.idCuenta
+		WHERE k.idCuenta = ? AND c.idFondo = ? ORDER BY u.fecha DESC, u.id DESC LIMIT ?`, idCuenta, idFondo, limit)
+	if err != nil {
+		return uses, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		u := &APIKeyUse{}
+		err = rows.Scan(&u.ID, &u.IDKey, &u.Metodo, &u.Ruta, &u.Estado, &u.IP, &u.Fecha)
+		if err != nil {
+			return uses, err
+		}
+
+		uses = append(uses, u)
+	}
+
+	return uses, rows.Err()
+}
+
+// IsFondoServiceAccount returns true if the service account belongs to the fondo
+func (s *UserService) IsFondoServiceAccount(idFondo int, id int) (bool, error) {
+	rows, err := s.DB.Query("SELECT id FROM cuentas_servicio WHERE id = ? AND idFondo = ?", id, idFondo)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+func (s *UserService) isServiceAccountActive(id int, idFondo int) (bool, error) {
+	rows, err := s.DB.Query("SELECT activa FROM cuentas_servicio WHERE id = ? AND idFondo = ?", id, idFondo)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	activa := false
+	for rows.Next() {
+		err = rows.Scan(&activa)
+	}
+
+	return activa, err
+}
@@ -0,0 +1,104 @@
+package data
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// storedArg matches any string and keeps it to be checked after the call
+type storedArg struct {
+	value *string
+}
+
+func (s storedArg) Match(v driver.Value) bool {
+	value, ok := v.(string)
+	*s.value = value
+
+	return ok
+}
+
+func TestValidateScope(t *testing.T) {
+	v := NewValidation(DefaultPasswordRules())
+
+	tests := []struct {
+		name   string
+		scopes []string
+		valid  bool
+	}{
+		{"known scopes", []string{"aportes:read", "multas:evaluate"}, true},
+		{"unknown scope", []string{"aportes:read", "aportes:delete"}, false},
+		// keys don't belong to a member, they can't read an account of their own
+		{"cuenta:read", []string{"cuenta:read"}, false},
+		{"no scopes", []string{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := v.Validate(&APIKeyCreate{Nombre: "script", Scopes: tt.scopes})
+			if (len(errs) == 0) != tt.valid {
+				t.Errorf("Validate scopes %v = %v, want valid %v", tt.scopes, errs.Errors(), tt.valid)
+			}
+		})
+	}
+}
+
+func TestCreateAPIKey(t *testing.T) {
+	s, mock := newMockService(t)
+	var claveHash string
+	mock.ExpectQuery("SELECT activa FROM cuentas_servicio WHERE id = \\? AND idFondo = \\?").WithArgs(4, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"activa"}).AddRow(true))
+	// only the hash of the key is stored
+	mock.ExpectExec("INSERT INTO api_keys").
+		WithArgs(4, "script", sqlmock.AnyArg(), storedArg{&claveHash}, "aportes:read,aportes:write", nil, 1, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(3, 1))
+
+	key, err := s.CreateAPIKey(&APIKeyCreate{Nombre: "script", Scopes: []string{"aportes:read", "aportes:write"}}, 4, 2, 1)
+	if err != nil {
+		t.Fatalf("CreateAPIKey error = %v", err)
+	}
+	if key.ID != 3 || !strings.HasPrefix(key.Clave, apiKeyPrefix+key.Prefijo+"_") || len(key.Prefijo) != 8 {
+		t.Errorf("CreateAPIKey = id %d, clave %q, prefijo %q, want 3 and a ffk_<prefijo>_ key", key.ID, key.Clave, key.Prefijo)
+	}
+	if claveHash != HashToken(key.Clave) {
+		t.Errorf("CreateAPIKey stored %q, want the hash of the key", claveHash)
+	}
+}
+
+func TestCreateAPIKeyInactiveAccount(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{"deactivated", sqlmock.NewRows([]string{"activa"}).AddRow(false)},
+		{"of another fondo", sqlmock.NewRows([]string{"activa"})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT activa FROM cuentas_servicio").WithArgs(4, 2).WillReturnRows(tt.rows)
+
+			_, err := s.CreateAPIKey(&APIKeyCreate{Nombre: "script", Scopes: []string{"aportes:read"}}, 4, 2, 1)
+			if err != ErrServiceAccountNotFound {
+				t.Errorf("CreateAPIKey error = %v, want %v", err, ErrServiceAccountNotFound)
+			}
+		})
+	}
+}
+
+func TestDeactivateServiceAccount(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE cuentas_servicio SET activa = 0 WHERE id = \\? AND idFondo = \\?").WithArgs(4, 2).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// every key of the account stops working
+	mock.ExpectExec("UPDATE api_keys SET revocada = 1 WHERE idCuenta = \\?").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	if err := s.DeactivateServiceAccount(4, 2); err != nil {
+		t.Errorf("DeactivateServiceAccount error = %v", err)
+	}
+}
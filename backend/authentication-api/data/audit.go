@@ -0,0 +1,74 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditRol             = "rol"
+	AuditDesactivar      = "desactivar"
+	AuditNombre          = "nombre"
+	AuditCelular         = "celular"
+	AuditEmail           = "email"
+	AuditContrasena      = "contrasena"
+	AuditEstado          = "estado"
+	AuditUsuario         = "usuario"
+	AuditCedula          = "cedula"
+	AuditDireccion       = "direccion"
+	AuditFechaNacimiento = "fechaNacimiento"
+	AuditBeneficiarios   = "beneficiarios"
+)
+
+// AuditEntry describes a change made to an user, by an administrator or by the user itself
+type AuditEntry struct {
+	ID        int       `json:"id"`
+	IDUsuario int       `json:"idUsuario"`
+	IDFondo   int       `json:"idFondo,omitempty"`
+	IDActor   int       `json:"idActor"`
+	Accion    string    `json:"accion"`
+	Anterior  string    `json:"anterior"`
+	Nuevo     string    `json:"nuevo"`
+	Fecha     time.Time `json:"fecha"`
+}
+
+// AuditEntries is a list of AuditEntry
+type AuditEntries []*AuditEntry
+
+// GetAudit returns the changes made to an user on the fondo and to its account, newest first.
+// The changes made on the other fondos of the user are left out
+func (s *UserService) GetAudit(idFondo int, idUsuario int) (AuditEntries, error) {
+	s.l.Info("[GetAudit] Getting audit of", "user", idUsuario, "fondo", idFondo)
+
+	entries := AuditEntries{}
+	rows, err := s.DB.Query("SELECT id, idUsuario, idFondo, idActor, accion, anterior, nuevo, fecha FROM usuario_auditoria WHERE idUsuario = ? AND (idFondo = ? OR idFondo IS NULL) ORDER BY fecha DESC, id DESC", idUsuario, idFondo)
+	if err != nil {
+		return entries, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &AuditEntry{}
+		var fondo sql.NullInt64
+		err = rows.Scan(&e.ID, &e.IDUsuario, &fondo, &e.IDActor, &e.Accion, &e.Anterior, &e.Nuevo, &e.Fecha)
+		if err != nil {
+			return entries, err
+		}
+		e.IDFondo = int(fondo.Int64)
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// writeAudit records a change made by idActor to idUsuario on the given transaction. idFondo is the fondo
+// of the membership that changed, or 0 for the changes to the account
+func writeAudit(tx *sql.Tx, idUsuario int, idFondo int, idActor int, accion string, anterior string, nuevo string) error {
+	fondo := sql.NullInt64{Int64: int64(idFondo), Valid: idFondo != 0}
+	_, err := tx.Exec("INSERT INTO usuario_auditoria (idUsuario, idFondo, idActor, accion, anterior, nuevo, fecha) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		idUsuario, fondo, idActor, accion, anterior, nuevo, time.Now())
+
+	return err
+}
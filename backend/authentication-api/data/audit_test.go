@@ -0,0 +1,61 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestGetAuditOfFondo(t *testing.T) {
+	s, mock := newMockService(t)
+	fecha := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+	mock.ExpectQuery("FROM usuario_auditoria WHERE idUsuario = \\? AND \\(idFondo = \\? OR idFondo IS NULL\\)").
+		WithArgs(7, 2).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idUsuario", "idFondo", "idActor", "accion", "anterior", "nuevo", "fecha"}).
+			AddRow(2, 7, 2, 1, AuditRol, "fondo 2: 3", "fondo 2: 1", fecha).
+			AddRow(1, 7, nil, 7, AuditNombre, "Ana", "Ana María", fecha))
+
+	entries, err := s.GetAudit(2, 7)
+	if err != nil {
+		t.Fatalf("GetAudit error = %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("GetAudit = %d entries, want 2", len(entries))
+	}
+	if entries[0].IDFondo != 2 || entries[1].IDFondo != 0 {
+		t.Errorf("GetAudit fondos = %d, %d, want 2 and 0 for the account", entries[0].IDFondo, entries[1].IDFondo)
+	}
+}
+
+func TestWriteAuditFondo(t *testing.T) {
+	tests := []struct {
+		name    string
+		idFondo int
+		fondo   interface{}
+	}{
+		{"change to a membership", 2, int64(2)},
+		{"change to the account", 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectExec("INSERT INTO usuario_auditoria").
+				WithArgs(7, tt.fondo, 1, AuditEstado, "pendiente", "aprobado", sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectRollback()
+
+			tx, err := s.DB.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer tx.Rollback()
+
+			if err := writeAudit(tx, 7, tt.idFondo, 1, AuditEstado, "pendiente", "aprobado"); err != nil {
+				t.Errorf("writeAudit error = %v", err)
+			}
+		})
+	}
+}
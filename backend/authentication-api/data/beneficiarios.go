@@ -0,0 +1,144 @@
+package data
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/go-playground/validator"
+)
+
+// Beneficiario is a person designated by a member to receive its aportes when it dies
+type Beneficiario struct {
+	ID         int     `json:"id,omitempty"`
+	Nombre     string  `json:"nombre" validate:"required,max=255"`
+	Cedula     string  `json:"cedula" validate:"required,numeric,min=5,max=15"`
+	Parentesco string  `json:"parentesco" validate:"required,max=64"`
+	Porcentaje float64 `json:"porcentaje" validate:"gt=0,lte=100"`
+}
+
+// Beneficiarios is a list of Beneficiario
+type Beneficiarios []*Beneficiario
+
+// BeneficiariosUpdate is the body sent to replace the beneficiarios of a member,
+// the porcentajes must add up to 100 and an empty list removes every beneficiario
+type BeneficiariosUpdate struct {
+	Beneficiarios Beneficiarios `json:"beneficiarios" validate:"max=10,dive"`
+}
+
+// validateBeneficiarios is registered as a struct validation of BeneficiariosUpdate,
+// porcentajes have two decimals so they are compared in hundredths
+func validateBeneficiarios(sl validator.StructLevel) {
+	b := sl.Current().Interface().(BeneficiariosUpdate)
+	if len(b.Beneficiarios) == 0 {
+		return
+	}
+
+	total := 0.0
+	for _, ben := range b.Beneficiarios {
+		if ben == nil {
+			sl.ReportError(b.Beneficiarios, "Beneficiarios", "beneficiarios", "required", "")
+			return
+		}
+		total += math.Round(ben.Porcentaje * 100)
+	}
+
+	if total != 10000 {
+		sl.ReportError(b.Beneficiarios, "Beneficiarios", "beneficiarios", "porcentajes", "100")
+	}
+}
+
+// validateFecha is registered as the fecha tag of the validator, it takes YYYY-MM-DD dates in the past
+func validateFecha(fl validator.FieldLevel) bool {
+	t, err := time.Parse("2006-01-02", fl.Field().String())
+	if err != nil {
+		return false
+	}
+
+	return t.Year() >= 1900 && t.Before(time.Now())
+}
+
+// GetBeneficiarios returns the beneficiarios of a member
+func (s *UserService) GetBeneficiarios(idUsuario int) (Beneficiarios, error) {
+	beneficiarios := Beneficiarios{}
+	rows, err := s.DB.Query("SELECT id, nombre, cedula, parentesco, porcentaje FROM beneficiarios WHERE idUsuario = ? ORDER BY id", idUsuario)
+	if err != nil {
+		return beneficiarios, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		b := &Beneficiario{}
+		err = rows.Scan(&b.ID, &b.Nombre, &b.Cedula, &b.Parentesco, &b.Porcentaje)
+		if err != nil {
+			return beneficiarios, err
+		}
+
+		beneficiarios = append(beneficiarios, b)
+	}
+
+	return beneficiarios, rows.Err()
+}
+
+// SetBeneficiarios replaces the beneficiarios of a member, the previous and the new set
+// are kept as JSON on the audit of the user
+func (s *UserService) SetBeneficiarios(idUsuario int, b *BeneficiariosUpdate, idActor int) (Beneficiarios, error) {
+	s.l.Info("[SetBeneficiarios] Replacing beneficiarios of", "user", idUsuario, "actor", idActor, "count", len(b.Beneficiarios))
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// locks the user so two changes can't interleave
+	_, err = readPerfil(tx, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	anterior, err := s.GetBeneficiarios(idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM beneficiarios WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	nuevo := Beneficiarios{}
+	for _, ben := range b.Beneficiarios {
+		porcentaje := math.Round(ben.Porcentaje*100) / 100
+		res, err := tx.Exec("INSERT INTO beneficiarios (idUsuario, nombre, cedula, parentesco, porcentaje) VALUES (?, ?, ?, ?, ?)",
+			idUsuario, ben.Nombre, ben.Cedula, ben.Parentesco, porcentaje)
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		nuevo = append(nuevo, &Beneficiario{ID: int(id), Nombre: ben.Nombre, Cedula: ben.Cedula, Parentesco: ben.Parentesco, Porcentaje: porcentaje})
+	}
+
+	err = writeAudit(tx, idUsuario, 0, idActor, AuditBeneficiarios, beneficiariosJSON(anterior), beneficiariosJSON(nuevo))
+	if err != nil {
+		return nil, err
+	}
+
+	return nuevo, tx.Commit()
+}
+
+// beneficiariosJSON returns the beneficiarios without their ids, which change on every replace, as stored on the audit
+func beneficiariosJSON(b Beneficiarios) string {
+	list := make([]Beneficiario, 0, len(b))
+	for _, ben := range b {
+		list = append(list, Beneficiario{Nombre: ben.Nombre, Cedula: ben.Cedula, Parentesco: ben.Parentesco, Porcentaje: ben.Porcentaje})
+	}
+
+	j, _ := json.Marshal(list)
+
+	return string(j)
+}
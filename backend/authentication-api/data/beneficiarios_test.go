@@ -0,0 +1,90 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestValidateBeneficiarios(t *testing.T) {
+	v := NewValidation(DefaultPasswordRules())
+	ben := func(porcentaje float64) *Beneficiario {
+		return &Beneficiario{Nombre: "Luis", Cedula: "123456", Parentesco: "hijo", Porcentaje: porcentaje}
+	}
+
+	tests := []struct {
+		name  string
+		b     Beneficiarios
+		valid bool
+	}{
+		{"no beneficiarios", Beneficiarios{}, true},
+		{"one with every porcentaje", Beneficiarios{ben(100)}, true},
+		{"thirds with two decimals", Beneficiarios{ben(33.33), ben(33.33), ben(33.34)}, true},
+		{"under 100", Beneficiarios{ben(50), ben(49.99)}, false},
+		{"over 100", Beneficiarios{ben(60), ben(40.01)}, false},
+		{"porcentaje of 0", Beneficiarios{ben(100), ben(0)}, false},
+		{"missing beneficiario", Beneficiarios{ben(100), nil}, false},
+		{"cedula that is not a number", Beneficiarios{{Nombre: "Luis", Cedula: "12a456", Parentesco: "hijo", Porcentaje: 100}}, false},
+		{"more than 10", Beneficiarios{ben(10), ben(10), ben(10), ben(10), ben(10), ben(10), ben(10), ben(10), ben(10), ben(5), ben(5)}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := v.Validate(&BeneficiariosUpdate{Beneficiarios: tt.b})
+			if (len(errs) == 0) != tt.valid {
+				t.Errorf("Validate beneficiarios = %v, want valid %v", errs.Errors(), tt.valid)
+			}
+		})
+	}
+}
+
+func TestValidateFecha(t *testing.T) {
+	v := NewValidation(DefaultPasswordRules())
+
+	tests := map[string]bool{
+		"":           true,
+		"1990-01-02": true,
+		"1899-12-31": false,
+		"02/01/1990": false,
+		"1990-02-30": false,
+		time.Now().AddDate(0, 0, 1).Format("2006-01-02"): false,
+	}
+	for fecha, want := range tests {
+		errs := v.Validate(&ProfileUpdate{Nombre: "Ana", FechaNacimiento: fecha})
+		if (len(errs) == 0) != want {
+			t.Errorf("Validate fechaNacimiento %q = %v, want valid %v", fecha, errs.Errors(), want)
+		}
+	}
+}
+
+func TestSetBeneficiarios(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM usuario WHERE id = \\? FOR UPDATE").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(perfilColumns).AddRow("Ana", "", "ana@x.co", "ana", "", "", ""))
+	mock.ExpectQuery("FROM beneficiarios WHERE idUsuario = \\?").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nombre", "cedula", "parentesco", "porcentaje"}).AddRow(3, "Luis", "123456", "hijo", 100))
+	mock.ExpectExec("DELETE FROM beneficiarios WHERE idUsuario = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO beneficiarios").WithArgs(7, "Luis", "123456", "hijo", 66.67).WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectExec("INSERT INTO beneficiarios").WithArgs(7, "Eva", "654321", "hija", 33.33).WillReturnResult(sqlmock.NewResult(5, 1))
+	// the audit keeps both sets without their ids
+	mock.ExpectExec("INSERT INTO usuario_auditoria").
+		WithArgs(7, nil, 1, AuditBeneficiarios,
+			`[{"nombre":"Luis","cedula":"123456","parentesco":"hijo","porcentaje":100}]`,
+			`[{"nombre":"Luis","cedula":"123456","parentesco":"hijo","porcentaje":66.67},{"nombre":"Eva","cedula":"654321","parentesco":"hija","porcentaje":33.33}]`,
+			sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	b, err := s.SetBeneficiarios(7, &BeneficiariosUpdate{Beneficiarios: Beneficiarios{
+		{Nombre: "Luis", Cedula: "123456", Parentesco: "hijo", Porcentaje: 66.666},
+		{Nombre: "Eva", Cedula: "654321", Parentesco: "hija", Porcentaje: 33.334},
+	}}, 1)
+	if err != nil {
+		t.Fatalf("SetBeneficiarios error = %v", err)
+	}
+	if len(b) != 2 || b[0].ID != 4 || b[1].ID != 5 || b[0].Porcentaje != 66.67 {
+		t.Errorf("SetBeneficiarios = %+v, %+v, want ids 4 and 5 with the porcentajes rounded", b[0], b[1])
+	}
+}
@@ -0,0 +1,236 @@
+package data
+
+import (
+	"fmt"
+	"time"
+)
+
+// ErrNotMember is raised when an user does not belong to a fondo
+var ErrNotMember = fmt.Errorf("User is not a member of the fondo")
+
+// ErrAlreadyMember is raised when an user tries to join a fondo it already belongs to
+var ErrAlreadyMember = fmt.Errorf("User is already a member of the fondo")
+
+// Fondo describes a family fund and the rol of an user in it
+type Fondo struct {
+	ID     int       `json:"id"`
+	Nombre string    `json:"nombre"`
+	IDRol  int       `json:"idRol"`
+	Creado time.Time `json:"creado"`
+}
+
+// Fondos is a list of Fondo
+type Fondos []*Fondo
+
+// Membresia is the rol and approval estado of an user in a fondo, Version is incremented when the
+// tokens issued for the fondo must stop working
+type Membresia struct {
+	IDFondo int
+	IDRol   int
+	Version int
+	Activo  bool
+	Estado  string
+}
+
+// FondoCreate is the body sent to create a fondo
+type FondoCreate struct {
+	Nombre string `json:"nombre" validate:"required,max=255"`
+}
+
+// FondoSwitch is the body sent to change the active fondo of the session
+type FondoSwitch struct {
+	IDFondo int `json:"idFondo" validate:"required"`
+}
+
+// FondoJoin is the body sent by an existing user to join another fondo
+type FondoJoin struct {
+	CodigoInvitacion string `json:"codigoInvitacion" validate:"required"`
+}
+
+// GetFondos returns the fondos an user is an active member of, and was not rejected from, with its rol in each one
+func (s *UserService) GetFondos(idUsuario int) (Fondos, error) {
+	fondos := Fondos{}
+	rows, err := s.DB.Query(`SELECT fondo.id, fondo.nombre, fondo_usuario.idRol, fondo.creado FROM fondo
+		JOIN fondo_usuario ON fondo_usuario.idFondo = fondo.id
+		WHERE fondo_usuario.idUsuario = ? AND fondo_usuario.activo = 1 AND fondo_usuario.estado <> ? ORDER BY fondo.id`, idUsuario, EstadoRechazado)
+	if err != nil {
+		return fondos, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		f := &Fondo{}
+		err = rows.Scan(&f.ID, &f.Nombre, &f.IDRol, &f.Creado)
+		if err != nil {
+			return fondos, err
+		}
+
+		fondos = append(fondos, f)
+	}
+
+	return fondos, rows.Err()
+}
+
+// GetMembresia returns the membership of an user to a fondo, idFondo 0 returns the first fondo where the user
+// is active and was not rejected, or its first membership when there is none so the caller can tell why
+func (s *UserService) GetMembresia(idUsuario int, idFondo int) (Membresia, error) {
+	query := "SELECT idFondo, idRol, tokenVersion, activo, estado FROM fondo_usuario WHERE idUsuario = ? AND idFondo = ?"
+	args := []interface{}{idUsuario, idFondo}
+	if idFondo == 0 {
+		query = "SELECT idFondo, idRol, tokenVersion, activo, estado FROM fondo_usuario WHERE idUsuario = ? ORDER BY activo DESC, estado = ?, idFondo LIMIT 1"
+		args[1] = EstadoRechazado
+	}
+
+	m := Membresia{}
+	rows, err := s.DB.Query(query, args...)
+	if err != nil {
+		return m, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&m.IDFondo, &m.IDRol, &m.Version, &m.Activo, &m.Estado)
+
+		return m, err
+	}
+
+	return m, ErrNotMember
+}
+
+// IsFondoMember returns true if the user belongs to the fondo
+func (s *UserService) IsFondoMember(idFondo int, idUsuario int) (bool, error) {
+	rows, err := s.DB.Query("SELECT idUsuario FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ?", idFondo, idUsuario)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// CanCreateFondos returns true if the user has the capability of creating fondos
+func (s *UserService) CanCreateFondos(idUsuario int) (bool, error) {
+	rows, err := s.DB.Query("SELECT creaFondos FROM usuario WHERE id = ?", idUsuario)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	crea := false
+	for rows.Next() {
+		err = rows.Scan(&crea)
+	}
+
+	return crea, err
+}
+
+// CreateFondo creates a fondo with the given user as its administrator
+func (s *UserService) CreateFondo(f *FondoCreate, idCreador int) (Fondo, error) {
+	s.l.Info("[CreateFondo] Creating fondo", "nombre", f.Nombre, "user", idCreador)
+
+	fondo := Fondo{Nombre: f.Nombre, IDRol: 1, Creado: time.Now()}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fondo, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("INSERT INTO fondo (nombre, creado) VALUES (?, ?)", fondo.Nombre, fondo.Creado)
+	if err != nil {
+		return fondo, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return fondo, err
+	}
+	fondo.ID = int(id)
+
+	_, err = tx.Exec("INSERT INTO fondo_usuario (idFondo, idUsuario, idRol, creado) VALUES (?, ?, 1, ?)", fondo.ID, idCreador, fondo.Creado)
+	if err != nil {
+		return fondo, err
+	}
+
+	return fondo, tx.Commit()
+}
+
+// JoinFondo adds an existing user to the fondo of an invitation code, the user waits in pendiente
+// until an administrator of the fondo approves it
+func (s *UserService) JoinFondo(idUsuario int, email string, code string) (Fondo, error) {
+	s.l.Info("[JoinFondo] Joining fondo with invitation", "user", idUsuario)
+
+	fondo := Fondo{}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return fondo, err
+	}
+	defer tx.Rollback()
+
+	inv, err := useInvitation(tx, code, email)
+	if err != nil {
+		return fondo, err
+	}
+
+	member := false
+	rows, err := tx.Query("SELECT idUsuario FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ?", inv.IDFondo, idUsuario)
+	if err != nil {
+		return fondo, err
+	}
+	member = rows.Next()
+	rows.Close()
+	if member {
+		return fondo, ErrAlreadyMember
+	}
+
+	fondo.ID, fondo.IDRol, fondo.Creado = inv.IDFondo, inv.IDRol, time.Now()
+	_, err = tx.Exec("INSERT INTO fondo_usuario (idFondo, idUsuario, idRol, creado, estado) VALUES (?, ?, ?, ?, ?)", fondo.ID, idUsuario, fondo.IDRol, fondo.Creado, EstadoPendiente)
+	if err != nil {
+		return fondo, err
+	}
+
+	rows, err = tx.Query("SELECT nombre FROM fondo WHERE id = ?", fondo.ID)
+	if err != nil {
+		return fondo, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&fondo.Nombre)
+	}
+	rows.Close()
+	if err != nil {
+		return fondo, err
+	}
+
+	return fondo, tx.Commit()
+}
+
+// GetSessionFondo returns the active fondo of the session of the given familia
+func (s *UserService) GetSessionFondo(familia string) (int, error) {
+	rows, err := s.DB.Query("SELECT idFondo FROM sesiones WHERE familia = ?", familia)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idFondo int
+		err = rows.Scan(&idFondo)
+
+		return idFondo, err
+	}
+
+	return 0, ErrSessionNotFound
+}
+
+// SetSessionFondo changes the active fondo of the session of the given familia
+func (s *UserService) SetSessionFondo(familia string, idUsuario int, idFondo int) error {
+	s.l.Info("[SetSessionFondo] Switching fondo", "user", idUsuario, "fondo", idFondo)
+
+	res, err := s.DB.Exec("UPDATE sesiones SET idFondo = ? WHERE familia = ? AND idUsuario = ?", idFondo, familia, idUsuario)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res, ErrSessionNotFound)
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// membresiaColumns are the columns read by GetMembresia
+var membresiaColumns = []string{"idFondo", "idRol", "tokenVersion", "activo", "estado"}
+
+func TestGetMembresia(t *testing.T) {
+	tests := []struct {
+		name    string
+		idFondo int
+		query   string
+		args    []driver.Value
+	}{
+		{"of a fondo", 2, "WHERE idUsuario = \\? AND idFondo = \\?", []driver.Value{7, 2}},
+		// without a fondo the active memberships that were not rejected go first
+		{"default fondo", 0, "WHERE idUsuario = \\? ORDER BY activo DESC, estado = \\?, idFondo LIMIT 1", []driver.Value{7, EstadoRechazado}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT idFondo, idRol, tokenVersion, activo, estado FROM fondo_usuario " + tt.query).
+				WithArgs(tt.args...).
+				WillReturnRows(sqlmock.NewRows(membresiaColumns).AddRow(2, 1, 3, true, EstadoAprobado))
+
+			m, err := s.GetMembresia(7, tt.idFondo)
+			if err != nil {
+				t.Fatalf("GetMembresia error = %v", err)
+			}
+			if want := (Membresia{IDFondo: 2, IDRol: 1, Version: 3, Activo: true, Estado: EstadoAprobado}); m != want {
+				t.Errorf("GetMembresia = %+v, want %+v", m, want)
+			}
+		})
+	}
+}
+
+func TestGetMembresiaNotMember(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("FROM fondo_usuario").WithArgs(7, 5).WillReturnRows(sqlmock.NewRows(membresiaColumns))
+
+	if _, err := s.GetMembresia(7, 5); err != ErrNotMember {
+		t.Errorf("GetMembresia error = %v, want %v", err, ErrNotMember)
+	}
+}
+
+func TestJoinFondoAlreadyMember(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM invitaciones WHERE codigoHash = \\?").
+		WillReturnRows(sqlmock.NewRows(invitationColumns).AddRow(4, "", 3, 5, 0, time.Now().Add(time.Hour), false, 2))
+	mock.ExpectExec("UPDATE invitaciones SET usos = usos \\+ 1").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\? AND idUsuario = \\?").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+	// the use of the invitation is rolled back too
+	mock.ExpectRollback()
+
+	if _, err := s.JoinFondo(7, "ana@x.co", "ABCD-EFGH-IJKL-MNOP"); err != ErrAlreadyMember {
+		t.Errorf("JoinFondo error = %v, want %v", err, ErrAlreadyMember)
+	}
+}
+
+func TestUpdateUserCompartido(t *testing.T) {
+	tests := []struct {
+		name   string
+		update UserUpdate
+		want   error
+	}{
+		{"email of a shared user", UserUpdate{Nombre: "Ana", Email: "nueva@x.co", Usuario: "ana"}, ErrUsuarioCompartido},
+		{"usuario of a shared user", UserUpdate{Nombre: "Ana", Email: "ana@x.co", Usuario: "anita"}, ErrUsuarioCompartido},
+		{"cedula of a shared user", UserUpdate{Nombre: "Ana", Email: "ana@x.co", Usuario: "ana", Cedula: "98765"}, ErrUsuarioCompartido},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT usuario, email FROM usuario").WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+			if tt.update.Cedula != "" {
+				mock.ExpectQuery("SELECT id FROM usuario WHERE cedula = \\?").WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM usuario WHERE id = \\? FOR UPDATE").WithArgs(7).
+				WillReturnRows(sqlmock.NewRows(perfilColumns).AddRow("Ana", "", "ana@x.co", "ana", "12345", "", ""))
+			mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM fondo_usuario WHERE idUsuario = \\?").WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+			mock.ExpectRollback()
+
+			if err := s.UpdateUser(7, &tt.update, 1); err != tt.want {
+				t.Errorf("UpdateUser error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserCompartidoNombre(t *testing.T) {
+	// the nombre of a shared user can still be changed, the fondos are not counted
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT usuario, email FROM usuario").WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM usuario WHERE id = \\? FOR UPDATE").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(perfilColumns).AddRow("Ana", "", "ana@x.co", "ana", "12345", "", ""))
+	mock.ExpectExec("UPDATE usuario SET nombre = \\?").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, nil, 1, AuditNombre, "Ana", "Ana Maria", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := s.UpdateUser(7, &UserUpdate{Nombre: "Ana Maria", Email: "ana@x.co", Usuario: "ana"}, 1); err != nil {
+		t.Errorf("UpdateUser error = %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package data
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/go-playground/validator"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Password hashing algorithms
+const (
+	HashBcrypt   = "bcrypt"
+	HashArgon2id = "argon2id"
+)
+
+// ErrUnknownHash is raised when a stored password hash has an unknown format
+var ErrUnknownHash = fmt.Errorf("Unknown password hash format")
+
+// HashPolicy describes how new passwords are hashed, stored hashes made with a
+// weaker policy are rehashed on the next successful signin
+type HashPolicy struct {
+	Algorithm  string
+	BcryptCost int
+	// Argon2id parameters, Memory is in KiB
+	Time    uint32
+	Memory  uint32
+	Threads uint8
+	KeyLen  uint32
+	SaltLen uint32
+}
+
+// DefaultHashPolicy returns bcrypt with cost 12
+func DefaultHashPolicy() HashPolicy {
+	return HashPolicy{
+		Algorithm:  HashBcrypt,
+		BcryptCost: 12,
+		Time:       3,
+		Memory:     64 * 1024,
+		Threads:    2,
+		KeyLen:     32,
+		SaltLen:    16,
+	}
+}
+
+// NewHashPolicy returns the default policy with the given algorithm and bcrypt cost,
+// empty or invalid values keep the defaults
+func NewHashPolicy(algorithm string, bcryptCost string) (HashPolicy, error) {
+	p := DefaultHashPolicy()
+
+	switch algorithm {
+	case "", HashBcrypt:
+	case HashArgon2id:
+		p.Algorithm = HashArgon2id
+	default:
+		return p, fmt.Errorf("Unknown password hash algorithm %s", algorithm)
+	}
+
+	if bcryptCost != "" {
+		cost, err := strconv.Atoi(bcryptCost)
+		if err != nil || cost < 10 || cost > bcrypt.MaxCost {
+			return p, fmt.Errorf("Invalid bcrypt cost %s", bcryptCost)
+		}
+		p.BcryptCost = cost
+	}
+
+	return p, nil
+}
+
+// Hash returns the hash of a password following the policy
+func (p HashPolicy) Hash(pwd []byte) (string, error) {
+	if p.Algorithm == HashArgon2id {
+		salt := make([]byte, p.SaltLen)
+		_, err := rand.Read(salt)
+		if err != nil {
+			return "", err
+		}
+
+		key := argon2.IDKey(pwd, salt, p.Time, p.Memory, p.Threads, p.KeyLen)
+
+		return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+			argon2.Version, p.Memory, p.Time, p.Threads,
+			base64.RawStdEncoding.EncodeToString(salt),
+			base64.RawStdEncoding.EncodeToString(key)), nil
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(pwd, p.BcryptCost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(hash), nil
+}
+
+// NeedsRehash returns true if the hash was made with another algorithm or weaker parameters
+func (p HashPolicy) NeedsRehash(hash string) bool {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		a, _, _, err := parseArgon2id(hash)
+		if err != nil || p.Algorithm != HashArgon2id {
+			return true
+		}
+
+		return a.Time < p.Time || a.Memory < p.Memory || a.Threads < p.Threads || a.KeyLen < p.KeyLen
+	}
+
+	cost, err := bcrypt.Cost([]byte(hash))
+	if err != nil || p.Algorithm != HashBcrypt {
+		return true
+	}
+
+	return cost < p.BcryptCost
+}
+
+// comparePassword checks a password against a bcrypt or argon2id hash
+func comparePassword(hash string, pwd []byte) (bool, error) {
+	if strings.HasPrefix(hash, "$argon2id$") {
+		a, salt, key, err := parseArgon2id(hash)
+		if err != nil {
+			return false, err
+		}
+
+		other := argon2.IDKey(pwd, salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+
+		return subtle.ConstantTimeCompare(key, other) == 1, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), pwd)
+	switch err {
+	case nil:
+		return true, nil
+	case bcrypt.ErrMismatchedHashAndPassword:
+		return false, nil
+	}
+
+	return false, err
+}
+
+// parseArgon2id parses a hash in the PHC string format
+func parseArgon2id(hash string) (HashPolicy, []byte, []byte, error) {
+	p := HashPolicy{Algorithm: HashArgon2id}
+
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Time, &p.Threads)
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return p, nil, nil, ErrUnknownHash
+	}
+	p.SaltLen = uint32(len(salt))
+	p.KeyLen = uint32(len(key))
+
+	return p, salt, key, nil
+}
+
+// PasswordRules are the minimum requirements of a new password
+type PasswordRules struct {
+	MinLength int
+	// MaxLength is limited to 72 bytes because bcrypt ignores anything after that
+	MaxLength int
+}
+
+// DefaultPasswordRules returns passwords of 10 to 72 characters with at least a letter and a digit
+func DefaultPasswordRules() PasswordRules {
+	return PasswordRules{MinLength: 10, MaxLength: 72}
+}
+
+// validatePassword is registered as the password tag of the validator
+func (pr PasswordRules) validatePassword(fl validator.FieldLevel) bool {
+	pwd := fl.Field().String()
+	if len(pwd) < pr.MinLength || len(pwd) > pr.MaxLength {
+		return false
+	}
+
+	letter, digit := false, false
+	for _, c := range pwd {
+		switch {
+		case unicode.IsLetter(c):
+			letter = true
+		case unicode.IsDigit(c):
+			digit = true
+		}
+	}
+
+	return letter && digit
+}
@@ -0,0 +1,150 @@
+package data
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hashicorp/go-hclog"
+)
+
+// fastBcrypt and fastArgon2id are cheap policies for the tests
+var (
+	fastBcrypt   = HashPolicy{Algorithm: HashBcrypt, BcryptCost: 4}
+	fastArgon2id = HashPolicy{Algorithm: HashArgon2id, Time: 1, Memory: 1024, Threads: 1, KeyLen: 32, SaltLen: 16}
+)
+
+func TestNewHashPolicy(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		cost      string
+		want      string
+		wantCost  int
+		err       bool
+	}{
+		{"", "", HashBcrypt, 12, false},
+		{HashBcrypt, "14", HashBcrypt, 14, false},
+		{HashArgon2id, "", HashArgon2id, 12, false},
+		{"md5", "", "", 0, true},
+		{HashBcrypt, "9", "", 0, true},
+		{HashBcrypt, "doce", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		p, err := NewHashPolicy(tt.algorithm, tt.cost)
+		if (err != nil) != tt.err {
+			t.Errorf("NewHashPolicy(%q, %q) error = %v, want error %v", tt.algorithm, tt.cost, err, tt.err)
+			continue
+		}
+		if !tt.err && (p.Algorithm != tt.want || p.BcryptCost != tt.wantCost) {
+			t.Errorf("NewHashPolicy(%q, %q) = %s cost %d, want %s cost %d", tt.algorithm, tt.cost, p.Algorithm, p.BcryptCost, tt.want, tt.wantCost)
+		}
+	}
+}
+
+func TestHashAndCompare(t *testing.T) {
+	for _, p := range []HashPolicy{fastBcrypt, fastArgon2id} {
+		t.Run(p.Algorithm, func(t *testing.T) {
+			hash, err := p.Hash([]byte("Clave-segura1"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for pwd, want := range map[string]bool{"Clave-segura1": true, "Clave-segura2": false} {
+				ok, err := comparePassword(hash, []byte(pwd))
+				if err != nil || ok != want {
+					t.Errorf("comparePassword(%q) = %v, %v, want %v", pwd, ok, err, want)
+				}
+			}
+			if p.NeedsRehash(hash) {
+				t.Errorf("NeedsRehash of a hash of the same policy = true, want false")
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	bcrypt4, err := fastBcrypt.Hash([]byte("Clave-segura1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	argon, err := fastArgon2id.Hash([]byte("Clave-segura1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stronger := fastArgon2id
+	stronger.Memory *= 2
+	costlier := fastBcrypt
+	costlier.BcryptCost = 5
+
+	tests := []struct {
+		name   string
+		policy HashPolicy
+		hash   string
+		want   bool
+	}{
+		{"bcrypt below the cost", costlier, bcrypt4, true},
+		{"bcrypt under argon2id", fastArgon2id, bcrypt4, true},
+		{"argon2id under bcrypt", fastBcrypt, argon, true},
+		{"argon2id with less memory", stronger, argon, true},
+		{"unknown hash", fastBcrypt, "plano", true},
+	}
+
+	for _, tt := range tests {
+		if got := tt.policy.NeedsRehash(tt.hash); got != tt.want {
+			t.Errorf("%s: NeedsRehash = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckPasswordRehash(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := New(db, hclog.NewNullLogger(), fastArgon2id)
+
+	old, err := fastBcrypt.Hash([]byte("Clave-segura1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the hash is only replaced if the password was not changed meanwhile
+	mock.ExpectExec("UPDATE usuario SET contrasena = \\? WHERE id = \\? AND contrasena = \\?").
+		WithArgs(passwordArg("Clave-segura1"), 7, old).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	user := &UserSignin{ID: 7, Contrasena: old}
+	if !s.CheckPassword(user, "Clave-segura1") {
+		t.Fatal("CheckPassword = false, want true")
+	}
+	if fastArgon2id.NeedsRehash(user.Contrasena) {
+		t.Errorf("CheckPassword kept hash %q, want an argon2id hash", user.Contrasena)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+
+	// a wrong password is not rehashed
+	if s.CheckPassword(&UserSignin{ID: 7, Contrasena: old}, "Clave-segura2") {
+		t.Error("CheckPassword of a wrong password = true, want false")
+	}
+}
+
+func TestValidatePassword(t *testing.T) {
+	v := NewValidation(DefaultPasswordRules())
+
+	tests := map[string]bool{
+		"Clave-segura1":          true,
+		"corta1":                 false,
+		"sinnumerosnunca":        false,
+		"12345678901":            false,
+		strings.Repeat("a1", 37): false,
+	}
+	for pwd, want := range tests {
+		errs := v.Validate(&PasswordReset{Token: "token", Contrasena: pwd})
+		if (len(errs) == 0) != want {
+			t.Errorf("Validate password %q = %v, want valid %v", pwd, errs.Errors(), want)
+		}
+	}
+}
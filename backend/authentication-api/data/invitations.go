@@ -0,0 +1,158 @@
+package data
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/base32"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvitationInvalid is raised when an invitation code does not exist, expired, was
+// revoked, has no uses left or is bound to another email
+var ErrInvitationInvalid = fmt.Errorf("Invalid invitation code")
+
+// ErrInvitationNotFound is raised when an invitation can not be found in the database
+var ErrInvitationNotFound = fmt.Errorf("Invitation not found")
+
+// defaultInvitationDays is how long an invitation lasts when the request does not say
+const defaultInvitationDays = 7
+
+// Invitation describes an invitation code, the code itself is only shown when it is created
+type Invitation struct {
+	ID          int       `json:"id"`
+	Codigo      string    `json:"codigo,omitempty"`
+	Email       string    `json:"email"`
+	IDRol       int       `json:"idRol"`
+	UsosMaximos int       `json:"usosMaximos"`
+	Usos        int       `json:"usos"`
+	Expira      time.Time `json:"expira"`
+	Revocada    bool      `json:"revocada"`
+	IDCreador   int       `json:"idCreador"`
+	Creada      time.Time `json:"creada"`
+	IDFondo     int       `json:"idFondo"`
+}
+
+// Invitations is a list of Invitation
+type Invitations []*Invitation
+
+// InvitationCreate is the body sent to create an invitation, every field is optional
+type InvitationCreate struct {
+	Email       string `json:"email" validate:"omitempty,email"`
+	IDRol       int    `json:"idRol" validate:"omitempty,oneof=1 2 3"`
+	UsosMaximos int    `json:"usosMaximos" validate:"omitempty,min=1,max=100"`
+	Dias        int    `json:"dias" validate:"omitempty,min=1,max=90"`
+}
+
+// CreateInvitation stores a new invitation to the given fondo and returns it with its code
+func (s *UserService) CreateInvitation(i *InvitationCreate, idFondo int, idCreador int) (Invitation, error) {
+	s.l.Info("[CreateInvitation] Creating invitation", "admin", idCreador, "fondo", idFondo, "rol", i.IDRol)
+
+	inv := Invitation{Email: i.Email, IDRol: i.IDRol, UsosMaximos: i.UsosMaximos, IDCreador: idCreador, Creada: time.Now(), IDFondo: idFondo}
+	if inv.IDRol == 0 {
+		inv.IDRol = 3
+	}
+	if inv.UsosMaximos == 0 {
+		inv.UsosMaximos = 1
+	}
+	dias := i.Dias
+	if dias == 0 {
+		dias = defaultInvitationDays
+	}
+	inv.Expira = inv.Creada.Add(time.Duration(dias) * 24 * time.Hour)
+
+	b := make([]byte, 10)
+	_, err := rand.Read(b)
+	if err != nil {
+		return inv, err
+	}
+	code := base32.StdEncoding.EncodeToString(b)
+	inv.Codigo = code[:4] + "-" + code[4:8] + "-" + code[8:12] + "-" + code[12:]
+
+	res, err := s.DB.Exec("INSERT INTO invitaciones (codigoHash, email, idRol, usosMaximos, expira, idCreador, creada, idFondo) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+		HashToken(normalizeInvitationCode(inv.Codigo)),
+		sql.NullString{String: inv.Email, Valid: inv.Email != ""},
+		inv.IDRol,
+		inv.UsosMaximos,
+		inv.Expira,
+		idCreador,
+		inv.Creada,
+		idFondo)
+	if err != nil {
+		return inv, err
+	}
+
+	id, err := res.LastInsertId()
+	inv.ID = int(id)
+
+	return inv, err
+}
+
+// GetInvitations returns every invitation of a fondo, newest first
+func (s *UserService) GetInvitations(idFondo int) (Invitations, error) {
+	invitations := Invitations{}
+	rows, err := s.DB.Query("SELECT id, COALESCE(email, ''), idRol, usosMaximos, usos, expira, revocada, idCreador, creada, idFondo FROM invitaciones WHERE idFondo = ? ORDER BY creada DESC, id DESC", idFondo)
+	if err != nil {
+		return invitations, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		i := &Invitation{}
+		err = rows.Scan(&i.ID, &i.Email, &i.IDRol, &i.UsosMaximos, &i.Usos, &i.Expira, &i.Revocada, &i.IDCreador, &i.Creada, &i.IDFondo)
+		if err != nil {
+			return invitations, err
+		}
+
+		invitations = append(invitations, i)
+	}
+
+	return invitations, rows.Err()
+}
+
+// RevokeInvitation stops an invitation of a fondo from being used
+func (s *UserService) RevokeInvitation(id int, idFondo int) error {
+	s.l.Info("[RevokeInvitation] Revoking", "invitation", id, "fondo", idFondo)
+
+	res, err := s.DB.Exec("UPDATE invitaciones SET revocada = 1 WHERE id = ? AND idFondo = ?", id, idFondo)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res, ErrInvitationNotFound)
+}
+
+// useInvitation checks an invitation code for the given email and counts one use of it,
+// it returns the invitation so the new user gets its fondo and rol
+func useInvitation(tx *sql.Tx, code string, email string) (Invitation, error) {
+	inv := Invitation{}
+	rows, err := tx.Query("SELECT id, COALESCE(email, ''), idRol, usosMaximos, usos, expira, revocada, idFondo FROM invitaciones WHERE codigoHash = ? FOR UPDATE",
+		HashToken(normalizeInvitationCode(code)))
+	if err != nil {
+		return inv, err
+	}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&inv.ID, &inv.Email, &inv.IDRol, &inv.UsosMaximos, &inv.Usos, &inv.Expira, &inv.Revocada, &inv.IDFondo)
+	}
+	rows.Close()
+	if err != nil {
+		return inv, err
+	}
+
+	if !found || inv.Revocada || inv.Usos >= inv.UsosMaximos || time.Now().After(inv.Expira) {
+		return inv, ErrInvitationInvalid
+	}
+	if inv.Email != "" && !strings.EqualFold(inv.Email, email) {
+		return inv, ErrInvitationInvalid
+	}
+
+	_, err = tx.Exec("UPDATE invitaciones SET usos = usos + 1 WHERE id = ?", inv.ID)
+
+	return inv, err
+}
+
+func normalizeInvitationCode(code string) string {
+	return strings.ToUpper(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
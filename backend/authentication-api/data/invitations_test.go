@@ -0,0 +1,97 @@
+package data
+
+import (
+	"database/sql/driver"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// invitationColumns are the columns read by useInvitation
+var invitationColumns = []string{"id", "email", "idRol", "usosMaximos", "usos", "expira", "revocada", "idFondo"}
+
+// expiraArg matches an expira within a minute of the given time
+type expiraArg time.Time
+
+func (e expiraArg) Match(v driver.Value) bool {
+	t, ok := v.(time.Time)
+	if !ok {
+		return false
+	}
+	d := t.Sub(time.Time(e))
+
+	return d > -time.Minute && d < time.Minute
+}
+
+func TestCreateInvitation(t *testing.T) {
+	s, mock := newMockService(t)
+	// the defaults are a member invitation with one use that lasts a week
+	mock.ExpectExec("INSERT INTO invitaciones").
+		WithArgs(sqlmock.AnyArg(), nil, 3, 1, expiraArg(time.Now().Add(7*24*time.Hour)), 1, sqlmock.AnyArg(), 2).
+		WillReturnResult(sqlmock.NewResult(5, 1))
+
+	inv, err := s.CreateInvitation(&InvitationCreate{}, 2, 1)
+	if err != nil {
+		t.Fatalf("CreateInvitation error = %v", err)
+	}
+	if inv.ID != 5 || !regexp.MustCompile(`^[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}-[A-Z2-7]{4}$`).MatchString(inv.Codigo) {
+		t.Errorf("CreateInvitation = id %d, codigo %q, want 5 and a XXXX-XXXX-XXXX-XXXX code", inv.ID, inv.Codigo)
+	}
+}
+
+func TestNormalizeInvitationCode(t *testing.T) {
+	if got := normalizeInvitationCode(" abcd-efgh-ijkl-mnop "); got != "ABCDEFGHIJKLMNOP" {
+		t.Errorf("normalizeInvitationCode = %q, want %q", got, "ABCDEFGHIJKLMNOP")
+	}
+}
+
+func TestCreateUserInvitation(t *testing.T) {
+	vigente := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name string
+		row  []driver.Value
+		want error
+	}{
+		{"unknown code", nil, ErrInvitationInvalid},
+		{"revoked", []driver.Value{4, "", 3, 1, 0, vigente, true, 2}, ErrInvitationInvalid},
+		{"no uses left", []driver.Value{4, "", 3, 2, 2, vigente, false, 2}, ErrInvitationInvalid},
+		{"expired", []driver.Value{4, "", 3, 1, 0, time.Now().Add(-time.Hour), false, 2}, ErrInvitationInvalid},
+		{"bound to another email", []driver.Value{4, "otra@x.co", 3, 1, 0, vigente, false, 2}, ErrInvitationInvalid},
+		{"bound to the email in other case", []driver.Value{4, "ANA@x.co", 2, 1, 0, vigente, false, 2}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			s.hp = fastBcrypt
+			mock.ExpectQuery("SELECT usuario, email FROM usuario").WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+			mock.ExpectBegin()
+			rows := sqlmock.NewRows(invitationColumns)
+			if tt.row != nil {
+				rows.AddRow(tt.row...)
+			}
+			mock.ExpectQuery("FROM invitaciones WHERE codigoHash = \\? FOR UPDATE").WithArgs(HashToken("ABCDEFGHIJKLMNOP")).WillReturnRows(rows)
+			if tt.want == nil {
+				mock.ExpectExec("UPDATE invitaciones SET usos = usos \\+ 1 WHERE id = \\?").WithArgs(4).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec("INSERT INTO usuario").WillReturnResult(sqlmock.NewResult(7, 1))
+				// the user joins the fondo of the invitation with its rol, pending approval
+				mock.ExpectExec("INSERT INTO fondo_usuario").WithArgs(2, 7, 2, sqlmock.AnyArg(), EstadoPendiente).WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectCommit()
+			} else {
+				mock.ExpectRollback()
+			}
+
+			u := &UserCreate{Usuario: "ana", Email: "ana@x.co", Contrasena: "Secreta123", CodigoInvitacion: "abcd-efgh-ijkl-mnop"}
+			err := s.CreateUser(u)
+			if err != tt.want {
+				t.Fatalf("CreateUser error = %v, want %v", err, tt.want)
+			}
+			if err == nil && (u.ID != 7 || u.IDRol != 2) {
+				t.Errorf("CreateUser = id %d, rol %d, want 7, 2", u.ID, u.IDRol)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package data
+
+import (
+	"database/sql"
+	"time"
+)
+
+// LockoutLimit describes how many failed signins are allowed before slowing down or locking
+type LockoutLimit struct {
+	// Free is the number of failures allowed without any delay
+	Free int
+	// Max is the number of failures that locks the account or ip
+	Max int
+}
+
+// LockoutPolicy describes how failed signins are throttled
+type LockoutPolicy struct {
+	Account LockoutLimit
+	IP      LockoutLimit
+	// Window is how far back failures are counted
+	Window time.Duration
+	// BaseDelay is doubled on each failure after the free ones, up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// LockDuration is how long a lock lasts after the last failure
+	LockDuration time.Duration
+}
+
+// LoginFailures describes the recent failed signins of an account or ip
+type LoginFailures struct {
+	Count int
+	Last  time.Time
+}
+
+// DefaultLockoutPolicy returns the policy used by /signin
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Account:      LockoutLimit{Free: 3, Max: 10},
+		IP:           LockoutLimit{Free: 10, Max: 50},
+		Window:       15 * time.Minute,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockDuration: 15 * time.Minute,
+	}
+}
+
+// RetryAfter returns how long the client has to wait before trying again and
+// whether the account or ip is locked
+func (p LockoutPolicy) RetryAfter(f LoginFailures, limit LockoutLimit, now time.Time) (time.Duration, bool) {
+	if f.Count >= limit.Max {
+		return f.Last.Add(p.LockDuration).Sub(now), true
+	}
+
+	if f.Count <= limit.Free {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := limit.Free + 1; i < f.Count && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return f.Last.Add(delay).Sub(now), false
+}
+
+// GetLoginFailures returns the failed signins since the given date for an account and for an ip
+func (s *UserService) GetLoginFailures(usuario string, ip string, since time.Time) (LoginFailures, LoginFailures, error) {
+	account, err := s.countLoginFailures("SELECT COUNT(*), MAX(fecha) FROM login_failures WHERE usuario = ? AND fecha > ?", usuario, since)
+	if err != nil {
+		return account, LoginFailures{}, err
+	}
+
+	byIP, err := s.countLoginFailures("SELECT COUNT(*), MAX(fecha) FROM login_failures WHERE ip = ? AND fecha > ?", ip, since)
+
+	return account, byIP, err
+}
+
+// RecordLoginFailure stores a failed signin
+func (s *UserService) RecordLoginFailure(usuario string, ip string) error {
+	s.l.Info("[RecordLoginFailure] Recording failed signin", "user", usuario, "ip", ip)
+
+	_, err := s.DB.Exec("INSERT INTO login_failures (usuario, ip, fecha) VALUES (?, ?, ?)", usuario, ip, time.Now())
+
+	return err
+}
+
+// ClearLoginFailures removes the failed signins of an account, unlocking it
+func (s *UserService) ClearLoginFailures(usuario string) error {
+	_, err := s.DB.Exec("DELETE FROM login_failures WHERE usuario = ?", usuario)
+
+	return err
+}
+
+func (s *UserService) countLoginFailures(query string, key string, since time.Time) (LoginFailures, error) {
+	f := LoginFailures{}
+	var last sql.NullTime
+
+	rows, err := s.DB.Query(query, key, since)
+	if err != nil {
+		return f, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&f.Count, &last)
+		if err != nil {
+			return f, err
+		}
+	}
+	f.Last = last.Time
+
+	return f, rows.Err()
+}
@@ -0,0 +1,60 @@
+package data
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryAfter(t *testing.T) {
+	p := DefaultLockoutPolicy()
+	last := time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)
+
+	capped := DefaultLockoutPolicy()
+	capped.MaxDelay = 5 * time.Second
+
+	tests := []struct {
+		name   string
+		policy LockoutPolicy
+		count  int
+		now    time.Time
+		delay  time.Duration
+		locked bool
+	}{
+		{"no failures", p, 0, last, 0, false},
+		{"free failures", p, 3, last, 0, false},
+		{"first delayed failure", p, 4, last, time.Second, false},
+		{"delay doubles", p, 5, last, 2 * time.Second, false},
+		{"delay doubles again", p, 6, last, 4 * time.Second, false},
+		{"last failure before the lock", p, 9, last, 32 * time.Second, false},
+		{"delay counts from the last failure", p, 5, last.Add(500 * time.Millisecond), 1500 * time.Millisecond, false},
+		{"delay over", p, 4, last.Add(3 * time.Second), -2 * time.Second, false},
+		{"delay capped", capped, 9, last, 5 * time.Second, false},
+		{"locked at max", p, 10, last, 15 * time.Minute, true},
+		{"lock counts from the last failure", p, 12, last.Add(5 * time.Minute), 10 * time.Minute, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			delay, locked := tt.policy.RetryAfter(LoginFailures{Count: tt.count, Last: last}, tt.policy.Account, tt.now)
+			if delay != tt.delay || locked != tt.locked {
+				t.Errorf("RetryAfter(%d) = %v, %v, want %v, %v", tt.count, delay, locked, tt.delay, tt.locked)
+			}
+		})
+	}
+}
+
+func TestRetryAfterIPLimit(t *testing.T) {
+	p := DefaultLockoutPolicy()
+	now := time.Now()
+
+	// failures that lock an account only slow down an ip
+	delay, locked := p.RetryAfter(LoginFailures{Count: 10, Last: now}, p.IP, now)
+	if delay != 0 || locked {
+		t.Errorf("RetryAfter(10) on ip = %v, %v, want 0, false", delay, locked)
+	}
+
+	_, locked = p.RetryAfter(LoginFailures{Count: 50, Last: now}, p.IP, now)
+	if !locked {
+		t.Error("RetryAfter(50) on ip is not locked")
+	}
+}
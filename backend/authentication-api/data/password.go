@@ -0,0 +1,109 @@
+package data
+
+import (
+	"time"
+)
+
+// PasswordForgot is the body sent to ask for a password reset
+type PasswordForgot struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordReset is the body sent to set a new password with a reset token
+type PasswordReset struct {
+	Token      string `json:"token" validate:"required"`
+	Contrasena string `json:"contrasena" validate:"required,password"`
+}
+
+// CreatePasswordReset stores a single use reset token for the user and returns it,
+// tokens that were not used yet are invalidated
+func (s *UserService) CreatePasswordReset(idUsuario int, ttl time.Duration) (string, error) {
+	s.l.Info("[CreatePasswordReset] Creating password reset for", "user", idUsuario)
+
+	token, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE password_resets SET usado = 1 WHERE idUsuario = ? AND usado = 0", idUsuario)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec("INSERT INTO password_resets (idUsuario, tokenHash, expira) VALUES (?, ?, ?)",
+		idUsuario,
+		HashToken(token),
+		time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return token, tx.Commit()
+}
+
+// ResetPassword sets a new password for the owner of the reset token, uses the token
+// and closes every session of the user
+func (s *UserService) ResetPassword(token string, contrasena string) (int, error) {
+	s.l.Info("[ResetPassword] Resetting password")
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var (
+		id        int
+		idUsuario int
+		expira    time.Time
+	)
+
+	rows, err := tx.Query("SELECT id, idUsuario, expira FROM password_resets WHERE tokenHash = ? AND usado = 0 FOR UPDATE", HashToken(token))
+	if err != nil {
+		return 0, err
+	}
+
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&id, &idUsuario, &expira)
+	}
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, ErrTokenNotFound
+	}
+
+	if time.Now().After(expira) {
+		return idUsuario, ErrTokenExpired
+	}
+
+	saltedPassword, err := s.hashAndSalt([]byte(contrasena))
+	if err != nil {
+		return idUsuario, err
+	}
+
+	_, err = tx.Exec("UPDATE password_resets SET usado = 1 WHERE id = ?", id)
+	if err != nil {
+		return idUsuario, err
+	}
+
+	_, err = tx.Exec("UPDATE usuario SET contrasena = ? WHERE id = ?", saltedPassword, idUsuario)
+	if err != nil {
+		return idUsuario, err
+	}
+
+	err = closeSessions(tx, idUsuario)
+	if err != nil {
+		return idUsuario, err
+	}
+
+	return idUsuario, tx.Commit()
+}
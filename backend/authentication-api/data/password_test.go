@@ -0,0 +1,80 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// passwordArg matches the hash stored for a password
+type passwordArg string
+
+func (p passwordArg) Match(v driver.Value) bool {
+	hash, ok := v.(string)
+	if !ok {
+		return false
+	}
+	matches, err := comparePassword(hash, []byte(p))
+
+	return err == nil && matches
+}
+
+func TestCreatePasswordReset(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	// the tokens sent before stop working
+	mock.ExpectExec("UPDATE password_resets SET usado = 1 WHERE idUsuario = \\? AND usado = 0").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO password_resets").WithArgs(7, sqlmock.AnyArg(), sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	token, err := s.CreatePasswordReset(7, time.Hour)
+	if err != nil || token == "" {
+		t.Errorf("CreatePasswordReset = %q, %v, want a token", token, err)
+	}
+}
+
+func TestResetPassword(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM password_resets WHERE tokenHash = \\? AND usado = 0 FOR UPDATE").WithArgs(HashToken("token")).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idUsuario", "expira"}).AddRow(3, 7, time.Now().Add(time.Hour)))
+	mock.ExpectExec("UPDATE password_resets SET usado = 1 WHERE id = \\?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE usuario SET contrasena = \\? WHERE id = \\?").WithArgs(passwordArg("Nueva-clave1"), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// every session of the user is closed
+	mock.ExpectExec("UPDATE usuario SET tokenVersion = tokenVersion \\+ 1 WHERE id = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE idUsuario = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	id, err := s.ResetPassword("token", "Nueva-clave1")
+	if err != nil || id != 7 {
+		t.Errorf("ResetPassword = %d, %v, want 7", id, err)
+	}
+}
+
+func TestResetPasswordInvalidToken(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want error
+	}{
+		{"unknown or used", sqlmock.NewRows([]string{"id", "idUsuario", "expira"}), ErrTokenNotFound},
+		{"expired", sqlmock.NewRows([]string{"id", "idUsuario", "expira"}).AddRow(3, 7, time.Now().Add(-time.Minute)), ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM password_resets").WillReturnRows(tt.rows)
+			mock.ExpectRollback()
+
+			if _, err := s.ResetPassword("token", "Nueva-clave1"); err != tt.want {
+				t.Errorf("ResetPassword error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package data
+
+import (
+	"database/sql"
+)
+
+// ProfileUpdate defines the fields of the profile an user can change by itself
+type ProfileUpdate struct {
+	Nombre          string `json:"nombre" validate:"required"`
+	Celular         string `json:"celular"`
+	Cedula          string `json:"cedula" validate:"omitempty,numeric,min=5,max=15"`
+	Direccion       string `json:"direccion" validate:"max=255"`
+	FechaNacimiento string `json:"fechaNacimiento" validate:"omitempty,fecha"`
+}
+
+// EmailChange is the body sent to change the email of the user, it needs the current password
+type EmailChange struct {
+	Email      string `json:"email" validate:"required,email"`
+	Contrasena string `json:"contrasena" validate:"required"`
+}
+
+// PasswordChange is the body sent to change the password of the user
+type PasswordChange struct {
+	Contrasena string `json:"contrasena" validate:"required"`
+	Nueva      string `json:"nueva" validate:"required,password"`
+}
+
+// UpdateProfile changes the profile of an user, each changed field is audited.
+// An empty cedula, direccion or fechaNacimiento keeps the current one
+func (s *UserService) UpdateProfile(id int, p *ProfileUpdate) error {
+	s.l.Info("[UpdateProfile] Updating profile of", "user", id)
+
+	err := s.checkCedula(id, p.Cedula)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	anterior, err := readPerfil(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE usuario SET nombre = ?, celular = ?, "+keepEmpty+" WHERE id = ?",
+		p.Nombre, p.Celular, p.Cedula, p.Direccion, p.FechaNacimiento, id)
+	if err != nil {
+		return duplicateError(err)
+	}
+
+	nuevo := perfil{AuditNombre: p.Nombre, AuditCelular: p.Celular}
+	nuevo.setFilled(AuditCedula, p.Cedula)
+	nuevo.setFilled(AuditDireccion, p.Direccion)
+	nuevo.setFilled(AuditFechaNacimiento, p.FechaNacimiento)
+	err = auditPerfil(tx, id, id, anterior, nuevo)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// perfil holds the audited fields of an user by their audit accion
+type perfil map[string]string
+
+// keepEmpty sets cedula, direccion and fechaNacimiento unless the given value is empty
+const keepEmpty = "cedula = COALESCE(NULLIF(?, ''), cedula), direccion = COALESCE(NULLIF(?, ''), direccion), fechaNacimiento = COALESCE(NULLIF(?, ''), fechaNacimiento)"
+
+// setFilled sets the field only when the value is not empty, matching keepEmpty
+func (p perfil) setFilled(field string, value string) {
+	if value != "" {
+		p[field] = value
+	}
+}
+
+// perfilFields is the order in which the changes of a perfil are audited
+var perfilFields = []string{AuditNombre, AuditCelular, AuditEmail, AuditUsuario, AuditCedula, AuditDireccion, AuditFechaNacimiento}
+
+// readPerfil locks the user and returns its audited fields
+func readPerfil(tx *sql.Tx, id int) (perfil, error) {
+	var nombre, celular, email, usuario, cedula, direccion, fechaNacimiento string
+	rows, err := tx.Query(`SELECT nombre, COALESCE(celular, ''), email, usuario, COALESCE(cedula, ''), COALESCE(direccion, ''),
+		COALESCE(DATE_FORMAT(fechaNacimiento, '%Y-%m-%d'), '') FROM usuario WHERE id = ? FOR UPDATE`, id)
+	if err != nil {
+		return nil, err
+	}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&nombre, &celular, &email, &usuario, &cedula, &direccion, &fechaNacimiento)
+	}
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrProductNotFound
+	}
+
+	return perfil{
+		AuditNombre:          nombre,
+		AuditCelular:         celular,
+		AuditEmail:           email,
+		AuditUsuario:         usuario,
+		AuditCedula:          cedula,
+		AuditDireccion:       direccion,
+		AuditFechaNacimiento: fechaNacimiento,
+	}, nil
+}
+
+// auditPerfil records each field of nuevo that is different in anterior, fields missing in nuevo were not changed
+func auditPerfil(tx *sql.Tx, id int, idActor int, anterior perfil, nuevo perfil) error {
+	for _, f := range perfilFields {
+		v, ok := nuevo[f]
+		if !ok || v == anterior[f] {
+			continue
+		}
+
+		err := writeAudit(tx, id, 0, idActor, f, anterior[f], v)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ChangeEmail replaces the email of an user once the new one was verified, it only succeeds
+// if the email is still the one the user had when the change was asked
+func (s *UserService) ChangeEmail(id int, anterior string, email string) error {
+	s.l.Info("[ChangeEmail] Changing email of", "user", id)
+
+	err := s.checkDuplicates(id, "", email)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE usuario SET email = ?, verificado = 1 WHERE id = ? AND email = ?", email, id, anterior)
+	if err != nil {
+		return duplicateError(err)
+	}
+
+	err = expectOneRow(res, ErrProductNotFound)
+	if err != nil {
+		return err
+	}
+
+	err = writeAudit(tx, id, 0, id, AuditEmail, anterior, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ChangePassword sets a new password for an user and closes every session of the user, its access
+// tokens stop being accepted too. The password itself is never written to the audit
+func (s *UserService) ChangePassword(id int, contrasena string) error {
+	s.l.Info("[ChangePassword] Changing password of", "user", id)
+
+	saltedPassword, err := s.hashAndSalt([]byte(contrasena))
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec("UPDATE usuario SET contrasena = ? WHERE id = ?", saltedPassword, id)
+	if err != nil {
+		return err
+	}
+
+	err = expectOneRow(res, ErrProductNotFound)
+	if err != nil {
+		return err
+	}
+
+	err = closeSessions(tx, id)
+	if err != nil {
+		return err
+	}
+
+	err = writeAudit(tx, id, 0, id, AuditContrasena, "", "")
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
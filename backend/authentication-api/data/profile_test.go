@@ -0,0 +1,95 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// perfilColumns are the columns read by readPerfil
+var perfilColumns = []string{"nombre", "celular", "email", "usuario", "cedula", "direccion", "fechaNacimiento"}
+
+func TestUpdateProfile(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT id FROM usuario WHERE cedula = \\? AND id <> \\?").WithArgs("12345", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM usuario WHERE id = \\? FOR UPDATE").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows(perfilColumns).AddRow("Ana", "300", "ana@x.co", "ana", "", "Calle 1", "1990-01-02"))
+	mock.ExpectExec("UPDATE usuario SET nombre = \\?, celular = \\?, cedula = COALESCE").
+		WithArgs("Ana Maria", "300", "12345", "", "", 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	// only the changed fields are audited, the empty direccion and fechaNacimiento are kept
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, nil, 7, AuditNombre, "Ana", "Ana Maria", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, nil, 7, AuditCedula, "", "12345", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	err := s.UpdateProfile(7, &ProfileUpdate{Nombre: "Ana Maria", Celular: "300", Cedula: "12345"})
+	if err != nil {
+		t.Errorf("UpdateProfile error = %v", err)
+	}
+}
+
+func TestUpdateProfileCedulaTaken(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT id FROM usuario WHERE cedula = \\? AND id <> \\?").WithArgs("12345", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(8))
+
+	err := s.UpdateProfile(7, &ProfileUpdate{Nombre: "Ana", Cedula: "12345"})
+	if err != ErrCedulaTaken {
+		t.Errorf("UpdateProfile error = %v, want %v", err, ErrCedulaTaken)
+	}
+}
+
+func TestChangeEmail(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT usuario, email FROM usuario").WithArgs("", "nueva@x.co", 7).
+		WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE usuario SET email = \\?, verificado = 1 WHERE id = \\? AND email = \\?").
+		WithArgs("nueva@x.co", 7, "ana@x.co").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, nil, 7, AuditEmail, "ana@x.co", "nueva@x.co", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.ChangeEmail(7, "ana@x.co", "nueva@x.co")
+	if err != nil {
+		t.Errorf("ChangeEmail error = %v", err)
+	}
+}
+
+func TestChangeEmailChangedMeanwhile(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("SELECT usuario, email FROM usuario").
+		WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+	mock.ExpectBegin()
+	// the email is no longer the one the change was asked from
+	mock.ExpectExec("UPDATE usuario SET email = \\?, verificado = 1 WHERE id = \\? AND email = \\?").
+		WithArgs("nueva@x.co", 7, "ana@x.co").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	err := s.ChangeEmail(7, "ana@x.co", "nueva@x.co")
+	if err != ErrProductNotFound {
+		t.Errorf("ChangeEmail error = %v, want %v", err, ErrProductNotFound)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE usuario SET contrasena = \\? WHERE id = \\?").WithArgs(passwordArg("Nueva-clave1"), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE usuario SET tokenVersion = tokenVersion \\+ 1 WHERE id = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE idUsuario = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = \\?").WithArgs(7).WillReturnResult(sqlmock.NewResult(0, 1))
+	// the password is never written to the audit
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, nil, 7, AuditContrasena, "", "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := s.ChangePassword(7, "Nueva-clave1")
+	if err != nil {
+		t.Errorf("ChangePassword error = %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ErrLastAdmin is raised when a change would leave the fondo without an active administrator
+var ErrLastAdmin = fmt.Errorf("The fondo must have at least one active administrator")
+
+// TokenState is what an access token is checked against on each request, FondoVersion, Activo
+// and Estado are the ones of the membership to the fondo of the token
+type TokenState struct {
+	Version      int
+	FondoVersion int
+	Activo       bool
+	Estado       string
+}
+
+// EstadoDecision is the body sent to approve or reject a pending user
+type EstadoDecision struct {
+	Motivo string `json:"motivo" validate:"max=255"`
+}
+
+// RoleChange is the body sent to change the role of an user, 1 is an administrator, 2 an auditor and 3 a member
+type RoleChange struct {
+	IDRol int `json:"idRol" validate:"required,oneof=1 2 3"`
+}
+
+// ChangeRole sets the role of an user in a fondo and increments the token version of the membership,
+// so every token issued for the fondo with the previous role stops being accepted while the tokens
+// of the other fondos of the user keep working. The change is audited
+func (s *UserService) ChangeRole(idFondo int, id int, idRol int, idActor int) error {
+	s.l.Info("[ChangeRole] Changing role of", "user", id, "fondo", idFondo, "rol", idRol, "actor", idActor)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	anterior := 0
+	rows, err := tx.Query("SELECT idRol FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ? FOR UPDATE", idFondo, id)
+	if err != nil {
+		return err
+	}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&anterior)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrProductNotFound
+	}
+
+	if anterior == idRol {
+		return nil
+	}
+
+	if anterior == 1 {
+		err = checkLastAdmin(tx, idFondo)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec("UPDATE fondo_usuario SET idRol = ?, tokenVersion = tokenVersion + 1 WHERE idFondo = ? AND idUsuario = ?", idRol, idFondo, id)
+	if err != nil {
+		return err
+	}
+
+	err = writeAudit(tx, id, idFondo, idActor, AuditRol, fmt.Sprintf("fondo %d: %d", idFondo, anterior), fmt.Sprintf("fondo %d: %d", idFondo, idRol))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkLastAdmin returns ErrLastAdmin when the fondo has a single active administrator. The administrators
+// stay locked until the transaction ends, so two of them can't be removed at the same time
+func checkLastAdmin(tx *sql.Tx, idFondo int) error {
+	admins := 0
+	rows, err := tx.Query("SELECT idUsuario FROM fondo_usuario WHERE idFondo = ? AND idRol = 1 AND activo = 1 FOR UPDATE", idFondo)
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+		admins++
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if admins <= 1 {
+		return ErrLastAdmin
+	}
+
+	return nil
+}
+
+// GetTokenState returns the current token versions of an user and its membership to the fondo
+// and whether the user is active in the fondo
+func (s *UserService) GetTokenState(id int, idFondo int) (TokenState, error) {
+	t := TokenState{}
+	rows, err := s.DB.Query(`SELECT u.tokenVersion, COALESCE(fu.tokenVersion, 0), COALESCE(fu.activo, 0), COALESCE(fu.estado, '') FROM usuario u
+		LEFT JOIN fondo_usuario fu ON fu.idUsuario = u.id AND fu.idFondo = ? WHERE u.id = ?`, idFondo, id)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&t.Version, &t.FondoVersion, &t.Activo, &t.Estado)
+
+		return t, err
+	}
+
+	return t, ErrProductNotFound
+}
@@ -0,0 +1,127 @@
+package data
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestDeactivateUserLastAdmin(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol, activo FROM fondo_usuario WHERE idFondo = \\? AND idUsuario = \\? FOR UPDATE").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idRol", "activo"}).AddRow(1, true))
+	mock.ExpectQuery("SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\? AND idRol = 1 AND activo = 1 FOR UPDATE").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+	mock.ExpectRollback()
+
+	if err := s.DeactivateUser(2, 7, 1); err != ErrLastAdmin {
+		t.Errorf("DeactivateUser error = %v, want %v", err, ErrLastAdmin)
+	}
+}
+
+func TestDeactivateUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		idRol  int
+		admins int
+	}{
+		{"member", 3, 0},
+		{"one of two administrators", 1, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT idRol, activo FROM fondo_usuario").WithArgs(2, 7).
+				WillReturnRows(sqlmock.NewRows([]string{"idRol", "activo"}).AddRow(tt.idRol, true))
+			if tt.idRol == 1 {
+				admins := sqlmock.NewRows([]string{"idUsuario"})
+				for i := 0; i < tt.admins; i++ {
+					admins.AddRow(i + 1)
+				}
+				mock.ExpectQuery("SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\? AND idRol = 1").WithArgs(2).WillReturnRows(admins)
+			}
+			mock.ExpectExec("UPDATE fondo_usuario SET activo = 0, tokenVersion = tokenVersion \\+ 1").WithArgs(2, 7).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1").WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("UPDATE sesiones SET revocada = 1").WithArgs(7, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO usuario_auditoria").
+				WithArgs(7, int64(2), 1, AuditDesactivar, "fondo 2: 1", "fondo 2: 0", sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectCommit()
+
+			if err := s.DeactivateUser(2, 7, 1); err != nil {
+				t.Errorf("DeactivateUser error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDeactivateUserNotFound(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol, activo FROM fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idRol", "activo"}))
+	mock.ExpectRollback()
+
+	if err := s.DeactivateUser(2, 7, 1); err != ErrProductNotFound {
+		t.Errorf("DeactivateUser error = %v, want %v", err, ErrProductNotFound)
+	}
+}
+
+func TestChangeRole(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol FROM fondo_usuario WHERE idFondo = \\? AND idUsuario = \\? FOR UPDATE").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idRol"}).AddRow(3))
+	// the tokens issued for the fondo with the previous rol stop being accepted
+	mock.ExpectExec("UPDATE fondo_usuario SET idRol = \\?, tokenVersion = tokenVersion \\+ 1 WHERE idFondo = \\? AND idUsuario = \\?").WithArgs(2, 2, 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO usuario_auditoria").WithArgs(7, int64(2), 1, AuditRol, "fondo 2: 3", "fondo 2: 2", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := s.ChangeRole(2, 7, 2, 1); err != nil {
+		t.Errorf("ChangeRole error = %v", err)
+	}
+}
+
+func TestChangeRoleUnchanged(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol FROM fondo_usuario").WithArgs(2, 7).WillReturnRows(sqlmock.NewRows([]string{"idRol"}).AddRow(3))
+	mock.ExpectRollback()
+
+	if err := s.ChangeRole(2, 7, 3, 1); err != nil {
+		t.Errorf("ChangeRole error = %v", err)
+	}
+}
+
+func TestChangeRoleLastAdmin(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol FROM fondo_usuario").WithArgs(2, 7).WillReturnRows(sqlmock.NewRows([]string{"idRol"}).AddRow(1))
+	mock.ExpectQuery("SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\? AND idRol = 1 AND activo = 1 FOR UPDATE").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+	mock.ExpectRollback()
+
+	if err := s.ChangeRole(2, 7, 3, 1); err != ErrLastAdmin {
+		t.Errorf("ChangeRole error = %v, want %v", err, ErrLastAdmin)
+	}
+}
+
+func TestGetTokenState(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("FROM usuario u\\s+LEFT JOIN fondo_usuario fu ON fu.idUsuario = u.id AND fu.idFondo = \\? WHERE u.id = \\?").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"ver", "fver", "activo", "estado"}).AddRow(4, 1, true, EstadoAprobado))
+
+	state, err := s.GetTokenState(7, 2)
+	if err != nil {
+		t.Fatalf("GetTokenState error = %v", err)
+	}
+	if want := (TokenState{Version: 4, FondoVersion: 1, Activo: true, Estado: EstadoAprobado}); state != want {
+		t.Errorf("GetTokenState = %+v, want %+v", state, want)
+	}
+}
@@ -1,36 +1,63 @@
 package data
 
 import (
-	"log"
-
-	"golang.org/x/crypto/bcrypt"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 )
 
+// hashAndSalt hashes a password following the hash policy of the service
 func (s *UserService) hashAndSalt(pwd []byte) (string, error) {
+	return s.hp.Hash(pwd)
+}
 
-	// Use GenerateFromPassword to hash & salt pwd.
-	// MinCost is just an integer constant provided by the bcrypt
-	// package along with DefaultCost & MaxCost.
-	// The cost can be any value you want provided it isn't lower
-	// than the MinCost (4)
-	hash, err := bcrypt.GenerateFromPassword(pwd, bcrypt.MinCost)
+// CheckPassword compares a password with the stored hash of the user. When it matches and the
+// hash is below the current policy the password is hashed again, so users are upgraded on signin
+func (s *UserService) CheckPassword(user *UserSignin, pwd string) bool {
+	ok, err := comparePassword(user.Contrasena, []byte(pwd))
 	if err != nil {
-		return "", err
+		s.l.Error("[CheckPassword] Comparing password", "user", user.ID, "error", err)
+		return false
+	}
+	if !ok || !s.hp.NeedsRehash(user.Contrasena) {
+		return ok
 	}
-	// GenerateFromPassword returns a byte slice so we need to
-	// convert the bytes to a string and return it
-	return string(hash), nil
+
+	hash, err := s.hashAndSalt([]byte(pwd))
+	if err != nil {
+		s.l.Error("[CheckPassword] Rehashing password", "user", user.ID, "error", err)
+		return true
+	}
+
+	// the condition on the old hash keeps a password changed meanwhile
+	_, err = s.DB.Exec("UPDATE usuario SET contrasena = ? WHERE id = ? AND contrasena = ?", hash, user.ID, user.Contrasena)
+	if err != nil {
+		s.l.Error("[CheckPassword] Storing rehashed password", "user", user.ID, "error", err)
+		return true
+	}
+	s.l.Info("[CheckPassword] Password rehashed", "user", user.ID, "algorithm", s.hp.Algorithm)
+	user.Contrasena = hash
+
+	return true
 }
 
-func comparePasswords(hashedPwd string, plainPwd []byte) (bool, error) {
-	// Since we'll be getting the hashed password from the DB it
-	// will be a string so we'll need to convert it to a byte slice
-	byteHash := []byte(hashedPwd)
-	err := bcrypt.CompareHashAndPassword(byteHash, plainPwd)
+// NewOpaqueToken returns a random url safe string used for ids and tokens
+// that are handed to the client
+func NewOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
 	if err != nil {
-		log.Println(err)
-		return false, err
+		return "", err
 	}
 
-	return true, nil
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// HashToken returns the hex encoded sha256 of a token, only this hash is
+// stored in the database so a leaked table can't be used to sign in
+func HashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+
+	return hex.EncodeToString(sum[:])
 }
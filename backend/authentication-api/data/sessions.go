@@ -0,0 +1,282 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ErrSessionNotFound is raised when a session does not exist or belongs to another user
+var ErrSessionNotFound = fmt.Errorf("Session not found")
+
+// Results of a signin attempt stored on login_events
+const (
+	LoginSuccess     = "success"
+	LoginMFARequired = "mfa_required"
+	LoginBadPassword = "bad_password"
+	LoginBadCode     = "bad_code"
+	LoginUnknownUser = "unknown_user"
+	LoginThrottled   = "throttled"
+	LoginDeactivated = "deactivated"
+	LoginUnverified  = "unverified"
+	LoginRejected    = "rejected"
+)
+
+// LoginEvent describes a signin attempt
+type LoginEvent struct {
+	ID        int       `json:"id"`
+	IDUsuario *int      `json:"idUsuario"`
+	Usuario   string    `json:"usuario"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Resultado string    `json:"resultado"`
+	Fecha     time.Time `json:"fecha"`
+}
+
+// LoginFilter describes the search and paging of the signin attempts
+type LoginFilter struct {
+	IDFondo   int
+	IDUsuario int
+	Usuario   string
+	Resultado string
+	IP        string
+	Desde     time.Time
+	Hasta     time.Time
+	Page      int
+	PageSize  int
+}
+
+// LoginPage is a page of signin attempts
+type LoginPage struct {
+	Logins   []*LoginEvent `json:"logins"`
+	Total    int           `json:"total"`
+	Page     int           `json:"page"`
+	PageSize int           `json:"pageSize"`
+}
+
+// Session describes a signin of an user, it lasts while its refresh tokens are valid
+type Session struct {
+	ID        int       `json:"id"`
+	Familia   string    `json:"-"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"userAgent"`
+	Creada    time.Time `json:"creada"`
+	UltimoUso time.Time `json:"ultimoUso"`
+	// Actual is true for the session of the token used on the request
+	Actual bool `json:"actual"`
+}
+
+// Sessions is a list of Session
+type Sessions []*Session
+
+// RecordLoginEvent stores a signin attempt
+func (s *UserService) RecordLoginEvent(e *LoginEvent) error {
+	_, err := s.DB.Exec("INSERT INTO login_events (idUsuario, usuario, ip, userAgent, resultado, fecha) VALUES (?, ?, ?, ?, ?, ?)",
+		e.IDUsuario,
+		e.Usuario,
+		e.IP,
+		truncate(e.UserAgent, 255),
+		e.Resultado,
+		time.Now())
+
+	return err
+}
+
+// GetLoginEvents returns a page of signin attempts, newest first
+func (s *UserService) GetLoginEvents(f LoginFilter) (LoginPage, error) {
+	s.l.Info("[GetLoginEvents] Getting signin attempts", "user", f.IDUsuario, "page", f.Page)
+
+	page := LoginPage{Logins: []*LoginEvent{}, Page: f.Page, PageSize: f.PageSize}
+
+	where := "WHERE 1 = 1"
+	args := []interface{}{}
+	if f.IDFondo != 0 {
+		where += " AND idUsuario IN (SELECT idUsuario FROM fondo_usuario WHERE idFondo = ?)"
+		args = append(args, f.IDFondo)
+	}
+	if f.IDUsuario != 0 {
+		where += " AND idUsuario = ?"
+		args = append(args, f.IDUsuario)
+	}
+	if f.Usuario != "" {
+		where += " AND usuario = ?"
+		args = append(args, f.Usuario)
+	}
+	if f.Resultado != "" {
+		where += " AND resultado = ?"
+		args = append(args, f.Resultado)
+	}
+	if f.IP != "" {
+		where += " AND ip = ?"
+		args = append(args, f.IP)
+	}
+	if !f.Desde.IsZero() {
+		where += " AND fecha >= ?"
+		args = append(args, f.Desde)
+	}
+	if !f.Hasta.IsZero() {
+		where += " AND fecha < ?"
+		args = append(args, f.Hasta)
+	}
+
+	rows, err := s.DB.Query("SELECT COUNT(*) FROM login_events "+where, args...)
+	if err != nil {
+		return page, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&page.Total)
+	}
+	rows.Close()
+	if err != nil {
+		return page, err
+	}
+
+	args = append(args, f.PageSize, (f.Page-1)*f.PageSize)
+	rows, err = s.DB.Query("SELECT id, idUsuario, usuario, ip, userAgent, resultado, fecha FROM login_events "+where+" ORDER BY fecha DESC, id DESC LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return page, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		e := &LoginEvent{}
+		var id sql.NullInt64
+		err = rows.Scan(&e.ID, &id, &e.Usuario, &e.IP, &e.UserAgent, &e.Resultado, &e.Fecha)
+		if err != nil {
+			return page, err
+		}
+		if id.Valid {
+			idUsuario := int(id.Int64)
+			e.IDUsuario = &idUsuario
+		}
+
+		page.Logins = append(page.Logins, e)
+	}
+
+	return page, rows.Err()
+}
+
+// CreateSession stores the session started by a signin with its active fondo
+func (s *UserService) CreateSession(familia string, idUsuario int, idFondo int, ip string, userAgent string) error {
+	s.l.Info("[CreateSession] Starting session for", "user", idUsuario, "fondo", idFondo, "ip", ip)
+
+	now := time.Now()
+	_, err := s.DB.Exec("INSERT INTO sesiones (familia, idUsuario, idFondo, ip, userAgent, creada, ultimoUso) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		familia, idUsuario, idFondo, ip, truncate(userAgent, 255), now, now)
+
+	return err
+}
+
+// GetSessions returns the sessions of an user that can still be refreshed
+func (s *UserService) GetSessions(idUsuario int) (Sessions, error) {
+	sessions := Sessions{}
+	rows, err := s.DB.Query(`SELECT s.id, s.familia, s.ip, s.userAgent, s.creada, s.ultimoUso FROM sesiones s
+		WHERE s.idUsuario = ? AND s.revocada = 0
+		AND EXISTS (SELECT 1 FROM refresh_tokens r WHERE r.familia = s.familia AND r.revocado = 0 AND r.expira > ?)
+		ORDER BY s.ultimoUso DESC`, idUsuario, time.Now())
+	if err != nil {
+		return sessions, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		ss := &Session{}
+		err = rows.Scan(&ss.ID, &ss.Familia, &ss.IP, &ss.UserAgent, &ss.Creada, &ss.UltimoUso)
+		if err != nil {
+			return sessions, err
+		}
+
+		sessions = append(sessions, ss)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession revokes a session of an user and every refresh token of its familia
+func (s *UserService) RevokeSession(id int, idUsuario int) error {
+	s.l.Info("[RevokeSession] Revoking session", "session", id, "user", idUsuario)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var familia string
+	rows, err := tx.Query("SELECT familia FROM sesiones WHERE id = ? AND idUsuario = ? FOR UPDATE", id, idUsuario)
+	if err != nil {
+		return err
+	}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&familia)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrSessionNotFound
+	}
+
+	err = revokeFamilia(tx, familia)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsSessionRevoked returns true if the session of the given familia was revoked
+func (s *UserService) IsSessionRevoked(familia string) (bool, error) {
+	rows, err := s.DB.Query("SELECT familia FROM sesiones WHERE familia = ? AND revocada = 1", familia)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// execer is implemented by both *sql.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// revokeFamilia revokes a session and every refresh token of its familia
+func revokeFamilia(e execer, familia string) error {
+	_, err := e.Exec("UPDATE refresh_tokens SET revocado = 1 WHERE familia = ?", familia)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Exec("UPDATE sesiones SET revocada = 1 WHERE familia = ?", familia)
+
+	return err
+}
+
+// closeSessions revokes every session and refresh token of an user and increments its token
+// version, so the access tokens already issued stop being accepted on every fondo
+func closeSessions(e execer, idUsuario int) error {
+	_, err := e.Exec("UPDATE usuario SET tokenVersion = tokenVersion + 1 WHERE id = ?", idUsuario)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Exec("UPDATE refresh_tokens SET revocado = 1 WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.Exec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = ?", idUsuario)
+
+	return err
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+
+	return s
+}
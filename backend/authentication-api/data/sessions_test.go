@@ -0,0 +1,70 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRevokeSession(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT familia FROM sesiones WHERE id = \\? AND idUsuario = \\? FOR UPDATE").WithArgs(3, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"familia"}).AddRow("fam"))
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE familia = \\?").WithArgs("fam").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sesiones SET revocada = 1 WHERE familia = \\?").WithArgs("fam").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.RevokeSession(3, 7); err != nil {
+		t.Errorf("RevokeSession error = %v", err)
+	}
+}
+
+func TestRevokeSessionOfOtherUser(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT familia FROM sesiones WHERE id = \\? AND idUsuario = \\? FOR UPDATE").WithArgs(3, 8).
+		WillReturnRows(sqlmock.NewRows([]string{"familia"}))
+	mock.ExpectRollback()
+
+	if err := s.RevokeSession(3, 8); err != ErrSessionNotFound {
+		t.Errorf("RevokeSession error = %v, want %v", err, ErrSessionNotFound)
+	}
+}
+
+func TestGetLoginEvents(t *testing.T) {
+	s, mock := newMockService(t)
+	desde := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	f := LoginFilter{IDFondo: 2, Resultado: LoginBadPassword, Desde: desde, Page: 2, PageSize: 10}
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM login_events WHERE 1 = 1 AND idUsuario IN \\(SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\?\\) AND resultado = \\? AND fecha >= \\?").
+		WithArgs(2, LoginBadPassword, desde).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(11))
+	mock.ExpectQuery("ORDER BY fecha DESC, id DESC LIMIT \\? OFFSET \\?").
+		WithArgs(2, LoginBadPassword, desde, 10, 10).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "idUsuario", "usuario", "ip", "userAgent", "resultado", "fecha"}).
+			AddRow(5, 7, "ana", "192.0.2.1", "curl", LoginBadPassword, time.Now()).
+			AddRow(4, nil, "nadie", "192.0.2.1", "curl", LoginBadPassword, time.Now()))
+
+	page, err := s.GetLoginEvents(f)
+	if err != nil {
+		t.Fatalf("GetLoginEvents error = %v", err)
+	}
+	if page.Total != 11 || len(page.Logins) != 2 {
+		t.Fatalf("GetLoginEvents = total %d with %d logins, want 11 with 2", page.Total, len(page.Logins))
+	}
+	// attempts of unknown users have no idUsuario
+	if page.Logins[0].IDUsuario == nil || *page.Logins[0].IDUsuario != 7 || page.Logins[1].IDUsuario != nil {
+		t.Errorf("GetLoginEvents usuarios = %v, %v, want 7 and none", page.Logins[0].IDUsuario, page.Logins[1].IDUsuario)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("Mozilla", 3); got != "Moz" {
+		t.Errorf("truncate = %q, want %q", got, "Moz")
+	}
+	if got := truncate("curl", 255); got != "curl" {
+		t.Errorf("truncate = %q, want %q", got, "curl")
+	}
+}
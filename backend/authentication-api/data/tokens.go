@@ -0,0 +1,178 @@
+package data
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ErrTokenNotFound is raised when a refresh token can not be found in the database
+var ErrTokenNotFound = fmt.Errorf("Token not found")
+
+// ErrTokenRevoked is raised when a refresh token was already used or revoked
+var ErrTokenRevoked = fmt.Errorf("Token has been revoked")
+
+// ErrTokenExpired is raised when a refresh token is past its expiration date
+var ErrTokenExpired = fmt.Errorf("Token has expired")
+
+// RefreshToken describes a refresh token stored in the database
+type RefreshToken struct {
+	ID        int
+	IDUsuario int
+	Familia   string
+	Amr       string
+	Expira    time.Time
+	Revocado  bool
+}
+
+// RefreshRequest is the body sent to refresh or revoke a session
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// CreateRefreshToken stores a new refresh token for the user and returns it,
+// familia groups every token rotated from the same signin and amr holds the
+// authentication methods used on that signin
+func (s *UserService) CreateRefreshToken(idUsuario int, familia string, amr string, ttl time.Duration) (string, error) {
+	s.l.Info("[CreateRefreshToken] Creating refresh token for", "user", idUsuario)
+
+	token, err := NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.DB.Exec("INSERT INTO refresh_tokens (idUsuario, tokenHash, familia, amr, expira) VALUES (?, ?, ?, ?, ?)",
+		idUsuario,
+		HashToken(token),
+		familia,
+		amr,
+		time.Now().Add(ttl))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken revokes the given refresh token and issues a new one in the same familia.
+// Presenting a token that was already rotated revokes the whole familia, since it means
+// the token was copied by someone else
+func (s *UserService) RotateRefreshToken(token string, ttl time.Duration) (RefreshToken, string, error) {
+	s.l.Info("[RotateRefreshToken] Rotating refresh token")
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return RefreshToken{}, "", err
+	}
+	defer tx.Rollback()
+
+	rt, err := getRefreshToken(tx, token)
+	if err != nil {
+		return rt, "", err
+	}
+
+	if rt.Revocado {
+		s.l.Info("[RotateRefreshToken] Revoked refresh token reused, revoking familia", "user", rt.IDUsuario)
+		err = revokeFamilia(tx, rt.Familia)
+		if err != nil {
+			return rt, "", err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return rt, "", err
+		}
+
+		return rt, "", ErrTokenRevoked
+	}
+
+	if time.Now().After(rt.Expira) {
+		return rt, "", ErrTokenExpired
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET revocado = 1 WHERE id = ?", rt.ID)
+	if err != nil {
+		return rt, "", err
+	}
+
+	_, err = tx.Exec("UPDATE sesiones SET ultimoUso = ? WHERE familia = ?", time.Now(), rt.Familia)
+	if err != nil {
+		return rt, "", err
+	}
+
+	newToken, err := NewOpaqueToken()
+	if err != nil {
+		return rt, "", err
+	}
+
+	_, err = tx.Exec("INSERT INTO refresh_tokens (idUsuario, tokenHash, familia, amr, expira) VALUES (?, ?, ?, ?, ?)",
+		rt.IDUsuario,
+		HashToken(newToken),
+		rt.Familia,
+		rt.Amr,
+		time.Now().Add(ttl))
+	if err != nil {
+		return rt, "", err
+	}
+
+	return rt, newToken, tx.Commit()
+}
+
+// RevokeRefreshToken revokes every token in the familia of the given refresh token
+// as long as it belongs to the given user
+func (s *UserService) RevokeRefreshToken(token string, idUsuario int) error {
+	s.l.Info("[RevokeRefreshToken] Revoking refresh token for", "user", idUsuario)
+
+	rt, err := getRefreshToken(s.DB, token)
+	if err != nil {
+		return err
+	}
+
+	if rt.IDUsuario != idUsuario {
+		return ErrTokenNotFound
+	}
+
+	return revokeFamilia(s.DB, rt.Familia)
+}
+
+// RevokeAccessToken adds the jti of an access token to the denylist until it expires
+func (s *UserService) RevokeAccessToken(jti string, expira time.Time) error {
+	s.l.Info("[RevokeAccessToken] Revoking access token", "jti", jti)
+
+	_, err := s.DB.Exec("INSERT IGNORE INTO revoked_tokens (jti, expira) VALUES (?, ?)", jti, expira)
+
+	return err
+}
+
+// IsAccessTokenRevoked returns true if the jti of an access token is on the denylist
+func (s *UserService) IsAccessTokenRevoked(jti string) (bool, error) {
+	rows, err := s.DB.Query("SELECT jti FROM revoked_tokens WHERE jti = ?", jti)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	return rows.Next(), rows.Err()
+}
+
+// queryer is implemented by both *sql.DB and *sql.Tx
+type queryer interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func getRefreshToken(q queryer, token string) (RefreshToken, error) {
+	rt := RefreshToken{}
+	rows, err := q.Query("SELECT id, idUsuario, familia, amr, expira, revocado FROM refresh_tokens WHERE tokenHash = ? FOR UPDATE", HashToken(token))
+	if err != nil {
+		return rt, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&rt.ID, &rt.IDUsuario, &rt.Familia, &rt.Amr, &rt.Expira, &rt.Revocado)
+
+		return rt, err
+	}
+
+	return rt, ErrTokenNotFound
+}
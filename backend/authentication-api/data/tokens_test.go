@@ -0,0 +1,82 @@
+package data
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var refreshColumns = []string{"id", "idUsuario", "familia", "amr", "expira", "revocado"}
+
+func TestRotateRefreshToken(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens WHERE tokenHash = \\? FOR UPDATE").WithArgs(HashToken("viejo")).
+		WillReturnRows(sqlmock.NewRows(refreshColumns).AddRow(3, 7, "familia", "pwd", time.Now().Add(time.Hour), false))
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE id = \\?").WithArgs(3).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE sesiones SET ultimoUso = \\? WHERE familia = \\?").WithArgs(sqlmock.AnyArg(), "familia").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO refresh_tokens").WithArgs(7, sqlmock.AnyArg(), "familia", "pwd", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(4, 1))
+	mock.ExpectCommit()
+
+	rt, nuevo, err := s.RotateRefreshToken("viejo", time.Hour)
+	if err != nil {
+		t.Fatalf("RotateRefreshToken error = %v", err)
+	}
+	if nuevo == "" || nuevo == "viejo" || rt.Familia != "familia" {
+		t.Errorf("RotateRefreshToken = %q on familia %q, want a new token on familia", nuevo, rt.Familia)
+	}
+}
+
+func TestRotateRefreshTokenReuse(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("FROM refresh_tokens WHERE tokenHash = \\?").WithArgs(HashToken("rotado")).
+		WillReturnRows(sqlmock.NewRows(refreshColumns).AddRow(3, 7, "familia", "pwd", time.Now().Add(time.Hour), true))
+	// a rotated token presented again was stolen, the whole familia is revoked
+	mock.ExpectExec("UPDATE refresh_tokens SET revocado = 1 WHERE familia = \\?").WithArgs("familia").WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("UPDATE sesiones SET revocada = 1 WHERE familia = \\?").WithArgs("familia").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, nuevo, err := s.RotateRefreshToken("rotado", time.Hour)
+	if err != ErrTokenRevoked || nuevo != "" {
+		t.Errorf("RotateRefreshToken = %q, %v, want no token and %v", nuevo, err, ErrTokenRevoked)
+	}
+}
+
+func TestRotateRefreshTokenInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want error
+	}{
+		{"unknown", sqlmock.NewRows(refreshColumns), ErrTokenNotFound},
+		{"expired", sqlmock.NewRows(refreshColumns).AddRow(3, 7, "familia", "pwd", time.Now().Add(-time.Minute), false), ErrTokenExpired},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("FROM refresh_tokens WHERE tokenHash = \\?").WillReturnRows(tt.rows)
+			mock.ExpectRollback()
+
+			_, _, err := s.RotateRefreshToken("token", time.Hour)
+			if err != tt.want {
+				t.Errorf("RotateRefreshToken error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevokeRefreshTokenOfOtherUser(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("FROM refresh_tokens WHERE tokenHash = \\?").
+		WillReturnRows(sqlmock.NewRows(refreshColumns).AddRow(3, 7, "familia", "pwd", time.Now().Add(time.Hour), false))
+
+	if err := s.RevokeRefreshToken("token", 8); err != ErrTokenNotFound {
+		t.Errorf("RevokeRefreshToken error = %v, want %v", err, ErrTokenNotFound)
+	}
+}
@@ -0,0 +1,240 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrTOTPNotFound is raised when an user has not enrolled a TOTP secret
+var ErrTOTPNotFound = fmt.Errorf("Two factor authentication not enrolled")
+
+// ErrTOTPEnabled is raised when trying to enroll an user that already has two factor authentication
+var ErrTOTPEnabled = fmt.Errorf("Two factor authentication already enabled")
+
+// ErrInvalidCode is raised when a TOTP or recovery code does not match
+var ErrInvalidCode = fmt.Errorf("Invalid code")
+
+// totpPeriod is the time step of the codes as recommended by RFC 6238
+const totpPeriod = 30
+
+// totpDigits is the length of the codes
+const totpDigits = 6
+
+// recoveryCodes is the number of recovery codes generated when enabling two factor authentication
+const recoveryCodes = 10
+
+// TOTP describes the two factor authentication secret of an user
+type TOTP struct {
+	Secreto    string
+	Activo     bool
+	UltimoPaso int64
+}
+
+// TOTPCode is the body sent to confirm or disable two factor authentication
+type TOTPCode struct {
+	Codigo string `json:"codigo" validate:"required"`
+}
+
+// TOTPSignin is the body sent on the second step of a signin
+type TOTPSignin struct {
+	MFAToken string `json:"mfaToken" validate:"required"`
+	Codigo   string `json:"codigo" validate:"required"`
+}
+
+// NewTOTPSecret returns a random base32 secret, as expected by authenticator apps
+func NewTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b), nil
+}
+
+// ValidateTOTP checks a code against the secret allowing one step of clock drift.
+// Steps up to lastStep are rejected so a code can't be used twice, the matched step is returned
+func ValidateTOTP(secret string, code string, now time.Time, lastStep int64) (int64, bool) {
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - 1; step <= current+1; step++ {
+		if step <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+
+	return 0, false
+}
+
+// totpCode computes the HOTP value (RFC 4226) for the given time step
+func totpCode(key []byte, step int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// GetTOTP returns the two factor authentication secret of an user
+func (s *UserService) GetTOTP(idUsuario int) (TOTP, error) {
+	t := TOTP{}
+	rows, err := s.DB.Query("SELECT secreto, activo, ultimoPaso FROM usuario_totp WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&t.Secreto, &t.Activo, &t.UltimoPaso)
+
+		return t, err
+	}
+
+	return t, ErrTOTPNotFound
+}
+
+// CreateTOTP stores a new secret for the user, it is not used on signin until it is enabled
+func (s *UserService) CreateTOTP(idUsuario int) (string, error) {
+	s.l.Info("[CreateTOTP] Enrolling two factor authentication for", "user", idUsuario)
+
+	t, err := s.GetTOTP(idUsuario)
+	if err == nil && t.Activo {
+		return "", ErrTOTPEnabled
+	}
+	if err != nil && err != ErrTOTPNotFound {
+		return "", err
+	}
+
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		return "", err
+	}
+
+	_, err = s.DB.Exec(`INSERT INTO usuario_totp (idUsuario, secreto, activo, ultimoPaso) VALUES (?, ?, 0, 0)
+		ON DUPLICATE KEY UPDATE secreto = VALUES(secreto), activo = 0, ultimoPaso = 0`, idUsuario, secret)
+	if err != nil {
+		return "", err
+	}
+
+	return secret, nil
+}
+
+// EnableTOTP enables two factor authentication and returns a new set of recovery codes,
+// only their hashes are stored so they can't be shown again
+func (s *UserService) EnableTOTP(idUsuario int, step int64) ([]string, error) {
+	s.l.Info("[EnableTOTP] Enabling two factor authentication for", "user", idUsuario)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("UPDATE usuario_totp SET activo = 1, ultimoPaso = ? WHERE idUsuario = ?", step, idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec("DELETE FROM usuario_codigos_recuperacion WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return nil, err
+	}
+
+	codes := []string{}
+	for i := 0; i < recoveryCodes; i++ {
+		b := make([]byte, 5)
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, err
+		}
+
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		code = code[:4] + "-" + code[4:]
+		codes = append(codes, code)
+
+		_, err = tx.Exec("INSERT INTO usuario_codigos_recuperacion (idUsuario, codigoHash) VALUES (?, ?)", idUsuario, HashToken(normalizeRecoveryCode(code)))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return codes, tx.Commit()
+}
+
+// DisableTOTP removes the two factor authentication secret and recovery codes of an user
+func (s *UserService) DisableTOTP(idUsuario int) error {
+	s.l.Info("[DisableTOTP] Disabling two factor authentication for", "user", idUsuario)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("DELETE FROM usuario_codigos_recuperacion WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("DELETE FROM usuario_totp WHERE idUsuario = ?", idUsuario)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// VerifyTOTP checks a TOTP code, or a recovery code, for an user with two factor
+// authentication enabled. Used codes can't be used again
+func (s *UserService) VerifyTOTP(idUsuario int, code string) error {
+	t, err := s.GetTOTP(idUsuario)
+	if err != nil {
+		return err
+	}
+	if !t.Activo {
+		return ErrTOTPNotFound
+	}
+
+	step, ok := ValidateTOTP(t.Secreto, code, time.Now(), t.UltimoPaso)
+	if ok {
+		// the condition on ultimoPaso makes concurrent requests with the same code fail
+		res, err := s.DB.Exec("UPDATE usuario_totp SET ultimoPaso = ? WHERE idUsuario = ? AND ultimoPaso < ?", step, idUsuario, step)
+		if err != nil {
+			return err
+		}
+
+		return expectOneRow(res, ErrInvalidCode)
+	}
+
+	res, err := s.DB.Exec("UPDATE usuario_codigos_recuperacion SET usado = 1 WHERE idUsuario = ? AND codigoHash = ? AND usado = 0",
+		idUsuario, HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res, ErrInvalidCode)
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
@@ -0,0 +1,87 @@
+package data
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 secret of the test vectors of RFC 6238, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestValidateTOTP(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		now      int64
+		lastStep int64
+		step     int64
+		ok       bool
+	}{
+		{"rfc vector 59", rfcSecret, "287082", 59, 0, 1, true},
+		{"rfc vector 1111111109", rfcSecret, "081804", 1111111109, 0, 37037036, true},
+		{"rfc vector 1234567890", rfcSecret, "005924", 1234567890, 0, 41152263, true},
+		{"lower case secret", strings.ToLower(rfcSecret), "287082", 59, 0, 1, true},
+		{"previous step", rfcSecret, "287082", 89, 0, 1, true},
+		{"next step", rfcSecret, "287082", 29, -1, 1, true},
+		{"two steps old", rfcSecret, "287082", 119, 0, 0, false},
+		{"step already used", rfcSecret, "287082", 59, 1, 0, false},
+		{"wrong code", rfcSecret, "287083", 59, 0, 0, false},
+		{"short code", rfcSecret, "28708", 59, 0, 0, false},
+		{"long code", rfcSecret, "94287082", 59, 0, 0, false},
+		{"invalid secret", "not base32!", "287082", 59, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := ValidateTOTP(tt.secret, tt.code, time.Unix(tt.now, 0), tt.lastStep)
+			if step != tt.step || ok != tt.ok {
+				t.Errorf("ValidateTOTP(%q, %d) = %d, %v, want %d, %v", tt.code, tt.now, step, ok, tt.step, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewTOTPSecret(t *testing.T) {
+	secret, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if secret == other {
+		t.Error("NewTOTPSecret returned the same secret twice")
+	}
+
+	// the code an authenticator app shows for the secret is accepted
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	if err != nil {
+		t.Fatalf("NewTOTPSecret is not base32: %v", err)
+	}
+	now := time.Now()
+	step := now.Unix() / totpPeriod
+	if got, ok := ValidateTOTP(secret, totpCode(key, step), now, 0); !ok || got != step {
+		t.Errorf("ValidateTOTP of the current code = %d, %v, want %d, true", got, ok, step)
+	}
+}
+
+func TestNormalizeRecoveryCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"abcd-efgh", "abcdefgh"},
+		{" ABCD-EFGH ", "abcdefgh"},
+		{"abcdefgh", "abcdefgh"},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeRecoveryCode(tt.code); got != tt.want {
+			t.Errorf("normalizeRecoveryCode(%q) = %q, want %q", tt.code, got, tt.want)
+		}
+	}
+}
@@ -3,20 +3,94 @@ package data
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/hashicorp/go-hclog"
 )
 
+// mysqlDuplicateEntry is the MySQL error number of a duplicate key
+const mysqlDuplicateEntry = 1062
+
 // ErrProductNotFound is an error raised when a product can not be found in the database
 var ErrProductNotFound = fmt.Errorf("Product not found")
 
+// ErrUserNotPending is raised when approving or rejecting an user that was already decided
+var ErrUserNotPending = fmt.Errorf("User is not pending approval")
+
+// Estados of an user
+const (
+	EstadoPendiente = "pendiente"
+	EstadoAprobado  = "aprobado"
+	EstadoRechazado = "rechazado"
+)
+
+// ErrUsuarioTaken is raised when the usuario of an user is already used by another one
+var ErrUsuarioTaken = fmt.Errorf("Username already taken")
+
+// ErrEmailTaken is raised when the email of an user is already used by another one
+var ErrEmailTaken = fmt.Errorf("Email already taken")
+
+// ErrCedulaTaken is raised when the cedula of an user is already used by another one
+var ErrCedulaTaken = fmt.Errorf("Cedula already taken")
+
+// ErrUsuarioCompartido is raised when an administrator changes the account of an user that belongs to other
+// fondos, the email, usuario, cedula and password of the account are shared by all of them
+var ErrUsuarioCompartido = fmt.Errorf("User belongs to other fondos, only the user can change its account")
+
 // User define la estructura de un usuario para el API
 type User struct {
-	ID   int    `json:"id"`
-	Name string `json:"name" validate:"required"`
+	ID         int    `json:"id"`
+	Nombre     string `json:"nombre"`
+	Celular    string `json:"celular"`
+	Email      string `json:"email"`
+	Usuario    string `json:"usuario"`
+	IDRol      int    `json:"idRol"`
+	Verificado bool   `json:"verificado"`
+	// Activo, Estado and MotivoEstado are the ones of the membership to the fondo
+	Activo bool `json:"activo"`
+	// IDInvitacion is the invitation used on signup, nil for members that joined before invitations
+	IDInvitacion *int   `json:"idInvitacion"`
+	Estado       string `json:"estado"`
+	MotivoEstado string `json:"motivoEstado"`
+	// Cedula, Direccion and FechaNacimiento (YYYY-MM-DD) are empty for members that joined before they were asked
+	Cedula          string `json:"cedula"`
+	Direccion       string `json:"direccion"`
+	FechaNacimiento string `json:"fechaNacimiento"`
+}
+
+// UserUpdate defines the fields of an user an administrator can change
+type UserUpdate struct {
+	Nombre          string `json:"nombre" validate:"required"`
+	Celular         string `json:"celular"`
+	Email           string `json:"email" validate:"required,email"`
+	Usuario         string `json:"usuario" validate:"required,excludes=@"`
+	Cedula          string `json:"cedula" validate:"omitempty,numeric,min=5,max=15"`
+	Direccion       string `json:"direccion" validate:"max=255"`
+	FechaNacimiento string `json:"fechaNacimiento" validate:"omitempty,fecha"`
+}
+
+// UserFilter describes the search and paging of a list of users
+type UserFilter struct {
+	Query        string
+	Activo       *bool
+	IDInvitacion int
+	Estado       string
+	IDFondo      int
+	Page         int
+	PageSize     int
+}
+
+// UserPage is a page of users
+type UserPage struct {
+	Usuarios Users `json:"usuarios"`
+	Total    int   `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"pageSize"`
 }
 
-// UserSignin defines user when is on Signin phase
+// UserSignin defines user when is on Signin phase, on /signin usuario can be the usuario or the email
 type UserSignin struct {
 	ID         int    `json:"id"`
 	Nombre     string `json:"nombre"`
@@ -24,16 +98,31 @@ type UserSignin struct {
 	Email      string `json:"email"`
 	Contrasena string `json:"contrasena" validate:"required"`
 	IDRol      int    `json:"idRol"`
+	Verificado bool   `json:"-"`
+	// TokenVersion is incremented when tokens issued before a change must stop working
+	TokenVersion int `json:"-"`
+	// IDFondo is the active fondo, IDRol, FondoVersion, Activo and Estado are the rol, the token
+	// version and the estado of the membership of the user to it
+	IDFondo      int    `json:"-"`
+	FondoVersion int    `json:"-"`
+	Activo       bool   `json:"-"`
+	Estado       string `json:"-"`
 }
 
 // UserCreate defines data user structure when realices a signup
 type UserCreate struct {
-	ID         int    `json:"id"`
-	Nombre     string `json:"nombre" validate:"required"`
-	Celular    string `json:"celular"`
-	Contrasena string `json:"contrasena" validate:"required"`
-	Email      string `json:"email"`
-	Usuario    string `json:"usuario" validate:"required"`
+	ID              int    `json:"id"`
+	Nombre          string `json:"nombre" validate:"required"`
+	Celular         string `json:"celular"`
+	Contrasena      string `json:"contrasena" validate:"required,password"`
+	Email           string `json:"email" validate:"required,email"`
+	Usuario         string `json:"usuario" validate:"required,excludes=@"`
+	Cedula          string `json:"cedula" validate:"required,numeric,min=5,max=15"`
+	Direccion       string `json:"direccion" validate:"required,max=255"`
+	FechaNacimiento string `json:"fechaNacimiento" validate:"required,fecha"`
+	// CodigoInvitacion is the invitation code given by an administrator
+	CodigoInvitacion string `json:"codigoInvitacion" validate:"required"`
+	IDRol            int    `json:"idRol"`
 }
 
 //Users is una colección de User
@@ -43,48 +132,90 @@ type Users []*User
 type UserService struct {
 	DB *sql.DB
 	l  hclog.Logger
+	hp HashPolicy
 }
 
-// New creates a new user service
-func New(d *sql.DB, l hclog.Logger) *UserService {
-	return &UserService{d, l}
+// New creates a new user service, new passwords are hashed following hp
+func New(d *sql.DB, l hclog.Logger, hp HashPolicy) *UserService {
+	return &UserService{d, l, hp}
 }
 
-// GetUsers retorna una lista de usuarios
-func (s *UserService) GetUsers() (Users, error) {
-	users := Users{}
-	rows, err := s.DB.Query("SELECT id, nombre FROM usuario")
+// userColumns are the columns scanned by scanUser, idRol is the rol in the fondo joined by userFrom
+const userColumns = "usuario.id, usuario.nombre, COALESCE(usuario.celular, ''), usuario.email, usuario.usuario, fondo_usuario.idRol, usuario.verificado, fondo_usuario.activo, usuario.idInvitacion, fondo_usuario.estado, COALESCE(fondo_usuario.motivoEstado, ''), " +
+	"COALESCE(usuario.cedula, ''), COALESCE(usuario.direccion, ''), COALESCE(DATE_FORMAT(usuario.fechaNacimiento, '%Y-%m-%d'), '')"
+
+// userFrom joins the users with their membership to the fondo given as the first argument
+const userFrom = " FROM usuario JOIN fondo_usuario ON fondo_usuario.idUsuario = usuario.id AND fondo_usuario.idFondo = ? "
+
+// GetUsers retorna una lista de usuarios del fondo
+func (s *UserService) GetUsers(f UserFilter) (UserPage, error) {
+	s.l.Info("[GetUsers] Getting users from database", "fondo", f.IDFondo, "query", f.Query, "page", f.Page)
+
+	page := UserPage{Usuarios: Users{}, Page: f.Page, PageSize: f.PageSize}
+
+	where := "WHERE 1 = 1"
+	args := []interface{}{f.IDFondo}
+	if f.Query != "" {
+		like := "%" + f.Query + "%"
+		where += " AND (usuario.nombre LIKE ? OR usuario.usuario LIKE ? OR usuario.email LIKE ? OR usuario.celular LIKE ?)"
+		args = append(args, like, like, like, like)
+	}
+	if f.Activo != nil {
+		where += " AND fondo_usuario.activo = ?"
+		args = append(args, *f.Activo)
+	}
+	if f.Estado != "" {
+		where += " AND fondo_usuario.estado = ?"
+		args = append(args, f.Estado)
+	}
+	if f.IDInvitacion != 0 {
+		where += " AND usuario.idInvitacion = ?"
+		args = append(args, f.IDInvitacion)
+	}
+
+	rows, err := s.DB.Query("SELECT COUNT(*)"+userFrom+where, args...)
+	if err != nil {
+		return page, err
+	}
+	for rows.Next() {
+		err = rows.Scan(&page.Total)
+	}
+	rows.Close()
+	if err != nil {
+		return page, err
+	}
+
+	args = append(args, f.PageSize, (f.Page-1)*f.PageSize)
+	rows, err = s.DB.Query("SELECT "+userColumns+userFrom+where+" ORDER BY usuario.nombre, usuario.id LIMIT ? OFFSET ?", args...)
 	if err != nil {
-		return users, err
+		return page, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
 		user := &User{}
-		err = rows.Scan(&user.ID, &user.Name)
+		err = scanUser(rows, user)
 		if err != nil {
-			return users, err
+			return page, err
 		}
 
-		users = append(users, user)
+		page.Usuarios = append(page.Usuarios, user)
 	}
 
-	return users, nil
+	return page, rows.Err()
 }
 
-//GetUserByID returns an user given an id
-func (s *UserService) GetUserByID(id int) (User, error) {
+//GetUserByID returns an user of the fondo given an id
+func (s *UserService) GetUserByID(idFondo int, id int) (User, error) {
 	user := User{}
-	rows, err := s.DB.Query("SELECT id, nombre FROM usuario WHERE id = (?)", id)
+	rows, err := s.DB.Query("SELECT "+userColumns+userFrom+"WHERE usuario.id = (?)", idFondo, id)
 	if err != nil {
-		return user, ErrProductNotFound
+		return user, err
 	}
+	defer rows.Close()
 
 	for rows.Next() {
-		user = User{}
-		err = rows.Scan(&user.ID, &user.Name)
-		if err != nil {
-			return user, err
-		}
+		err = scanUser(rows, &user)
 
 		return user, err
 	}
@@ -92,19 +223,118 @@ func (s *UserService) GetUserByID(id int) (User, error) {
 	return user, ErrProductNotFound
 }
 
+//UpdateUser changes the data of an user given an id, each changed field is audited.
+//An empty cedula, direccion or fechaNacimiento keeps the current one, the email, usuario
+//and cedula of an user that belongs to other fondos can't be changed
+func (s *UserService) UpdateUser(id int, pUser *UserUpdate, idActor int) error {
+	s.l.Info("[UpdateUser] Updating", "user", id, "actor", idActor)
+
+	err := s.checkDuplicates(id, pUser.Usuario, pUser.Email)
+	if err == nil {
+		err = s.checkCedula(id, pUser.Cedula)
+	}
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	anterior, err := readPerfil(tx, id)
+	if err != nil {
+		return err
+	}
+
+	if pUser.Email != anterior[AuditEmail] || pUser.Usuario != anterior[AuditUsuario] || (pUser.Cedula != "" && pUser.Cedula != anterior[AuditCedula]) {
+		fondos, err := countFondos(tx, id)
+		if err != nil {
+			return err
+		}
+		if fondos > 1 {
+			return ErrUsuarioCompartido
+		}
+	}
+
+	_, err = tx.Exec("UPDATE usuario SET nombre = ?, celular = ?, email = ?, usuario = ?, "+keepEmpty+" WHERE id = ?",
+		pUser.Nombre,
+		pUser.Celular,
+		pUser.Email,
+		pUser.Usuario,
+		pUser.Cedula,
+		pUser.Direccion,
+		pUser.FechaNacimiento,
+		id)
+	if err != nil {
+		return duplicateError(err)
+	}
+
+	nuevo := perfil{AuditNombre: pUser.Nombre, AuditCelular: pUser.Celular, AuditEmail: pUser.Email, AuditUsuario: pUser.Usuario}
+	nuevo.setFilled(AuditCedula, pUser.Cedula)
+	nuevo.setFilled(AuditDireccion, pUser.Direccion)
+	nuevo.setFilled(AuditFechaNacimiento, pUser.FechaNacimiento)
+	err = auditPerfil(tx, id, idActor, anterior, nuevo)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// IsUsuarioCompartido returns true if the user belongs to more than one fondo, administrators can't
+// change its account then
+func (s *UserService) IsUsuarioCompartido(id int) (bool, error) {
+	fondos, err := countFondos(s.DB, id)
+
+	return fondos > 1, err
+}
+
+// countFondos returns the number of fondos an user belongs to
+func countFondos(q queryer, id int) (int, error) {
+	rows, err := q.Query("SELECT COUNT(*) FROM fondo_usuario WHERE idUsuario = ?", id)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	fondos := 0
+	for rows.Next() {
+		err = rows.Scan(&fondos)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return fondos, rows.Err()
+}
+
+func scanUser(rows *sql.Rows, user *User) error {
+	var idInvitacion sql.NullInt64
+	err := rows.Scan(&user.ID, &user.Nombre, &user.Celular, &user.Email, &user.Usuario, &user.IDRol, &user.Verificado, &user.Activo, &idInvitacion, &user.Estado, &user.MotivoEstado,
+		&user.Cedula, &user.Direccion, &user.FechaNacimiento)
+	if err == nil && idInvitacion.Valid {
+		id := int(idInvitacion.Int64)
+		user.IDInvitacion = &id
+	}
+
+	return err
+}
+
 //GetUserByEmail returns an user given an email
 func (s *UserService) GetUserByEmail(email string) (UserSignin, error) {
 	s.l.Info("[GetUserByEmail] Getting user from database with", "email", email)
 
 	user := UserSignin{}
-	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email FROM usuario WHERE email = (?)", email)
+	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email, verificado, tokenVersion FROM usuario WHERE email = (?)", email)
 	if err != nil {
 		return user, ErrProductNotFound
 	}
 
 	for rows.Next() {
 		user = UserSignin{}
-		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email)
+		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email, &user.Verificado, &user.TokenVersion)
 		if err != nil {
 			return user, err
 		}
@@ -120,14 +350,14 @@ func (s *UserService) GetUserByUser(usuario string) (UserSignin, error) {
 	s.l.Info("[GetUserByEmail] Getting user from database with", "user", usuario)
 
 	user := UserSignin{}
-	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email, usuario FROM usuario WHERE usuario = (?)", usuario)
+	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email, usuario, verificado, tokenVersion FROM usuario WHERE usuario = (?)", usuario)
 	if err != nil {
 		return user, ErrProductNotFound
 	}
 
 	for rows.Next() {
 		user = UserSignin{}
-		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email, &user.Usuario)
+		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email, &user.Usuario, &user.Verificado, &user.TokenVersion)
 		if err != nil {
 			return user, err
 		}
@@ -138,29 +368,295 @@ func (s *UserService) GetUserByUser(usuario string) (UserSignin, error) {
 	return user, ErrProductNotFound
 }
 
-//CreateUser crea un usuario
+//GetUserByLogin returns an user given its usuario or its email
+func (s *UserService) GetUserByLogin(login string) (UserSignin, error) {
+	s.l.Info("[GetUserByLogin] Getting user from database with", "login", login)
+
+	user := UserSignin{}
+	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email, usuario, verificado, tokenVersion FROM usuario WHERE usuario = ? OR email = ?", login, login)
+	if err != nil {
+		return user, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email, &user.Usuario, &user.Verificado, &user.TokenVersion)
+
+		return user, err
+	}
+
+	return user, ErrProductNotFound
+}
+
+//GetSigninUserByID returns the data needed to issue a token given an user id
+func (s *UserService) GetSigninUserByID(id int) (UserSignin, error) {
+	s.l.Info("[GetSigninUserByID] Getting user from database with", "id", id)
+
+	user := UserSignin{}
+	rows, err := s.DB.Query("SELECT id, nombre, contrasena, idRol, email, usuario, verificado, tokenVersion FROM usuario WHERE id = (?)", id)
+	if err != nil {
+		return user, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		err = rows.Scan(&user.ID, &user.Nombre, &user.Contrasena, &user.IDRol, &user.Email, &user.Usuario, &user.Verificado, &user.TokenVersion)
+
+		return user, err
+	}
+
+	return user, ErrProductNotFound
+}
+
+//CreateUser crea un usuario using an invitation code, the user joins the fondo of the invitation with its rol
+//and waits in pendiente until an administrator approves it
 func (s *UserService) CreateUser(pUser *UserCreate) error {
-	s.l.Info("[CreateUser] Creating", "user", pUser)
+	s.l.Info("[CreateUser] Creating", "user", pUser.Usuario)
+
+	err := s.checkDuplicates(0, pUser.Usuario, pUser.Email)
+	if err == nil {
+		err = s.checkCedula(0, pUser.Cedula)
+	}
+	if err != nil {
+		return err
+	}
+
 	saltedPassword, err := s.hashAndSalt([]byte(pUser.Contrasena))
 	if err != nil {
 		return err
 	}
-	_, err = s.DB.Exec("INSERT INTO usuario (nombre, celular, contrasena, email, idrol, usuario) VALUES (?, ?, ?, ?, ?, ?)",
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	inv, err := useInvitation(tx, pUser.CodigoInvitacion, pUser.Email)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.Exec("INSERT INTO usuario (nombre, celular, contrasena, email, idrol, usuario, verificado, idInvitacion, cedula, direccion, fechaNacimiento) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
 		pUser.Nombre,
 		pUser.Celular,
 		saltedPassword,
 		pUser.Email,
-		3,
-		pUser.Usuario)
+		inv.IDRol,
+		pUser.Usuario,
+		false,
+		inv.ID,
+		pUser.Cedula,
+		pUser.Direccion,
+		pUser.FechaNacimiento)
+	if err != nil {
+		return duplicateError(err)
+	}
 
-	return err
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+	pUser.ID = int(id)
+	pUser.IDRol = inv.IDRol
+
+	_, err = tx.Exec("INSERT INTO fondo_usuario (idFondo, idUsuario, idRol, creado, estado) VALUES (?, ?, ?, ?, ?)", inv.IDFondo, pUser.ID, inv.IDRol, time.Now(), EstadoPendiente)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+//SetUserVerified marks the email of an user as verified, it only succeeds if the
+//email is still the one the verification was sent to
+func (s *UserService) SetUserVerified(id int, email string) error {
+	s.l.Info("[SetUserVerified] Verifying email of", "user", id)
+
+	res, err := s.DB.Exec("UPDATE usuario SET verificado = 1 WHERE id = ? AND email = ?", id, email)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(res, ErrProductNotFound)
 }
 
-//DeleteUser elimina un usuario dado un id
-func (s *UserService) DeleteUser(id int) error {
-	_, err := s.DB.Exec("DELETE FROM usuario WHERE id = (?)", id)
+// expectOneRow returns notFound when a statement did not match any row
+func expectOneRow(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
 	if err != nil {
 		return err
 	}
+	if n == 0 {
+		return notFound
+	}
+
 	return nil
 }
+
+//DeactivateUser desactiva un usuario del fondo dado un id, the row is kept so its aportes and
+//creditos history is still readable, and every session of the user on the fondo is revoked.
+//The user keeps its account and its other fondos, the last active administrator can't be deactivated
+func (s *UserService) DeactivateUser(idFondo int, id int, idActor int) error {
+	s.l.Info("[DeactivateUser] Deactivating", "user", id, "fondo", idFondo, "actor", idActor)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var (
+		idRol  int
+		activo bool
+	)
+	err = tx.QueryRow("SELECT idRol, activo FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ? FOR UPDATE", idFondo, id).Scan(&idRol, &activo)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if idRol == 1 && activo {
+		err = checkLastAdmin(tx, idFondo)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.Exec("UPDATE fondo_usuario SET activo = 0, tokenVersion = tokenVersion + 1 WHERE idFondo = ? AND idUsuario = ?", idFondo, id)
+	if err != nil {
+		return err
+	}
+
+	err = expectOneRow(res, ErrProductNotFound)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE refresh_tokens SET revocado = 1 WHERE familia IN (SELECT familia FROM sesiones WHERE idUsuario = ? AND idFondo = ?)", id, idFondo)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec("UPDATE sesiones SET revocada = 1 WHERE idUsuario = ? AND idFondo = ?", id, idFondo)
+	if err != nil {
+		return err
+	}
+
+	err = writeAudit(tx, id, idFondo, idActor, AuditDesactivar, fmt.Sprintf("fondo %d: 1", idFondo), fmt.Sprintf("fondo %d: 0", idFondo))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// checkDuplicates returns ErrUsuarioTaken or ErrEmailTaken if another user, not id, already uses them
+func (s *UserService) checkDuplicates(id int, usuario string, email string) error {
+	rows, err := s.DB.Query("SELECT usuario, email FROM usuario WHERE (usuario = ? OR email = ?) AND id <> ?", usuario, email, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var u, e string
+		err = rows.Scan(&u, &e)
+		if err != nil {
+			return err
+		}
+
+		if strings.EqualFold(u, usuario) {
+			return ErrUsuarioTaken
+		}
+		return ErrEmailTaken
+	}
+
+	return rows.Err()
+}
+
+// checkCedula returns ErrCedulaTaken when another user has the cedula, an empty cedula is not checked
+func (s *UserService) checkCedula(id int, cedula string) error {
+	if cedula == "" {
+		return nil
+	}
+
+	rows, err := s.DB.Query("SELECT id FROM usuario WHERE cedula = ? AND id <> ?", cedula, id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		return ErrCedulaTaken
+	}
+
+	return rows.Err()
+}
+
+// duplicateError maps a duplicate key error of MySQL to ErrUsuarioTaken, ErrEmailTaken or ErrCedulaTaken,
+// it happens when two requests pass checkDuplicates at the same time
+func duplicateError(err error) error {
+	me, ok := err.(*mysql.MySQLError)
+	if !ok || me.Number != mysqlDuplicateEntry {
+		return err
+	}
+
+	if strings.Contains(me.Message, "uq_usuario_email") {
+		return ErrEmailTaken
+	}
+	if strings.Contains(me.Message, "uq_usuario_usuario") {
+		return ErrUsuarioTaken
+	}
+	if strings.Contains(me.Message, "uq_usuario_cedula") {
+		return ErrCedulaTaken
+	}
+
+	return err
+}
+
+//SetUserEstado approves or rejects a pending member of a fondo, motivo is kept to be shown to the user
+func (s *UserService) SetUserEstado(idFondo int, id int, estado string, motivo string, idActor int) error {
+	s.l.Info("[SetUserEstado] Deciding", "user", id, "fondo", idFondo, "estado", estado, "actor", idActor)
+
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	anterior := ""
+	rows, err := tx.Query("SELECT estado FROM fondo_usuario WHERE idFondo = ? AND idUsuario = ? FOR UPDATE", idFondo, id)
+	if err != nil {
+		return err
+	}
+	found := rows.Next()
+	if found {
+		err = rows.Scan(&anterior)
+	}
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrProductNotFound
+	}
+	if anterior != EstadoPendiente {
+		return ErrUserNotPending
+	}
+
+	_, err = tx.Exec("UPDATE fondo_usuario SET estado = ?, motivoEstado = ? WHERE idFondo = ? AND idUsuario = ?",
+		estado, sql.NullString{String: motivo, Valid: motivo != ""}, idFondo, id)
+	if err != nil {
+		return err
+	}
+
+	err = writeAudit(tx, id, idFondo, idActor, AuditEstado, fmt.Sprintf("fondo %d: %s", idFondo, anterior), fmt.Sprintf("fondo %d: %s", idFondo, estado))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
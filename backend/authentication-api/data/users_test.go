@@ -0,0 +1,188 @@
+package data
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-sql-driver/mysql"
+	"github.com/hashicorp/go-hclog"
+)
+
+// newMockService returns an UserService on a mocked database, the expectations are checked when the test ends
+func newMockService(t *testing.T) (*UserService, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	return New(db, hclog.NewNullLogger(), DefaultHashPolicy()), mock
+}
+
+func TestSigninLookups(t *testing.T) {
+	columns := []string{"id", "nombre", "contrasena", "idRol", "email", "usuario", "verificado", "tokenVersion"}
+
+	tests := []struct {
+		name   string
+		query  string
+		lookup func(s *UserService) (UserSignin, error)
+	}{
+		{"by usuario", "FROM usuario WHERE usuario = ", func(s *UserService) (UserSignin, error) { return s.GetUserByUser("ana") }},
+		{"by usuario or email", "FROM usuario WHERE usuario = \\? OR email = \\?", func(s *UserService) (UserSignin, error) { return s.GetUserByLogin("ana") }},
+		{"by id", "FROM usuario WHERE id = ", func(s *UserService) (UserSignin, error) { return s.GetSigninUserByID(7) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT id, nombre, contrasena, idRol, email, usuario, verificado, tokenVersion " + tt.query).
+				WillReturnRows(sqlmock.NewRows(columns).AddRow(7, "Ana", "hash", 3, "ana@x.co", "ana", true, 4))
+
+			user, err := tt.lookup(s)
+			if err != nil {
+				t.Fatalf("lookup error = %v", err)
+			}
+			want := UserSignin{ID: 7, Nombre: "Ana", Contrasena: "hash", IDRol: 3, Email: "ana@x.co", Usuario: "ana", Verificado: true, TokenVersion: 4}
+			if user != want {
+				t.Errorf("lookup = %+v, want %+v", user, want)
+			}
+		})
+	}
+}
+
+func TestSigninLookupNotFound(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectQuery("FROM usuario WHERE usuario = \\? OR email = \\?").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nombre", "contrasena", "idRol", "email", "usuario", "verificado", "tokenVersion"}))
+
+	_, err := s.GetUserByLogin("nadie")
+	if err != ErrProductNotFound {
+		t.Errorf("GetUserByLogin error = %v, want %v", err, ErrProductNotFound)
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	s, mock := newMockService(t)
+	activo := true
+	f := UserFilter{Query: "ana", Activo: &activo, Estado: EstadoAprobado, IDFondo: 2, Page: 3, PageSize: 10}
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM usuario JOIN fondo_usuario .* AND fondo_usuario.idFondo = \\? WHERE 1 = 1 AND \\(usuario.nombre LIKE \\?.*\\) AND fondo_usuario.activo = \\? AND fondo_usuario.estado = \\?").
+		WithArgs(2, "%ana%", "%ana%", "%ana%", "%ana%", true, EstadoAprobado).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(21))
+	mock.ExpectQuery("ORDER BY usuario.nombre, usuario.id LIMIT \\? OFFSET \\?").
+		WithArgs(2, "%ana%", "%ana%", "%ana%", "%ana%", true, EstadoAprobado, 10, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nombre", "celular", "email", "usuario", "idRol", "verificado", "activo", "idInvitacion", "estado", "motivoEstado", "cedula", "direccion", "fechaNacimiento"}).
+			AddRow(7, "Ana", "", "ana@x.co", "ana", 3, true, true, nil, EstadoAprobado, "", "", "", "").
+			AddRow(8, "Mariana", "300", "mariana@x.co", "mariana", 1, true, true, 4, EstadoAprobado, "", "", "", ""))
+
+	page, err := s.GetUsers(f)
+	if err != nil {
+		t.Fatalf("GetUsers error = %v", err)
+	}
+	if page.Total != 21 || page.Page != 3 || page.PageSize != 10 || len(page.Usuarios) != 2 {
+		t.Fatalf("GetUsers = total %d, page %d of %d with %d users, want 21, 3 of 10 with 2", page.Total, page.Page, page.PageSize, len(page.Usuarios))
+	}
+	if page.Usuarios[0].IDInvitacion != nil || page.Usuarios[1].IDInvitacion == nil || *page.Usuarios[1].IDInvitacion != 4 {
+		t.Errorf("GetUsers invitaciones = %v, %v, want none and 4", page.Usuarios[0].IDInvitacion, page.Usuarios[1].IDInvitacion)
+	}
+}
+
+func TestCreateUserDuplicates(t *testing.T) {
+	tests := []struct {
+		name    string
+		usuario string
+		email   string
+		want    error
+	}{
+		{"usuario in other case", "ANA", "otra@x.co", ErrUsuarioTaken},
+		{"email", "otra", "ana@x.co", ErrEmailTaken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectQuery("SELECT usuario, email FROM usuario WHERE \\(usuario = \\? OR email = \\?\\) AND id <> \\?").
+				WithArgs(tt.usuario, tt.email, 0).
+				WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}).AddRow("ana", "ana@x.co"))
+
+			err := s.CreateUser(&UserCreate{Usuario: tt.usuario, Email: tt.email, Contrasena: "Secreta123"})
+			if err != tt.want {
+				t.Errorf("CreateUser error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDuplicateError(t *testing.T) {
+	other := fmt.Errorf("other")
+
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'ana@x.co' for key 'uq_usuario_email'"}, ErrEmailTaken},
+		{&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'ana' for key 'uq_usuario_usuario'"}, ErrUsuarioTaken},
+		{&mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry '123' for key 'uq_usuario_cedula'"}, ErrCedulaTaken},
+		{&mysql.MySQLError{Number: 1452, Message: "uq_usuario_email"}, nil},
+		{other, other},
+	}
+
+	for _, tt := range tests {
+		want := tt.want
+		if want == nil {
+			want = tt.err
+		}
+		if got := duplicateError(tt.err); got != want {
+			t.Errorf("duplicateError(%v) = %v, want %v", tt.err, got, want)
+		}
+	}
+}
+
+func TestSetUserEstado(t *testing.T) {
+	s, mock := newMockService(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT estado FROM fondo_usuario WHERE idFondo = \\? AND idUsuario = \\? FOR UPDATE").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"estado"}).AddRow(EstadoPendiente))
+	mock.ExpectExec("UPDATE fondo_usuario SET estado = \\?, motivoEstado = \\?").
+		WithArgs(EstadoRechazado, "Sin cedula", 2, 7).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO usuario_auditoria").
+		WithArgs(7, 2, 1, AuditEstado, "fondo 2: "+EstadoPendiente, "fondo 2: "+EstadoRechazado, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := s.SetUserEstado(2, 7, EstadoRechazado, "Sin cedula", 1); err != nil {
+		t.Errorf("SetUserEstado error = %v", err)
+	}
+}
+
+func TestSetUserEstadoNotPending(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+		want error
+	}{
+		{"already decided", sqlmock.NewRows([]string{"estado"}).AddRow(EstadoAprobado), ErrUserNotPending},
+		{"not in the fondo", sqlmock.NewRows([]string{"estado"}), ErrProductNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newMockService(t)
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT estado FROM fondo_usuario").WithArgs(2, 7).WillReturnRows(tt.rows)
+			mock.ExpectRollback()
+
+			if err := s.SetUserEstado(2, 7, EstadoAprobado, "", 1); err != tt.want {
+				t.Errorf("SetUserEstado error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -39,10 +39,14 @@ type Validation struct {
 	validate *validator.Validate
 }
 
-// NewValidation creates a new Validation type
-func NewValidation() *Validation {
+// NewValidation creates a new Validation type, the password tag checks the given rules,
+// the fecha tag takes YYYY-MM-DD dates in the past and the scope tag the APIKeyScopes
+func NewValidation(pr PasswordRules) *Validation {
 	validate := validator.New()
-	//validate.RegisterValidation("sku", validateSKU)
+	validate.RegisterValidation("password", pr.validatePassword)
+	validate.RegisterValidation("fecha", validateFecha)
+	validate.RegisterValidation("scope", validateScope)
+	validate.RegisterStructValidation(validateBeneficiarios, BeneficiariosUpdate{})
 
 	return &Validation{validate}
 }
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// apiKeyUsesLimit is how many requests are returned when listing the use of the keys
+const apiKeyUsesLimit = 200
+
+// CreateServiceAccount creates a service account in the active fondo
+func (h *Auth) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.ServiceAccountCreate)
+
+	h.l.Info("[CreateServiceAccount] Handling create service account request", "admin", claims.ID)
+
+	sa, err := h.u.CreateServiceAccount(body, claims.Fondo, claims.ID)
+	if err != nil {
+		h.l.Error("[CreateServiceAccount] Something went wrong creating service account", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong creating service account"}, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&sa, w)
+}
+
+// ListServiceAccounts returns every service account of the active fondo
+func (h *Auth) ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[ListServiceAccounts] Handling list service accounts request", "admin", claims.ID)
+
+	accounts, err := h.u.GetServiceAccounts(claims.Fondo)
+	if err != nil {
+		h.l.Error("[ListServiceAccounts] Something went wrong listing service accounts", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing service accounts"}, w)
+		return
+	}
+
+	data.ToJSON(&accounts, w)
+}
+
+// DeactivateServiceAccount deactivates a service account and revokes its keys
+func (h *Auth) DeactivateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[DeactivateServiceAccount] Handling deactivate service account request", "admin", claims.ID, "account", id)
+
+	err := h.u.DeactivateServiceAccount(id, claims.Fondo)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrServiceAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[DeactivateServiceAccount] Something went wrong deactivating service account", "account", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong deactivating service account"}, w)
+	}
+}
+
+// CreateAPIKey creates a key for a service account, the key is only returned on this response
+func (h *Auth) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.APIKeyCreate)
+	id := getID(r)
+
+	h.l.Info("[CreateAPIKey] Handling create API key request", "admin", claims.ID, "account", id)
+
+	key, err := h.u.CreateAPIKey(body, id, claims.Fondo, claims.ID)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		data.ToJSON(&key, w)
+	case data.ErrServiceAccountNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[CreateAPIKey] Something went wrong creating API key", "account", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong creating API key"}, w)
+	}
+}
+
+// ListAPIKeys returns the keys of a service account with their scopes and last use
+func (h *Auth) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[ListAPIKeys] Handling list API keys request", "admin", claims.ID, "account", id)
+
+	keys, err := h.u.GetAPIKeys(id, claims.Fondo)
+	if err != nil {
+		h.l.Error("[ListAPIKeys] Something went wrong listing API keys", "account", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing API keys"}, w)
+		return
+	}
+
+	data.ToJSON(&keys, w)
+}
+
+// RevokeAPIKey stops a key of a service account from being used
+func (h *Auth) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+	idClave, _ := strconv.Atoi(mux.Vars(r)["idClave"])
+
+	h.l.Info("[RevokeAPIKey] Handling revoke API key request", "admin", claims.ID, "account", id, "key", idClave)
+
+	err := h.u.RevokeAPIKey(idClave, id, claims.Fondo)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrAPIKeyNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[RevokeAPIKey] Something went wrong revoking API key", "key", idClave, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong revoking API key"}, w)
+	}
+}
+
+// ListAPIKeyUses returns the last requests made to the app with the keys of a service account
+func (h *Auth) ListAPIKeyUses(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[ListAPIKeyUses] Handling list API key uses request", "admin", claims.ID, "account", id)
+
+	uses, err := h.u.GetAPIKeyUses(id, claims.Fondo, apiKeyUsesLimit)
+	if err != nil {
+		h.l.Error("[ListAPIKeyUses] Something went wrong listing API key uses", "account", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing API key uses"}, w)
+		return
+	}
+
+	data.ToJSON(&uses, w)
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"net/http"
+)
+
+// ApproveUser approves a pending user, from then on it can use the fondo
+func (h *Auth) ApproveUser(w http.ResponseWriter, r *http.Request) {
+	h.decideUser(w, r, data.EstadoAprobado)
+}
+
+// RejectUser rejects a pending user, a motivo is required so the user knows why
+func (h *Auth) RejectUser(w http.ResponseWriter, r *http.Request) {
+	h.decideUser(w, r, data.EstadoRechazado)
+}
+
+func (h *Auth) decideUser(w http.ResponseWriter, r *http.Request, estado string) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.EstadoDecision)
+	id := getID(r)
+
+	h.l.Info("[decideUser] Handling user decision request", "admin", claims.ID, "user", id, "estado", estado)
+
+	if estado == data.EstadoRechazado && body.Motivo == "" {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		data.ToJSON(&FieldError{Message: "A motivo is required to reject an user", Field: "motivo"}, w)
+		return
+	}
+
+	err := h.u.SetUserEstado(claims.Fondo, id, estado, body.Motivo, claims.ID)
+	switch err {
+	case nil:
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	case data.ErrUserNotPending:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	default:
+		h.l.Error("[decideUser] Something went wrong deciding user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong deciding user"}, w)
+		return
+	}
+
+	user, err := h.u.GetUserByID(claims.Fondo, id)
+	if err != nil {
+		h.l.Error("[decideUser] Fetching user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	subject := "Tu solicitud al fondo fue aprobada"
+	msg := fmt.Sprintf("Hola %s,\n\nTu solicitud para unirte al fondo fue aprobada, ya puedes ingresar.", user.Nombre)
+	if estado == data.EstadoRechazado {
+		subject = "Tu solicitud al fondo fue rechazada"
+		msg = fmt.Sprintf("Hola %s,\n\nTu solicitud para unirte al fondo fue rechazada.\n\nMotivo: %s", user.Nombre, body.Motivo)
+	}
+	err = h.n.Notify(user.Email, subject, msg)
+	if err != nil {
+		h.l.Error("[decideUser] Something went wrong notifying user", "user", id, "error", err)
+	}
+
+	data.ToJSON(&user, w)
+}
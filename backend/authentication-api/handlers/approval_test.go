@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// decisionRequest returns a request of the administrator deciding on the user 7
+func decisionRequest(motivo string) *http.Request {
+	r := adminRequest(http.MethodPost, 7)
+
+	return r.WithContext(context.WithValue(r.Context(), KeyBody{}, &data.EstadoDecision{Motivo: motivo}))
+}
+
+func TestRejectUserWithoutMotivo(t *testing.T) {
+	h, _ := newMockAuth(t)
+
+	w := httptest.NewRecorder()
+	h.RejectUser(w, decisionRequest(""))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("RejectUser status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+}
+
+func TestApproveUserNotPending(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT estado FROM fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"estado"}).AddRow(data.EstadoRechazado))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	h.ApproveUser(w, decisionRequest(""))
+	if w.Code != http.StatusConflict {
+		t.Errorf("ApproveUser status = %d, want %d", w.Code, http.StatusConflict)
+	}
+	if sent := h.n.(*recorder).sent; len(sent) != 0 {
+		t.Errorf("ApproveUser sent %+v, want nothing", sent)
+	}
+}
@@ -2,26 +2,81 @@ package handlers
 
 import (
 	"authentication-api/data"
+	"authentication-api/keys"
+	"authentication-api/notify"
 	"fmt"
-	"os"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/gorilla/mux"
 	"github.com/hashicorp/go-hclog"
 )
 
-var signKey = []byte(os.Getenv("secretAuthKey"))
+// accessTokenTTL is how long an access token can be used
+const accessTokenTTL = 30 * time.Minute
+
+// refreshTokenTTL is how long a refresh token can be used before the user has to sign in again
+const refreshTokenTTL = 30 * 24 * time.Hour
+
+// Authentication methods (amr claim) used to sign in
+const (
+	amrPassword = "pwd"
+	amrOTP      = "otp"
+)
 
 // Auth describes a Auth http handler object
 type Auth struct {
-	l hclog.Logger
-	u *data.UserService
-	v *data.Validation
+	l  hclog.Logger
+	u  *data.UserService
+	v  *data.Validation
+	k  *keys.Store
+	n  notify.Notifier
+	lp data.LockoutPolicy
+	// requireMFA makes two factor authentication mandatory for administrators
+	requireMFA bool
 }
 
 // KeyUser used for the middleware to pass data trought request context
 type KeyUser struct{}
 
+// KeyClaims used for the middleware to pass the validated token claims trought request context
+type KeyClaims struct{}
+
+// KeyBody used for the middleware to pass a validated request body trought request context
+type KeyBody struct{}
+
+// Claims describes the user authenticated by an access token
+type Claims struct {
+	ID     int
+	Rol    int
+	Email  string
+	JTI    string
+	Amr    []string
+	Expira time.Time
+	// Version is the token version of the user when the token was issued
+	Version int
+	// Sid is the familia of the session the token belongs to
+	Sid string
+	// Estado is the current approval estado of the user
+	Estado string
+	// Fondo is the active fondo, Rol is the rol of the user in it
+	Fondo int
+}
+
+// HasMFA returns true if the token was issued after a second factor was verified
+func (c *Claims) HasMFA() bool {
+	for _, m := range c.Amr {
+		if m == amrOTP {
+			return true
+		}
+	}
+
+	return false
+}
+
 // GenericError is a generic error message returned by a server
 type GenericError struct {
 	Message string `json:"message"`
@@ -29,7 +84,21 @@ type GenericError struct {
 
 // Token is the token structure generated by the the server
 type Token struct {
+	Message      string `json:"message"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+	ExpiresIn    int    `json:"expiresIn,omitempty"`
+}
+
+// MFARequired is returned by /signin when the user has to send a second factor
+type MFARequired struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+}
+
+// FieldError is an error caused by the value of a single field of the request
+type FieldError struct {
 	Message string `json:"message"`
+	Field   string `json:"field"`
 }
 
 // ValidationError is a collection of validation error messages
@@ -38,26 +107,35 @@ type ValidationError struct {
 }
 
 // New creates a new instance of an auth handler
-func New(l hclog.Logger, u *data.UserService, v *data.Validation) *Auth {
+func New(l hclog.Logger, u *data.UserService, v *data.Validation, k *keys.Store, n notify.Notifier, lp data.LockoutPolicy, requireMFA bool) *Auth {
 	l.Debug("[New] Creating new instance of an Auth handler")
 
-	return &Auth{l, u, v}
+	return &Auth{l, u, v, k, n, lp, requireMFA}
 }
 
-// GenerateToken a token
-func (h *Auth) GenerateToken(user *data.UserSignin) (string, error) {
+// GenerateToken a token, amr are the authentication methods used to sign in and sid the session familia
+func (h *Auth) GenerateToken(user *data.UserSignin, amr []string, sid string) (string, error) {
 	h.l.Info("[GenerateToken] Generating token for user", "email", user.Email)
 
-	token := jwt.New(jwt.SigningMethodHS256)
-	claims := token.Claims.(jwt.MapClaims)
-	fmt.Println(user.IDRol)
+	jti, err := data.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{}
 	claims["nombre"] = user.Nombre
 	claims["email"] = user.Email
-	claims["exp"] = time.Now().Add(time.Minute * 30).Unix()
+	claims["exp"] = time.Now().Add(accessTokenTTL).Unix()
 	claims["rol"] = user.IDRol
 	claims["id"] = user.ID
+	claims["jti"] = jti
+	claims["amr"] = amr
+	claims["ver"] = user.TokenVersion
+	claims["fver"] = user.FondoVersion
+	claims["sid"] = sid
+	claims["fondo"] = user.IDFondo
 
-	tokenString, err := token.SignedString(signKey)
+	tokenString, err := h.k.Sign(claims)
 
 	if err != nil {
 		return "", err
@@ -65,3 +143,150 @@ func (h *Auth) GenerateToken(user *data.UserSignin) (string, error) {
 
 	return tokenString, nil
 }
+
+// loadFondo sets the active fondo of the user and its rol and estado in it, idFondo 0 loads the first fondo of the user
+func (h *Auth) loadFondo(user *data.UserSignin, idFondo int) error {
+	m, err := h.u.GetMembresia(user.ID, idFondo)
+	if err != nil {
+		return err
+	}
+
+	user.IDFondo, user.IDRol, user.FondoVersion = m.IDFondo, m.IDRol, m.Version
+	user.Activo, user.Estado = m.Activo, m.Estado
+
+	return nil
+}
+
+// GenerateTokenPair generates an access token and a refresh token for the given session familia
+func (h *Auth) GenerateTokenPair(user *data.UserSignin, familia string, amr []string) (Token, error) {
+	accessToken, err := h.GenerateToken(user, amr, familia)
+	if err != nil {
+		return Token{}, err
+	}
+
+	refreshToken, err := h.u.CreateRefreshToken(user.ID, familia, strings.Join(amr, " "), refreshTokenTTL)
+	if err != nil {
+		return Token{}, err
+	}
+
+	return Token{Message: accessToken, RefreshToken: refreshToken, ExpiresIn: int(accessTokenTTL.Seconds())}, nil
+}
+
+// validateToken parses an access token and checks it has not been revoked
+func (h *Auth) validateToken(t string) (*Claims, error) {
+	token, err := jwt.Parse(t, h.k.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, fmt.Errorf("Invalid token")
+	}
+
+	// tokens with a purpose are signed with the same keys but can't be used as access tokens
+	if _, ok := claims["purpose"]; ok {
+		return nil, fmt.Errorf("Invalid token")
+	}
+
+	id, _ := claims["id"].(float64)
+	rol, _ := claims["rol"].(float64)
+	exp, _ := claims["exp"].(float64)
+	email, _ := claims["email"].(string)
+	jti, _ := claims["jti"].(string)
+	sid, _ := claims["sid"].(string)
+	fondo, _ := claims["fondo"].(float64)
+
+	amr := []string{}
+	if list, ok := claims["amr"].([]interface{}); ok {
+		for _, m := range list {
+			if s, ok := m.(string); ok {
+				amr = append(amr, s)
+			}
+		}
+	}
+
+	if jti != "" {
+		revoked, err := h.u.IsAccessTokenRevoked(jti)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, data.ErrTokenRevoked
+		}
+	}
+
+	if sid != "" {
+		revoked, err := h.u.IsSessionRevoked(sid)
+		if err != nil {
+			return nil, err
+		}
+		if revoked {
+			return nil, data.ErrTokenRevoked
+		}
+	}
+
+	// tokens issued before a deactivation or a role change in the fondo of the token are not accepted
+	ver, _ := claims["ver"].(float64)
+	fver, _ := claims["fver"].(float64)
+	state, err := h.u.GetTokenState(int(id), int(fondo))
+	if err != nil {
+		return nil, err
+	}
+	if !state.Activo || state.Version != int(ver) || state.FondoVersion != int(fver) || state.Estado == data.EstadoRechazado {
+		return nil, data.ErrTokenRevoked
+	}
+
+	return &Claims{ID: int(id), Rol: int(rol), Email: email, JTI: jti, Amr: amr, Expira: time.Unix(int64(exp), 0), Version: int(ver), Sid: sid, Estado: state.Estado, Fondo: int(fondo)}, nil
+}
+
+// parsePurposeToken parses a token issued for a single purpose, like verifying an email
+func (h *Auth) parsePurposeToken(t string, purpose string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(t, h.k.Keyfunc)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid || claims["purpose"] != purpose {
+		return nil, fmt.Errorf("Invalid token")
+	}
+
+	return claims, nil
+}
+
+// writeDuplicate writes a 409 with the field that is already taken, it returns false for any other error
+func writeDuplicate(w http.ResponseWriter, err error) bool {
+	field := ""
+	switch err {
+	case data.ErrUsuarioTaken:
+		field = "usuario"
+	case data.ErrEmailTaken:
+		field = "email"
+	case data.ErrCedulaTaken:
+		field = "cedula"
+	default:
+		return false
+	}
+
+	w.WriteHeader(http.StatusConflict)
+	data.ToJSON(&FieldError{Message: err.Error(), Field: field}, w)
+
+	return true
+}
+
+// getID returns the id from the URL
+// Panics if cannot convert the id into an integer
+// this should never happen as the router ensures that
+// this is a valid number
+func getID(r *http.Request) int {
+	vars := mux.Vars(r)
+
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		// should never happen
+		panic(err)
+	}
+
+	return id
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"authentication-api/keys"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/hashicorp/go-hclog"
+)
+
+// notification is a message sent through the recorder notifier
+type notification struct {
+	to, subject, body string
+}
+
+// recorder is a notifier that keeps the messages sent
+type recorder struct {
+	sent []notification
+}
+
+func (n *recorder) Notify(to string, subject string, body string) error {
+	n.sent = append(n.sent, notification{to, subject, body})
+
+	return nil
+}
+
+// newKeyStore returns a key store with a single EdDSA key
+func newKeyStore(t *testing.T) *keys.Store {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	err = ioutil.WriteFile(filepath.Join(dir, "test.pem"), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := keys.Load(dir, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+// newMockAuth returns an Auth handler on a mocked database, the expectations are checked when the test ends
+func newMockAuth(t *testing.T) (*Auth, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	l := hclog.NewNullLogger()
+	u := data.New(db, l, data.DefaultHashPolicy())
+
+	return New(l, u, nil, newKeyStore(t), &recorder{}, data.DefaultLockoutPolicy(), false), mock
+}
+
+func TestWriteDuplicate(t *testing.T) {
+	tests := []struct {
+		err   error
+		field string
+	}{
+		{data.ErrUsuarioTaken, "usuario"},
+		{data.ErrEmailTaken, "email"},
+		{data.ErrCedulaTaken, "cedula"},
+		{data.ErrProductNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if written := writeDuplicate(w, tt.err); written != (tt.field != "") {
+			t.Fatalf("writeDuplicate(%v) = %v, want %v", tt.err, written, tt.field != "")
+		}
+		if tt.field == "" {
+			continue
+		}
+
+		fe := &FieldError{}
+		if err := data.FromJSON(fe, w.Body); err != nil {
+			t.Fatal(err)
+		}
+		if w.Code != http.StatusConflict || fe.Field != tt.field {
+			t.Errorf("writeDuplicate(%v) = %d on %q, want %d on %q", tt.err, w.Code, fe.Field, http.StatusConflict, tt.field)
+		}
+	}
+}
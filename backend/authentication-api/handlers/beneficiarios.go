@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+)
+
+// GetMyBeneficiarios returns the beneficiarios of the authenticated user
+func (h *Auth) GetMyBeneficiarios(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.getBeneficiarios(w, claims.ID, claims.ID)
+}
+
+// SetMyBeneficiarios replaces the beneficiarios of the authenticated user
+func (h *Auth) SetMyBeneficiarios(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.BeneficiariosUpdate)
+
+	h.setBeneficiarios(w, claims.ID, body, claims.ID)
+}
+
+// DeleteMyBeneficiarios removes every beneficiario of the authenticated user
+func (h *Auth) DeleteMyBeneficiarios(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.setBeneficiarios(w, claims.ID, &data.BeneficiariosUpdate{}, claims.ID)
+}
+
+// GetUserBeneficiarios returns the beneficiarios of a member of the fondo
+func (h *Auth) GetUserBeneficiarios(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.getBeneficiarios(w, getID(r), claims.ID)
+}
+
+// SetUserBeneficiarios replaces the beneficiarios of a member of the fondo
+func (h *Auth) SetUserBeneficiarios(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.BeneficiariosUpdate)
+
+	h.setBeneficiarios(w, getID(r), body, claims.ID)
+}
+
+func (h *Auth) getBeneficiarios(w http.ResponseWriter, id int, idActor int) {
+	h.l.Info("[getBeneficiarios] Handling get beneficiarios request", "user", id, "actor", idActor)
+
+	beneficiarios, err := h.u.GetBeneficiarios(id)
+	if err != nil {
+		h.l.Error("[getBeneficiarios] Something went wrong getting beneficiarios", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong getting beneficiarios"}, w)
+		return
+	}
+
+	data.ToJSON(&beneficiarios, w)
+}
+
+func (h *Auth) setBeneficiarios(w http.ResponseWriter, id int, body *data.BeneficiariosUpdate, idActor int) {
+	h.l.Info("[setBeneficiarios] Handling replace beneficiarios request", "user", id, "actor", idActor)
+
+	beneficiarios, err := h.u.SetBeneficiarios(id, body, idActor)
+	switch err {
+	case nil:
+		data.ToJSON(&beneficiarios, w)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[setBeneficiarios] Something went wrong replacing beneficiarios", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong replacing beneficiarios"}, w)
+	}
+}
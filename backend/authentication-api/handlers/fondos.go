@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+)
+
+// ListFondos returns the fondos of the authenticated user with its rol in each one
+func (h *Auth) ListFondos(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[ListFondos] Handling list fondos request", "user", claims.ID)
+
+	fondos, err := h.u.GetFondos(claims.ID)
+	if err != nil {
+		h.l.Error("[ListFondos] Fetching fondos", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing fondos"}, w)
+		return
+	}
+
+	data.ToJSON(&fondos, w)
+}
+
+// SwitchFondo changes the active fondo of the session and returns a new access token for it,
+// the access token used on the request is revoked
+func (h *Auth) SwitchFondo(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.FondoSwitch)
+
+	h.l.Info("[SwitchFondo] Handling switch fondo request", "user", claims.ID, "fondo", body.IDFondo)
+
+	userdb, err := h.u.GetSigninUserByID(claims.ID)
+	if err == nil {
+		err = h.loadFondo(&userdb, body.IDFondo)
+	}
+	// members deactivated or rejected in the fondo can't use it
+	if err == nil && (!userdb.Activo || userdb.Estado == data.EstadoRechazado) {
+		err = data.ErrNotMember
+	}
+	if err == nil {
+		err = h.u.SetSessionFondo(claims.Sid, claims.ID, userdb.IDFondo)
+	}
+
+	switch err {
+	case nil:
+	case data.ErrNotMember:
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	case data.ErrSessionNotFound:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: "Sign in again to switch fondo"}, w)
+		return
+	default:
+		h.l.Error("[SwitchFondo] Something went wrong switching fondo", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong switching fondo"}, w)
+		return
+	}
+
+	accessToken, err := h.GenerateToken(&userdb, claims.Amr, claims.Sid)
+	if err == nil && claims.JTI != "" {
+		err = h.u.RevokeAccessToken(claims.JTI, claims.Expira)
+	}
+	if err != nil {
+		h.l.Error("[SwitchFondo] Something went wrong generating token", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	data.ToJSON(&Token{Message: accessToken, ExpiresIn: int(accessTokenTTL.Seconds())}, w)
+}
+
+// JoinFondo adds the authenticated user to the fondo of an invitation code
+func (h *Auth) JoinFondo(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.FondoJoin)
+
+	h.l.Info("[JoinFondo] Handling join fondo request", "user", claims.ID)
+
+	fondo, err := h.u.JoinFondo(claims.ID, claims.Email, body.CodigoInvitacion)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusCreated)
+		data.ToJSON(&fondo, w)
+	case data.ErrInvitationInvalid:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		data.ToJSON(&FieldError{Message: err.Error(), Field: "codigoInvitacion"}, w)
+	case data.ErrAlreadyMember:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[JoinFondo] Something went wrong joining fondo", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong joining fondo"}, w)
+	}
+}
+
+// CreateFondo creates a new fondo with the authenticated administrator as its administrator, only
+// the users that can create fondos get here
+func (h *Auth) CreateFondo(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.FondoCreate)
+
+	h.l.Info("[CreateFondo] Handling create fondo request", "admin", claims.ID)
+
+	fondo, err := h.u.CreateFondo(body, claims.ID)
+	if err != nil {
+		h.l.Error("[CreateFondo] Something went wrong creating fondo", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong creating fondo"}, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&fondo, w)
+}
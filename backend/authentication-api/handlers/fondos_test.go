@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSwitchFondoNotAllowed(t *testing.T) {
+	tests := []struct {
+		name string
+		rows *sqlmock.Rows
+	}{
+		{"not a member", sqlmock.NewRows([]string{"idFondo", "idRol", "tokenVersion", "activo", "estado"})},
+		{"deactivated", sqlmock.NewRows([]string{"idFondo", "idRol", "tokenVersion", "activo", "estado"}).AddRow(5, 3, 0, false, data.EstadoAprobado)},
+		{"rejected", sqlmock.NewRows([]string{"idFondo", "idRol", "tokenVersion", "activo", "estado"}).AddRow(5, 3, 0, true, data.EstadoRechazado)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newMockAuth(t)
+			mock.ExpectQuery("FROM usuario WHERE id = ").WithArgs(7).
+				WillReturnRows(sqlmock.NewRows([]string{"id", "nombre", "contrasena", "idRol", "email", "usuario", "verificado", "tokenVersion"}).
+					AddRow(7, "Ana", "hash", 3, "ana@x.co", "ana", true, 0))
+			mock.ExpectQuery("FROM fondo_usuario WHERE idUsuario = \\? AND idFondo = \\?").WithArgs(7, 5).WillReturnRows(tt.rows)
+
+			r := httptest.NewRequest(http.MethodPost, "/fondos/switch", nil)
+			ctx := context.WithValue(r.Context(), KeyClaims{}, &Claims{ID: 7, Rol: 3, Fondo: 2, Sid: "fam"})
+			r = r.WithContext(context.WithValue(ctx, KeyBody{}, &data.FondoSwitch{IDFondo: 5}))
+			w := httptest.NewRecorder()
+			h.SwitchFondo(w, r)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("SwitchFondo status = %d, want %d", w.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
+
+func TestUnlockUserCompartido(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM fondo_usuario WHERE idUsuario = \\?").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	w := httptest.NewRecorder()
+	h.UnlockUser(w, adminRequest(http.MethodPost, 7))
+	if w.Code != http.StatusConflict {
+		t.Errorf("UnlockUser status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
@@ -0,0 +1,16 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+)
+
+// JWKS returns the public keys used to verify the tokens signed by this server
+func (h *Auth) JWKS(w http.ResponseWriter, r *http.Request) {
+	h.l.Debug("[JWKS] Handling jwks request")
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Add("Cache-Control", "public, max-age=300")
+
+	data.ToJSON(h.k.JWKS(), w)
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// CreateInvitation creates an invitation code to the active fondo, when it is bound to an email the code is sent to it
+func (h *Auth) CreateInvitation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.InvitationCreate)
+
+	h.l.Info("[CreateInvitation] Handling create invitation request", "admin", claims.ID)
+
+	inv, err := h.u.CreateInvitation(body, claims.Fondo, claims.ID)
+	if err != nil {
+		h.l.Error("[CreateInvitation] Something went wrong creating invitation", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong creating invitation"}, w)
+		return
+	}
+
+	if inv.Email != "" {
+		msg := fmt.Sprintf("Hola,\n\nFuiste invitado a unirte al fondo. Registrate en %s con el codigo:\n\n%s\n\nEl codigo vence el %s.",
+			os.Getenv("signupURL"), inv.Codigo, inv.Expira.Format("2006-01-02"))
+		err = h.n.Notify(inv.Email, "Invitacion al fondo", msg)
+		if err != nil {
+			h.l.Error("[CreateInvitation] Something went wrong sending invitation", "invitation", inv.ID, "error", err)
+		}
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	data.ToJSON(&inv, w)
+}
+
+// ListInvitations returns every invitation of the active fondo with its uses
+func (h *Auth) ListInvitations(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[ListInvitations] Handling list invitations request", "admin", claims.ID)
+
+	invitations, err := h.u.GetInvitations(claims.Fondo)
+	if err != nil {
+		h.l.Error("[ListInvitations] Something went wrong listing invitations", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing invitations"}, w)
+		return
+	}
+
+	data.ToJSON(&invitations, w)
+}
+
+// RevokeInvitation stops an invitation from being used, members that already used it are kept
+func (h *Auth) RevokeInvitation(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[RevokeInvitation] Handling revoke invitation request", "admin", claims.ID, "invitation", id)
+
+	err := h.u.RevokeInvitation(id, claims.Fondo)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrInvitationNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[RevokeInvitation] Something went wrong revoking invitation", "invitation", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong revoking invitation"}, w)
+	}
+}
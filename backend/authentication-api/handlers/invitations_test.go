@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestCreateInvitationSendsCode(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectExec("INSERT INTO invitaciones").WillReturnResult(sqlmock.NewResult(5, 1))
+
+	r := adminRequest(http.MethodPost, 0)
+	r = r.WithContext(context.WithValue(r.Context(), KeyBody{}, &data.InvitationCreate{Email: "ana@x.co"}))
+	w := httptest.NewRecorder()
+	h.CreateInvitation(w, r)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateInvitation status = %d, want %d", w.Code, http.StatusCreated)
+	}
+
+	inv := &data.Invitation{}
+	if err := data.FromJSON(inv, w.Body); err != nil {
+		t.Fatal(err)
+	}
+	sent := h.n.(*recorder).sent
+	if len(sent) != 1 || sent[0].to != "ana@x.co" || !strings.Contains(sent[0].body, inv.Codigo) {
+		t.Errorf("CreateInvitation sent %+v, want the code %s to ana@x.co", sent, inv.Codigo)
+	}
+}
+
+func TestRevokeInvitationOfOtherFondo(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectExec("UPDATE invitaciones SET revocada = 1 WHERE id = \\? AND idFondo = \\?").WithArgs(5, 2).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := httptest.NewRecorder()
+	h.RevokeInvitation(w, adminRequest(http.MethodDelete, 5))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("RevokeInvitation status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
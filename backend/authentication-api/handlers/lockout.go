@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// checkLockout answers with 429 Too Many Requests when the account or the ip have too many
+// recent failed signins, it returns false when the request must not go on
+func (h *Auth) checkLockout(w http.ResponseWriter, usuario string, ip string) bool {
+	now := time.Now()
+
+	account, byIP, err := h.u.GetLoginFailures(usuario, ip, now.Add(-h.lp.Window))
+	if err != nil {
+		h.l.Error("[checkLockout] Fetching failed signins", "user", usuario, "ip", ip, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	retry, locked := h.lp.RetryAfter(account, h.lp.Account, now)
+	retryIP, lockedIP := h.lp.RetryAfter(byIP, h.lp.IP, now)
+	if retryIP > retry {
+		retry, locked = retryIP, lockedIP
+	}
+
+	if retry <= 0 {
+		return true
+	}
+
+	seconds := int(math.Ceil(retry.Seconds()))
+	h.l.Info("[checkLockout] Signin throttled", "user", usuario, "ip", ip, "retryAfter", seconds, "locked", locked)
+
+	msg := fmt.Sprintf("Too many failed attempts, try again in %d seconds", seconds)
+	if locked {
+		msg = fmt.Sprintf("Account temporarily locked, try again in %d seconds", seconds)
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.WriteHeader(http.StatusTooManyRequests)
+	data.ToJSON(&GenericError{Message: msg}, w)
+
+	return false
+}
+
+// recordFailure stores a failed signin, errors are only logged so the client gets the same answer
+func (h *Auth) recordFailure(usuario string, ip string) {
+	err := h.u.RecordLoginFailure(usuario, ip)
+	if err != nil {
+		h.l.Error("[recordFailure] Something went wrong recording failed signin", "user", usuario, "ip", ip, "error", err)
+	}
+}
+
+// UnlockUser removes the failed signins of an user so it can sign in again
+func (h *Auth) UnlockUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[UnlockUser] Handling unlock request", "admin", claims.ID, "user", id)
+
+	if !h.checkAccountAdmin(w, id) {
+		return
+	}
+
+	userdb, err := h.u.GetSigninUserByID(id)
+	if err == nil {
+		err = h.u.ClearLoginFailures(userdb.Usuario)
+	}
+
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[UnlockUser] Something went wrong unlocking user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// clientIP returns the ip address of the client without the port
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
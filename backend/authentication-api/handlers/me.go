@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// purposeChangeEmail is the purpose claim of the tokens sent to confirm a new email
+const purposeChangeEmail = "change-email"
+
+// GetMe returns the profile of the authenticated user
+func (h *Auth) GetMe(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[GetMe] Handling get profile request", "user", claims.ID)
+
+	user, err := h.u.GetUserByID(claims.Fondo, claims.ID)
+	switch err {
+	case nil:
+		data.ToJSON(&user, w)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[GetMe] Fetching user", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateMe changes the nombre and celular of the authenticated user
+func (h *Auth) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.ProfileUpdate)
+
+	h.l.Info("[UpdateMe] Handling update profile request", "user", claims.ID)
+
+	err := h.u.UpdateProfile(claims.ID, body)
+	if err == nil {
+		var user data.User
+		user, err = h.u.GetUserByID(claims.Fondo, claims.ID)
+		if err == nil {
+			data.ToJSON(&user, w)
+			return
+		}
+	}
+
+	if writeDuplicate(w, err) {
+		return
+	}
+
+	switch err {
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[UpdateMe] Something went wrong updating profile", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong updating profile"}, w)
+	}
+}
+
+// ChangeEmail sends a confirmation link to the new email, the email of the user
+// does not change until the link is opened
+func (h *Auth) ChangeEmail(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(KeyBody{}).(*data.EmailChange)
+
+	userdb, ok := h.checkCurrentPassword(w, r, body.Contrasena)
+	if !ok {
+		return
+	}
+
+	h.l.Info("[ChangeEmail] Handling change email request", "user", userdb.ID)
+
+	token, err := h.k.Sign(jwt.MapClaims{
+		"sub":      userdb.ID,
+		"email":    body.Email,
+		"anterior": userdb.Email,
+		"purpose":  purposeChangeEmail,
+		"exp":      time.Now().Add(verificationTTL).Unix(),
+	})
+	if err == nil {
+		link := os.Getenv("changeEmailURL") + "?token=" + url.QueryEscape(token)
+		msg := fmt.Sprintf("Hola %s,\n\nPara confirmar tu nuevo correo ingresa a:\n\n%s\n\nEl enlace vence en %d horas.",
+			userdb.Nombre, link, int(verificationTTL.Hours()))
+		err = h.n.Notify(body.Email, "Confirma tu nuevo correo", msg)
+	}
+	if err != nil {
+		h.l.Error("[ChangeEmail] Something went wrong sending confirmation", "user", userdb.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong sending confirmation"}, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmEmail replaces the email of an user using the token sent by ChangeEmail,
+// the previous email is told about the change
+func (h *Auth) ConfirmEmail(w http.ResponseWriter, r *http.Request) {
+	h.l.Info("[ConfirmEmail] Handling confirm email request")
+	w.Header().Add("Content-Type", "application/json")
+
+	claims, err := h.parsePurposeToken(r.URL.Query().Get("token"), purposeChangeEmail)
+	if err != nil {
+		h.l.Info("[ConfirmEmail] Confirmation token rejected", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	id, _ := claims["sub"].(float64)
+	email, _ := claims["email"].(string)
+	anterior, _ := claims["anterior"].(string)
+
+	err = h.u.ChangeEmail(int(id), anterior, email)
+	if writeDuplicate(w, err) {
+		return
+	}
+
+	switch err {
+	case nil:
+		msg := fmt.Sprintf("Hola,\n\nEl correo de tu cuenta fue cambiado a %s. Si no fuiste tu, contacta a un administrador.", email)
+		err = h.n.Notify(anterior, "Tu correo fue cambiado", msg)
+		if err != nil {
+			h.l.Error("[ConfirmEmail] Something went wrong notifying previous email", "user", id, "error", err)
+		}
+
+		data.ToJSON(&GenericError{Message: "Email changed"}, w)
+	case data.ErrProductNotFound:
+		h.l.Info("[ConfirmEmail] Email changed since the confirmation was sent", "user", id)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: "Invalid token"}, w)
+	default:
+		h.l.Error("[ConfirmEmail] Something went wrong changing email", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong changing email"}, w)
+	}
+}
+
+// ChangePassword sets a new password for the authenticated user, every session is closed
+func (h *Auth) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(KeyBody{}).(*data.PasswordChange)
+
+	userdb, ok := h.checkCurrentPassword(w, r, body.Contrasena)
+	if !ok {
+		return
+	}
+
+	h.l.Info("[ChangePassword] Handling change password request", "user", userdb.ID)
+
+	err := h.u.ChangePassword(userdb.ID, body.Nueva)
+	if err != nil {
+		h.l.Error("[ChangePassword] Something went wrong changing password", "user", userdb.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong changing password"}, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkCurrentPassword checks the password of the authenticated user, wrong passwords
+// count as failed signins so a stolen access token can't be used to guess it
+func (h *Auth) checkCurrentPassword(w http.ResponseWriter, r *http.Request, contrasena string) (data.UserSignin, bool) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	ip := clientIP(r)
+
+	userdb, err := h.u.GetSigninUserByID(claims.ID)
+	if err != nil {
+		h.l.Error("[checkCurrentPassword] Fetching user", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return userdb, false
+	}
+
+	if !h.checkLockout(w, userdb.Usuario, ip) {
+		return userdb, false
+	}
+
+	if !h.u.CheckPassword(&userdb, contrasena) {
+		h.l.Info("[checkCurrentPassword] Wrong current password", "user", userdb.ID, "ip", ip)
+		h.recordFailure(userdb.Usuario, ip)
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: "Wrong password"}, w)
+		return userdb, false
+	}
+
+	return userdb, true
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+)
+
+// meRequest returns a request of the user 7 with the given body
+func meRequest(body interface{}) *http.Request {
+	r := httptest.NewRequest(http.MethodPut, "/me/email", nil)
+	ctx := context.WithValue(r.Context(), KeyClaims{}, &Claims{ID: 7, Rol: 3, Fondo: 2})
+
+	return r.WithContext(context.WithValue(ctx, KeyBody{}, body))
+}
+
+// expectCurrentUser expects the lookups of checkCurrentPassword for the user 7 with the password correcta
+func expectCurrentUser(t *testing.T, mock sqlmock.Sqlmock) {
+	t.Helper()
+
+	hash, err := data.DefaultHashPolicy().Hash([]byte("correcta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("FROM usuario WHERE id = ").WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "nombre", "contrasena", "idRol", "email", "usuario", "verificado", "tokenVersion"}).
+			AddRow(7, "Ana", hash, 3, "ana@x.co", "ana", true, 0))
+	mock.ExpectQuery("FROM login_failures WHERE usuario = \\?").WillReturnRows(sqlmock.NewRows([]string{"count", "fecha"}).AddRow(0, nil))
+	mock.ExpectQuery("FROM login_failures WHERE ip = \\?").WillReturnRows(sqlmock.NewRows([]string{"count", "fecha"}).AddRow(0, nil))
+}
+
+func TestChangeEmail(t *testing.T) {
+	h, mock := newMockAuth(t)
+	expectCurrentUser(t, mock)
+
+	w := httptest.NewRecorder()
+	h.ChangeEmail(w, meRequest(&data.EmailChange{Email: "nueva@x.co", Contrasena: "correcta"}))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("ChangeEmail status = %d, want %d", w.Code, http.StatusAccepted)
+	}
+
+	// the link goes to the new email and keeps the current one to check it did not change
+	sent := h.n.(*recorder).sent
+	if len(sent) != 1 || sent[0].to != "nueva@x.co" {
+		t.Fatalf("ChangeEmail sent %+v, want one message to nueva@x.co", sent)
+	}
+	i := strings.Index(sent[0].body, "?token=")
+	if i < 0 {
+		t.Fatalf("ChangeEmail body = %q, want a link with the token", sent[0].body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(sent[0].body[i+len("?token="):])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := h.parsePurposeToken(token, purposeChangeEmail)
+	if err != nil {
+		t.Fatalf("parsePurposeToken error = %v", err)
+	}
+	if claims["sub"] != float64(7) || claims["email"] != "nueva@x.co" || claims["anterior"] != "ana@x.co" {
+		t.Errorf("change email claims = %v, want sub 7 from ana@x.co to nueva@x.co", claims)
+	}
+}
+
+func TestChangeEmailWrongPassword(t *testing.T) {
+	h, mock := newMockAuth(t)
+	expectCurrentUser(t, mock)
+	// a wrong password counts as a failed signin
+	mock.ExpectExec("INSERT INTO login_failures").WithArgs("ana", "192.0.2.1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	w := httptest.NewRecorder()
+	h.ChangeEmail(w, meRequest(&data.EmailChange{Email: "nueva@x.co", Contrasena: "incorrecta"}))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("ChangeEmail status = %d, want %d", w.Code, http.StatusForbidden)
+	}
+	if sent := h.n.(*recorder).sent; len(sent) != 0 {
+		t.Errorf("ChangeEmail sent %+v, want nothing", sent)
+	}
+}
+
+func TestConfirmEmailChangedMeanwhile(t *testing.T) {
+	h, mock := newMockAuth(t)
+	token, err := h.k.Sign(jwt.MapClaims{"sub": 7, "email": "nueva@x.co", "anterior": "ana@x.co", "purpose": purposeChangeEmail, "exp": time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock.ExpectQuery("SELECT usuario, email FROM usuario").WillReturnRows(sqlmock.NewRows([]string{"usuario", "email"}))
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE usuario SET email = \\?, verificado = 1 WHERE id = \\? AND email = \\?").
+		WithArgs("nueva@x.co", 7, "ana@x.co").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	h.ConfirmEmail(w, httptest.NewRequest(http.MethodGet, "/me/email/confirm?token="+url.QueryEscape(token), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ConfirmEmail status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if sent := h.n.(*recorder).sent; len(sent) != 0 {
+		t.Errorf("ConfirmEmail sent %+v, want nothing", sent)
+	}
+}
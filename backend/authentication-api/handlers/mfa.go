@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mfaTokenTTL is how long the user has to send the second factor after the password
+const mfaTokenTTL = 5 * time.Minute
+
+// purposeMFA is the purpose claim of the tokens returned by the first step of /signin
+const purposeMFA = "mfa"
+
+// TOTPEnrollment is returned when an user starts enrolling two factor authentication
+type TOTPEnrollment struct {
+	Secreto string `json:"secreto"`
+	URI     string `json:"uri"`
+}
+
+// RecoveryCodes is returned once, when two factor authentication is enabled
+type RecoveryCodes struct {
+	CodigosRecuperacion []string `json:"codigosRecuperacion"`
+}
+
+// requireSecondFactor answers the first step of a signin with a token that must be
+// sent back with a TOTP code to /signin/2fa
+func (h *Auth) requireSecondFactor(w http.ResponseWriter, user *data.UserSignin) {
+	token, err := h.k.Sign(jwt.MapClaims{
+		"sub":     user.ID,
+		"purpose": purposeMFA,
+		"exp":     time.Now().Add(mfaTokenTTL).Unix(),
+	})
+	if err != nil {
+		h.l.Error("[requireSecondFactor] Something went wrong generating token", "error", err)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	data.ToJSON(&MFARequired{MFARequired: true, MFAToken: token}, w)
+}
+
+// SigninTOTP handles the second step of a signin for users with two factor authentication
+func (h *Auth) SigninTOTP(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(KeyBody{}).(*data.TOTPSignin)
+	ip := clientIP(r)
+
+	claims, err := h.parsePurposeToken(body.MFAToken, purposeMFA)
+	if err != nil {
+		h.l.Info("[SigninTOTP] MFA token rejected", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	id, _ := claims["sub"].(float64)
+	h.l.Info("[SigninTOTP] Handling second factor for", "user", id, "ip", ip)
+
+	userdb, err := h.u.GetSigninUserByID(int(id))
+	if err != nil {
+		h.l.Error("[SigninTOTP] Fetching user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if !h.checkLockout(w, userdb.Usuario, ip) {
+		h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginThrottled)
+		return
+	}
+
+	err = h.u.VerifyTOTP(userdb.ID, body.Codigo)
+	switch err {
+	case nil:
+	case data.ErrInvalidCode, data.ErrTOTPNotFound:
+		h.l.Info("[SigninTOTP] Failed second factor", "user", userdb.ID, "ip", ip)
+		h.recordFailure(userdb.Usuario, ip)
+		h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginBadCode)
+		writeBadCredentials(w)
+		return
+	default:
+		h.l.Error("[SigninTOTP] Something went wrong verifying code", "user", userdb.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// the user could have been deactivated or rejected since the first step
+	if !h.checkAccount(w, r, &userdb) {
+		return
+	}
+
+	h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginSuccess)
+	h.issueTokens(w, r, &userdb, []string{amrPassword, amrOTP})
+}
+
+// EnrollTOTP creates a new TOTP secret for the caller, it has to be confirmed with ConfirmTOTP
+func (h *Auth) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[EnrollTOTP] Handling two factor enrollment for", "user", claims.ID)
+
+	secret, err := h.u.CreateTOTP(claims.ID)
+	switch err {
+	case nil:
+	case data.ErrTOTPEnabled:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	default:
+		h.l.Error("[EnrollTOTP] Something went wrong enrolling", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	userdb, err := h.u.GetSigninUserByID(claims.ID)
+	if err != nil {
+		h.l.Error("[EnrollTOTP] Fetching user", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data.ToJSON(&TOTPEnrollment{Secreto: secret, URI: otpauthURI(secret, userdb.Usuario)}, w)
+}
+
+// ConfirmTOTP enables two factor authentication once the user sends a valid code for the new secret
+func (h *Auth) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.TOTPCode)
+
+	h.l.Info("[ConfirmTOTP] Handling two factor confirmation for", "user", claims.ID)
+
+	totp, err := h.u.GetTOTP(claims.ID)
+	switch {
+	case err == data.ErrTOTPNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	case err != nil:
+		h.l.Error("[ConfirmTOTP] Fetching two factor authentication", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case totp.Activo:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: data.ErrTOTPEnabled.Error()}, w)
+		return
+	}
+
+	step, ok := data.ValidateTOTP(totp.Secreto, body.Codigo, time.Now(), totp.UltimoPaso)
+	if !ok {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		data.ToJSON(&GenericError{Message: data.ErrInvalidCode.Error()}, w)
+		return
+	}
+
+	codes, err := h.u.EnableTOTP(claims.ID, step)
+	if err != nil {
+		h.l.Error("[ConfirmTOTP] Something went wrong enabling two factor authentication", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	data.ToJSON(&RecoveryCodes{CodigosRecuperacion: codes}, w)
+}
+
+// DisableTOTP turns off two factor authentication, a valid code is required
+func (h *Auth) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.TOTPCode)
+
+	h.l.Info("[DisableTOTP] Handling two factor removal for", "user", claims.ID)
+
+	err := h.u.VerifyTOTP(claims.ID, body.Codigo)
+	if err == nil {
+		err = h.u.DisableTOTP(claims.ID)
+	}
+
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrInvalidCode:
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	case data.ErrTOTPNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[DisableTOTP] Something went wrong disabling two factor authentication", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// otpauthURI returns the uri used by authenticator apps to import the secret, usually as a QR code
+func otpauthURI(secret string, usuario string) string {
+	issuer := os.Getenv("totpIssuer")
+	if issuer == "" {
+		issuer = "FondoFamiliar"
+	}
+
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", "6")
+	q.Set("period", "30")
+
+	u := url.URL{Scheme: "otpauth", Host: "totp", Path: "/" + issuer + ":" + usuario, RawQuery: q.Encode()}
+
+	return u.String()
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// passwordResetTTL is how long a password reset token can be used
+const passwordResetTTL = time.Hour
+
+// ForgotPassword sends a password reset link to the email of the user. It answers the
+// same whether the email exists or not so it can't be used to find out who is in the fondo
+func (h *Auth) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(KeyBody{}).(*data.PasswordForgot)
+
+	h.l.Info("[ForgotPassword] Handling password forgot request for", "email", body.Email)
+
+	userdb, err := h.u.GetUserByEmail(body.Email)
+	switch err {
+	case nil:
+		token, err := h.u.CreatePasswordReset(userdb.ID, passwordResetTTL)
+		if err != nil {
+			h.l.Error("[ForgotPassword] Something went wrong creating password reset", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			data.ToJSON(&GenericError{Message: "Something went wrong creating password reset"}, w)
+			return
+		}
+
+		link := os.Getenv("passwordResetURL") + "?token=" + url.QueryEscape(token)
+		msg := fmt.Sprintf("Hola %s,\n\nPara cambiar tu contraseña ingresa a:\n\n%s\n\nEl enlace vence en %d minutos. Si no lo solicitaste puedes ignorar este mensaje.",
+			userdb.Nombre, link, int(passwordResetTTL.Minutes()))
+
+		err = h.n.Notify(userdb.Email, "Recuperación de contraseña", msg)
+		if err != nil {
+			h.l.Error("[ForgotPassword] Something went wrong sending password reset", "error", err)
+		}
+	case data.ErrProductNotFound:
+		h.l.Info("[ForgotPassword] User with email not found", "email", body.Email)
+	default:
+		h.l.Error("[ForgotPassword] Fetching user with email", "email", body.Email, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	data.ToJSON(&GenericError{Message: "If the email is registered a reset link was sent"}, w)
+}
+
+// ResetPassword sets a new password using a reset token
+func (h *Auth) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	body := r.Context().Value(KeyBody{}).(*data.PasswordReset)
+
+	h.l.Info("[ResetPassword] Handling password reset request")
+
+	id, err := h.u.ResetPassword(body.Token, body.Contrasena)
+	switch err {
+	case nil:
+		h.l.Info("[ResetPassword] Password changed", "user", id)
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrTokenNotFound, data.ErrTokenExpired:
+		h.l.Info("[ResetPassword] Reset token rejected", "user", id, "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[ResetPassword] Something went wrong resetting password", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong resetting password"}, w)
+	}
+}
@@ -3,8 +3,6 @@ package handlers
 import (
 	"authentication-api/data"
 	"net/http"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Signup hanldes user signup requests
@@ -15,9 +13,26 @@ func (h *Auth) Signup(w http.ResponseWriter, r *http.Request) {
 	err := h.u.CreateUser(user)
 
 	if err != nil {
+		if writeDuplicate(w, err) {
+			h.l.Info("[Signup] Duplicate user", "user", user.Usuario, "error", err)
+			return
+		}
+		if err == data.ErrInvitationInvalid {
+			h.l.Info("[Signup] Invitation code rejected", "user", user.Usuario)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&FieldError{Message: err.Error(), Field: "codigoInvitacion"}, w)
+			return
+		}
+
 		h.l.Error("[Signup] Something went wrong creating an user in the database ", "error", err)
 		w.WriteHeader(http.StatusInternalServerError)
-		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		data.ToJSON(&GenericError{Message: "Something went wrong creating user"}, w)
+		return
+	}
+
+	err = h.sendVerification(user.ID, user.Nombre, user.Email)
+	if err != nil {
+		h.l.Error("[Signup] Something went wrong sending email verification", "user", user.ID, "error", err)
 	}
 
 	data.ToJSON(&user, w)
@@ -26,36 +41,134 @@ func (h *Auth) Signup(w http.ResponseWriter, r *http.Request) {
 // Signin hanldes user Signin requests
 func (h *Auth) Signin(w http.ResponseWriter, r *http.Request) {
 	user := r.Context().Value(KeyUser{}).(*data.UserSignin)
+	ip := clientIP(r)
+
+	h.l.Info("[Signin] Handling signin request for", "user", user.Usuario, "ip", ip)
 
-	h.l.Info("[Signin] Handling signin request for", "user", user.Usuario)
+	userdb, err := h.u.GetUserByLogin(user.Usuario)
 
-	userdb, err := h.u.GetUserByUser(user.Usuario)
+	// failures are counted on the usuario so signing in with the email shares the same limit
+	account := user.Usuario
+	if err == nil {
+		account = userdb.Usuario
+	}
+
+	if !h.checkLockout(w, account, ip) {
+		h.logLogin(r, userdb.ID, account, data.LoginThrottled)
+		return
+	}
 
 	switch err {
 	case nil:
-		err = bcrypt.CompareHashAndPassword([]byte(userdb.Contrasena), []byte(user.Contrasena))
-		if err != nil {
-			h.l.Info("[Signin] Failed login attempt at", "email", user.Email)
-			data.ToJSON(&GenericError{Message: "Can't authenticate user"}, w)
+		if !h.u.CheckPassword(&userdb, user.Contrasena) {
+			h.l.Info("[Signin] Failed login attempt at", "user", account, "ip", ip)
+			h.recordFailure(account, ip)
+			h.logLogin(r, userdb.ID, account, data.LoginBadPassword)
+			writeBadCredentials(w)
 			return
 		}
-		tokenString, err := h.GenerateToken(&userdb)
+		err = h.u.ClearLoginFailures(account)
 		if err != nil {
-			h.l.Error("[Signin] Something went wrong generating token", "error", err)
-			data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+			h.l.Error("[Signin] Something went wrong clearing failed signins", "user", account, "error", err)
+		}
+		if !h.checkAccount(w, r, &userdb) {
 			return
 		}
-		data.ToJSON(&Token{Message: tokenString}, w)
+		totp, err := h.u.GetTOTP(userdb.ID)
+		if err != nil && err != data.ErrTOTPNotFound {
+			h.l.Error("[Signin] Fetching two factor authentication", "user", userdb.ID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err == nil && totp.Activo {
+			h.logLogin(r, userdb.ID, account, data.LoginMFARequired)
+			h.requireSecondFactor(w, &userdb)
+			return
+		}
+		h.logLogin(r, userdb.ID, account, data.LoginSuccess)
+		h.issueTokens(w, r, &userdb, []string{amrPassword})
 	case data.ErrProductNotFound:
-		w.WriteHeader(http.StatusNotFound)
-		h.l.Error("[Signin] User with phone not found", "email", user.Email)
-		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		h.recordFailure(user.Usuario, ip)
+		h.logLogin(r, 0, user.Usuario, data.LoginUnknownUser)
+		h.l.Info("[Signin] Failed login attempt with unknown", "user", user.Usuario, "ip", ip)
+		writeBadCredentials(w)
 
 		return
 	default:
-		h.l.Error("[Signin] Fetching user with email", "email", user.Email, "err", err)
+		h.l.Error("[Signin] Fetching user", "user", user.Usuario, "err", err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
 
 }
+
+// writeBadCredentials answers a signin with an unknown user or a wrong password, both get the same
+// 401 Unauthorized so the answer doesn't tell which accounts exist
+func writeBadCredentials(w http.ResponseWriter) {
+	w.WriteHeader(http.StatusUnauthorized)
+	data.ToJSON(&GenericError{Message: "Can't authenticate user"}, w)
+}
+
+// checkAccount loads the first fondo of the user and answers with 403 Forbidden when the user can't get
+// tokens because it was deactivated, its signup was rejected or its email is not verified, it returns
+// false when the signin must not go on
+func (h *Auth) checkAccount(w http.ResponseWriter, r *http.Request, userdb *data.UserSignin) bool {
+	err := h.loadFondo(userdb, 0)
+	if err == data.ErrNotMember {
+		h.l.Info("[checkAccount] User without fondo", "user", userdb.ID)
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: "User is not a member of any fondo"}, w)
+		return false
+	}
+	if err != nil {
+		h.l.Error("[checkAccount] Fetching fondo", "user", userdb.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	if !userdb.Activo {
+		h.l.Info("[checkAccount] Signin attempt with deactivated user", "user", userdb.ID)
+		h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginDeactivated)
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: "User is deactivated"}, w)
+		return false
+	}
+	if userdb.Estado == data.EstadoRechazado {
+		h.l.Info("[checkAccount] Signin attempt with rejected user", "user", userdb.ID)
+		h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginRejected)
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: "Signup was rejected"}, w)
+		return false
+	}
+	if !userdb.Verificado {
+		h.l.Info("[checkAccount] Signin attempt with unverified email", "user", userdb.ID)
+		h.logLogin(r, userdb.ID, userdb.Usuario, data.LoginUnverified)
+		w.WriteHeader(http.StatusForbidden)
+		data.ToJSON(&GenericError{Message: "Email not verified, check your inbox for the verification link"}, w)
+		return false
+	}
+
+	return true
+}
+
+// issueTokens starts a new session on the fondo loaded by checkAccount and writes its token pair
+func (h *Auth) issueTokens(w http.ResponseWriter, r *http.Request, user *data.UserSignin, amr []string) {
+	familia, err := data.NewOpaqueToken()
+	if err == nil {
+		err = h.u.CreateSession(familia, user.ID, user.IDFondo, clientIP(r), r.UserAgent())
+	}
+	if err != nil {
+		h.l.Error("[issueTokens] Something went wrong generating token", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+	token, err := h.GenerateTokenPair(user, familia, amr)
+	if err != nil {
+		h.l.Error("[issueTokens] Something went wrong generating token", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+	data.ToJSON(&token, w)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSigninBadCredentials(t *testing.T) {
+	hash, err := data.DefaultHashPolicy().Hash([]byte("correcta"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	columns := []string{"id", "nombre", "contrasena", "idRol", "email", "usuario", "verificado", "tokenVersion"}
+
+	tests := []struct {
+		name   string
+		user   *sqlmock.Rows
+		result string
+	}{
+		{"unknown user", sqlmock.NewRows(columns), data.LoginUnknownUser},
+		{"wrong password", sqlmock.NewRows(columns).AddRow(7, "Ana", hash, 3, "ana@x.co", "ana", true, 0), data.LoginBadPassword},
+	}
+
+	bodies := map[string]string{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newMockAuth(t)
+			mock.ExpectQuery("FROM usuario WHERE usuario = \\? OR email = \\?").WillReturnRows(tt.user)
+			mock.ExpectQuery("FROM login_failures WHERE usuario = \\?").WillReturnRows(sqlmock.NewRows([]string{"count", "fecha"}).AddRow(0, nil))
+			mock.ExpectQuery("FROM login_failures WHERE ip = \\?").WillReturnRows(sqlmock.NewRows([]string{"count", "fecha"}).AddRow(0, nil))
+			mock.ExpectExec("INSERT INTO login_failures").WithArgs("ana", "192.0.2.1", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+			mock.ExpectExec("INSERT INTO login_events").
+				WithArgs(sqlmock.AnyArg(), "ana", "192.0.2.1", sqlmock.AnyArg(), tt.result, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			r := httptest.NewRequest(http.MethodPost, "/signin", nil)
+			r = r.WithContext(context.WithValue(r.Context(), KeyUser{}, &data.UserSignin{Usuario: "ana", Contrasena: "incorrecta"}))
+			w := httptest.NewRecorder()
+			h.Signin(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Signin status = %d, want %d", w.Code, http.StatusUnauthorized)
+			}
+			bodies[tt.name] = w.Body.String()
+		})
+	}
+
+	// the answer must not tell which accounts exist
+	if bodies["unknown user"] != bodies["wrong password"] {
+		t.Errorf("Signin bodies = %q and %q, want the same", bodies["unknown user"], bodies["wrong password"])
+	}
+}
+
+func TestIssueTokensError(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectExec("INSERT INTO sesiones").WillReturnError(fmt.Errorf("connection lost"))
+
+	w := httptest.NewRecorder()
+	h.issueTokens(w, httptest.NewRequest(http.MethodPost, "/signin", nil), &data.UserSignin{ID: 7, IDFondo: 2}, []string{amrPassword})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("issueTokens status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// logLogin stores a signin attempt, idUsuario is 0 when the usuario does not exist
+func (h *Auth) logLogin(r *http.Request, idUsuario int, usuario string, resultado string) {
+	e := &data.LoginEvent{Usuario: usuario, IP: clientIP(r), UserAgent: r.UserAgent(), Resultado: resultado}
+	if idUsuario != 0 {
+		e.IDUsuario = &idUsuario
+	}
+
+	err := h.u.RecordLoginEvent(e)
+	if err != nil {
+		h.l.Error("[logLogin] Something went wrong recording signin", "user", usuario, "error", err)
+	}
+}
+
+// ListSessions returns the active sessions of the authenticated user
+func (h *Auth) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+	h.l.Info("[ListSessions] Handling list sessions request", "user", claims.ID)
+
+	sessions, err := h.u.GetSessions(claims.ID)
+	if err != nil {
+		h.l.Error("[ListSessions] Fetching sessions", "user", claims.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing sessions"}, w)
+		return
+	}
+
+	for _, s := range sessions {
+		s.Actual = s.Familia == claims.Sid
+	}
+
+	data.ToJSON(&sessions, w)
+}
+
+// RevokeSession revokes a session of the authenticated user, its access tokens stop being accepted
+func (h *Auth) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[RevokeSession] Handling revoke session request", "user", claims.ID, "session", id)
+
+	err := h.u.RevokeSession(id, claims.ID)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrSessionNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[RevokeSession] Something went wrong revoking session", "session", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong revoking session"}, w)
+	}
+}
+
+// ListLogins returns a page of signin attempts of the members of the active fondo, it can be filtered with the
+// idUsuario, usuario, resultado, ip, desde and hasta query params
+func (h *Auth) ListLogins(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	q := r.URL.Query()
+
+	f := data.LoginFilter{IDFondo: claims.Fondo, Usuario: q.Get("usuario"), Resultado: q.Get("resultado"), IP: q.Get("ip")}
+	f.Page, f.PageSize = getPage(r)
+	f.IDUsuario, _ = strconv.Atoi(q.Get("idUsuario"))
+
+	var err error
+	f.Desde, err = parseDate(q.Get("desde"))
+	if err == nil {
+		f.Hasta, err = parseDate(q.Get("hasta"))
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: "Dates must be YYYY-MM-DD or RFC 3339"}, w)
+		return
+	}
+
+	h.l.Info("[ListLogins] Handling list logins request", "admin", claims.ID, "user", f.IDUsuario, "page", f.Page)
+
+	page, err := h.u.GetLoginEvents(f)
+	if err != nil {
+		h.l.Error("[ListLogins] Something went wrong listing logins", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing logins"}, w)
+		return
+	}
+
+	data.ToJSON(&page, w)
+}
+
+// parseDate parses a query param date, empty values return the zero time
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse("2006-01-02", s)
+	if err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, s)
+}
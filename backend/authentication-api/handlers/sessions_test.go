@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestListSessions(t *testing.T) {
+	h, mock := newMockAuth(t)
+	now := time.Now()
+	mock.ExpectQuery("FROM sesiones s").WithArgs(7, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "familia", "ip", "userAgent", "creada", "ultimoUso"}).
+			AddRow(1, "otra", "192.0.2.2", "curl", now, now).
+			AddRow(2, "actual", "192.0.2.1", "curl", now, now))
+
+	r := httptest.NewRequest(http.MethodGet, "/me/sessions", nil)
+	r = r.WithContext(context.WithValue(r.Context(), KeyClaims{}, &Claims{ID: 7, Rol: 3, Fondo: 2, Sid: "actual"}))
+	w := httptest.NewRecorder()
+	h.ListSessions(w, r)
+
+	sessions := data.Sessions{}
+	if err := data.FromJSON(&sessions, w.Body); err != nil {
+		t.Fatal(err)
+	}
+	// the familia is not sent, only which session made the request
+	if len(sessions) != 2 || sessions[0].Actual || !sessions[1].Actual || sessions[1].Familia != "" {
+		t.Errorf("ListSessions = %+v, %+v, want only the second one actual", sessions[0], sessions[1])
+	}
+}
+
+func TestRevokeSessionNotFound(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT familia FROM sesiones").WithArgs(3, 1).WillReturnRows(sqlmock.NewRows([]string{"familia"}))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	h.RevokeSession(w, adminRequest(http.MethodDelete, 3))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("RevokeSession status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
+
+func TestListLoginsBadDate(t *testing.T) {
+	h, _ := newMockAuth(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/logins?desde=01/03/2021", nil)
+	r = r.WithContext(context.WithValue(r.Context(), KeyClaims{}, &Claims{ID: 1, Rol: 1, Fondo: 2}))
+	w := httptest.NewRecorder()
+	h.ListLogins(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("ListLogins status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want time.Time
+		err  bool
+	}{
+		{"", time.Time{}, false},
+		{"2021-03-01", time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), false},
+		{"2021-03-01T10:30:00Z", time.Date(2021, 3, 1, 10, 30, 0, 0, time.UTC), false},
+		{"01/03/2021", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseDate(tt.s)
+		if (err != nil) != tt.err || !got.Equal(tt.want) {
+			t.Errorf("parseDate(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+	"strings"
+)
+
+// RefreshToken handles requests to exchange a refresh token for a new token pair
+func (h *Auth) RefreshToken(w http.ResponseWriter, r *http.Request) {
+	h.l.Info("[RefreshToken] Handling refresh token request")
+
+	body := r.Context().Value(KeyBody{}).(*data.RefreshRequest)
+
+	rt, refreshToken, err := h.u.RotateRefreshToken(body.RefreshToken, refreshTokenTTL)
+	switch err {
+	case nil:
+	case data.ErrTokenNotFound, data.ErrTokenRevoked, data.ErrTokenExpired:
+		h.l.Info("[RefreshToken] Refresh token rejected", "user", rt.IDUsuario, "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	default:
+		h.l.Error("[RefreshToken] Something went wrong rotating refresh token", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	userdb, err := h.u.GetSigninUserByID(rt.IDUsuario)
+	if err != nil {
+		h.l.Error("[RefreshToken] Fetching user", "id", rt.IDUsuario, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	// sessions started before fondos existed use the first fondo of the user
+	idFondo, err := h.u.GetSessionFondo(rt.Familia)
+	if err == data.ErrSessionNotFound {
+		idFondo, err = 0, nil
+	}
+	if err == nil {
+		err = h.loadFondo(&userdb, idFondo)
+	}
+	if err == data.ErrNotMember {
+		h.l.Info("[RefreshToken] User is no longer a member of the fondo", "user", userdb.ID, "fondo", idFondo)
+		w.WriteHeader(http.StatusUnauthorized)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+	if err != nil {
+		h.l.Error("[RefreshToken] Fetching fondo", "user", userdb.ID, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	if !userdb.Activo {
+		h.l.Info("[RefreshToken] Refresh attempt with deactivated user", "user", userdb.ID, "fondo", userdb.IDFondo)
+		w.WriteHeader(http.StatusUnauthorized)
+		data.ToJSON(&GenericError{Message: "User is deactivated"}, w)
+		return
+	}
+
+	if userdb.Estado == data.EstadoRechazado {
+		h.l.Info("[RefreshToken] Refresh attempt with rejected user", "user", userdb.ID, "fondo", userdb.IDFondo)
+		w.WriteHeader(http.StatusUnauthorized)
+		data.ToJSON(&GenericError{Message: "Signup was rejected"}, w)
+		return
+	}
+
+	accessToken, err := h.GenerateToken(&userdb, strings.Fields(rt.Amr), rt.Familia)
+	if err != nil {
+		h.l.Error("[RefreshToken] Something went wrong generating token", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong generating token"}, w)
+		return
+	}
+
+	data.ToJSON(&Token{Message: accessToken, RefreshToken: refreshToken, ExpiresIn: int(accessTokenTTL.Seconds())}, w)
+}
+
+// Signout revokes the access token used on the request and the session of the given refresh token
+func (h *Auth) Signout(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.RefreshRequest)
+
+	h.l.Info("[Signout] Handling signout request for", "user", claims.ID)
+
+	err := h.u.RevokeRefreshToken(body.RefreshToken, claims.ID)
+	switch err {
+	case nil:
+	case data.ErrTokenNotFound:
+		h.l.Info("[Signout] Refresh token not found", "user", claims.ID)
+	default:
+		h.l.Error("[Signout] Something went wrong revoking refresh token", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong signing out"}, w)
+		return
+	}
+
+	if claims.JTI != "" {
+		err = h.u.RevokeAccessToken(claims.JTI, claims.Expira)
+		if err != nil {
+			h.l.Error("[Signout] Something went wrong revoking access token", "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			data.ToJSON(&GenericError{Message: "Something went wrong signing out"}, w)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+//MiddlewareTokenValidation verifies the access token sent on the Authorization header
+func (h *Auth) MiddlewareTokenValidation(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareTokenValidation] Handling token validation middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		if r.Header["Authorization"] == nil {
+			h.l.Info("[MiddlewareTokenValidation] User request not autorized")
+			w.WriteHeader(http.StatusUnauthorized)
+			data.ToJSON(&GenericError{Message: "User request not autorized"}, w)
+			return
+		}
+
+		claims, err := h.validateToken(r.Header["Authorization"][0])
+		if err != nil {
+			h.l.Info("[MiddlewareTokenValidation] Error parsing or validating request token", "error", err, "endpoint", r.URL)
+			w.WriteHeader(http.StatusUnauthorized)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+
+		// add the claims to the context
+		ctx := context.WithValue(r.Context(), KeyClaims{}, claims)
+		r = r.WithContext(ctx)
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareRequireAdmin only lets administrators (rol 1) through, it must run after MiddlewareTokenValidation
+func (h *Auth) MiddlewareRequireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+		if claims.Rol != 1 {
+			h.l.Info("[MiddlewareRequireAdmin] User request not autorized", "user", claims.ID, "endpoint", r.URL)
+			w.WriteHeader(http.StatusForbidden)
+			data.ToJSON(&GenericError{Message: "User request not autorized"}, w)
+			return
+		}
+
+		if claims.Estado != data.EstadoAprobado {
+			h.l.Info("[MiddlewareRequireAdmin] Administrator pending approval", "user", claims.ID, "endpoint", r.URL)
+			w.WriteHeader(http.StatusForbidden)
+			data.ToJSON(&GenericError{Message: "User pending approval"}, w)
+			return
+		}
+
+		if h.requireMFA && !claims.HasMFA() {
+			h.l.Info("[MiddlewareRequireAdmin] Administrator token without two factor authentication", "user", claims.ID, "endpoint", r.URL)
+			w.WriteHeader(http.StatusForbidden)
+			data.ToJSON(&GenericError{Message: "Two factor authentication required"}, w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareRequireFondoMember only lets through requests on /usuarios/{id} and /cuentas-servicio/{id} when the
+//user or the service account belongs to the active fondo of the administrator, it must run after MiddlewareTokenValidation
+func (h *Auth) MiddlewareRequireFondoMember(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+		if _, ok := mux.Vars(r)["id"]; ok {
+			id := getID(r)
+
+			var (
+				member   = true
+				notFound = data.ErrProductNotFound
+				err      error
+			)
+			switch {
+			case strings.HasPrefix(r.URL.Path, "/usuarios/"):
+				member, err = h.u.IsFondoMember(claims.Fondo, id)
+			case strings.HasPrefix(r.URL.Path, "/cuentas-servicio/"):
+				member, err = h.u.IsFondoServiceAccount(claims.Fondo, id)
+				notFound = data.ErrServiceAccountNotFound
+			}
+			if err != nil {
+				h.l.Error("[MiddlewareRequireFondoMember] Checking membership", "id", id, "fondo", claims.Fondo, "endpoint", r.URL, "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			if !member {
+				h.l.Info("[MiddlewareRequireFondoMember] Not a member of the fondo", "admin", claims.ID, "id", id, "fondo", claims.Fondo, "endpoint", r.URL)
+				w.WriteHeader(http.StatusNotFound)
+				data.ToJSON(&GenericError{Message: notFound.Error()}, w)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareRequireFondoCreator only lets through the users with the capability of creating fondos,
+//it must run after MiddlewareTokenValidation
+func (h *Auth) MiddlewareRequireFondoCreator(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims := r.Context().Value(KeyClaims{}).(*Claims)
+
+		crea, err := h.u.CanCreateFondos(claims.ID)
+		if err != nil {
+			h.l.Error("[MiddlewareRequireFondoCreator] Checking capability", "user", claims.ID, "error", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if !crea {
+			h.l.Info("[MiddlewareRequireFondoCreator] User can't create fondos", "user", claims.ID, "endpoint", r.URL)
+			w.WriteHeader(http.StatusForbidden)
+			data.ToJSON(&GenericError{Message: "User request not autorized"}, w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"net/http"
+	"strconv"
+)
+
+// defaultPageSize is the page size used when the request does not specify one
+const defaultPageSize = 20
+
+// maxPageSize is the biggest page size a request can ask for
+const maxPageSize = 100
+
+// ListUsers returns a page of the users of the active fondo, it can be filtered with the q, activo, estado and invitacion query params
+func (h *Auth) ListUsers(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	q := r.URL.Query()
+
+	f := data.UserFilter{Query: q.Get("q"), IDFondo: claims.Fondo}
+	f.Page, f.PageSize = getPage(r)
+	if activo, err := strconv.ParseBool(q.Get("activo")); err == nil {
+		f.Activo = &activo
+	}
+	f.IDInvitacion, _ = strconv.Atoi(q.Get("invitacion"))
+	f.Estado = q.Get("estado")
+
+	h.l.Info("[ListUsers] Handling list users request", "admin", claims.ID, "query", f.Query, "page", f.Page)
+
+	page, err := h.u.GetUsers(f)
+	if err != nil {
+		h.l.Error("[ListUsers] Something went wrong listing users", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong listing users"}, w)
+		return
+	}
+
+	data.ToJSON(&page, w)
+}
+
+// GetUser returns an user given an id
+func (h *Auth) GetUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[GetUser] Handling get user request", "admin", claims.ID, "user", id)
+
+	user, err := h.u.GetUserByID(claims.Fondo, id)
+	switch err {
+	case nil:
+		data.ToJSON(&user, w)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[GetUser] Fetching user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// UpdateUser changes the nombre, celular, email and usuario of an user, the account of an user
+// that belongs to other fondos can only be changed by the user
+func (h *Auth) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.UserUpdate)
+	id := getID(r)
+
+	h.l.Info("[UpdateUser] Handling update user request", "admin", claims.ID, "user", id)
+
+	err := h.u.UpdateUser(id, body, claims.ID)
+	if err == nil {
+		var user data.User
+		user, err = h.u.GetUserByID(claims.Fondo, id)
+		if err == nil {
+			data.ToJSON(&user, w)
+			return
+		}
+	}
+
+	if writeDuplicate(w, err) {
+		return
+	}
+
+	switch err {
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	case data.ErrUsuarioCompartido:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[UpdateUser] Something went wrong updating user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong updating user"}, w)
+	}
+}
+
+// DeactivateUser deactivates an user in the active fondo, its aportes and creditos are kept
+func (h *Auth) DeactivateUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[DeactivateUser] Handling deactivate user request", "admin", claims.ID, "user", id)
+
+	if id == claims.ID {
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: "An administrator can't deactivate itself"}, w)
+		return
+	}
+
+	err := h.u.DeactivateUser(claims.Fondo, id, claims.ID)
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	case data.ErrLastAdmin:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[DeactivateUser] Something went wrong deactivating user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong deactivating user"}, w)
+	}
+}
+
+// ChangeRole sets the role of an user in the active fondo, tokens issued with the previous role stop being accepted
+func (h *Auth) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	body := r.Context().Value(KeyBody{}).(*data.RoleChange)
+	id := getID(r)
+
+	h.l.Info("[ChangeRole] Handling change role request", "admin", claims.ID, "user", id, "rol", body.IDRol)
+
+	err := h.u.ChangeRole(claims.Fondo, id, body.IDRol, claims.ID)
+	if err == nil {
+		var user data.User
+		user, err = h.u.GetUserByID(claims.Fondo, id)
+		if err == nil {
+			data.ToJSON(&user, w)
+			return
+		}
+	}
+
+	switch err {
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	case data.ErrLastAdmin:
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[ChangeRole] Something went wrong changing role", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong changing role"}, w)
+	}
+}
+
+// GetUserAudit returns the changes made by administrators to an user, on the fondo of the administrator
+func (h *Auth) GetUserAudit(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[GetUserAudit] Handling get user audit request", "admin", claims.ID, "user", id)
+
+	entries, err := h.u.GetAudit(claims.Fondo, id)
+	if err != nil {
+		h.l.Error("[GetUserAudit] Fetching audit", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong fetching audit"}, w)
+		return
+	}
+
+	data.ToJSON(&entries, w)
+}
+
+// checkAccountAdmin answers with 409 Conflict when the user belongs to other fondos, its account is shared
+// by them and an administrator of one fondo can't change it. It returns false when the request must not go on
+func (h *Auth) checkAccountAdmin(w http.ResponseWriter, id int) bool {
+	compartido, err := h.u.IsUsuarioCompartido(id)
+	if err != nil {
+		h.l.Error("[checkAccountAdmin] Counting fondos", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return false
+	}
+
+	if compartido {
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: data.ErrUsuarioCompartido.Error()}, w)
+		return false
+	}
+
+	return true
+}
+
+// getPage returns the page and pageSize query params, using defaults when they are missing or invalid
+func getPage(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err := strconv.Atoi(r.URL.Query().Get("pageSize"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
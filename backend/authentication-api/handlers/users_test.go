@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gorilla/mux"
+)
+
+// adminRequest returns a request made by the administrator 1 of the fondo 2 on the user id
+func adminRequest(method string, id int) *http.Request {
+	r := httptest.NewRequest(method, "/users/"+strconv.Itoa(id), nil)
+	r = mux.SetURLVars(r, map[string]string{"id": strconv.Itoa(id)})
+
+	return r.WithContext(context.WithValue(r.Context(), KeyClaims{}, &Claims{ID: 1, Rol: 1, Fondo: 2}))
+}
+
+func TestGetPage(t *testing.T) {
+	tests := []struct {
+		query    string
+		page     int
+		pageSize int
+	}{
+		{"", 1, defaultPageSize},
+		{"?page=3&pageSize=5", 3, 5},
+		{"?page=0&pageSize=-1", 1, defaultPageSize},
+		{"?page=x&pageSize=1000", 1, maxPageSize},
+	}
+
+	for _, tt := range tests {
+		page, pageSize := getPage(httptest.NewRequest(http.MethodGet, "/users"+tt.query, nil))
+		if page != tt.page || pageSize != tt.pageSize {
+			t.Errorf("getPage(%q) = %d, %d, want %d, %d", tt.query, page, pageSize, tt.page, tt.pageSize)
+		}
+	}
+}
+
+func TestDeactivateUserItself(t *testing.T) {
+	h, _ := newMockAuth(t)
+
+	w := httptest.NewRecorder()
+	h.DeactivateUser(w, adminRequest(http.MethodDelete, 1))
+	if w.Code != http.StatusConflict {
+		t.Errorf("DeactivateUser status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestDeactivateLastAdmin(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol, activo FROM fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idRol", "activo"}).AddRow(1, true))
+	mock.ExpectQuery("SELECT idUsuario FROM fondo_usuario WHERE idFondo = \\? AND idRol = 1").WithArgs(2).
+		WillReturnRows(sqlmock.NewRows([]string{"idUsuario"}).AddRow(7))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	h.DeactivateUser(w, adminRequest(http.MethodDelete, 7))
+	if w.Code != http.StatusConflict {
+		t.Errorf("DeactivateUser status = %d, want %d", w.Code, http.StatusConflict)
+	}
+}
+
+func TestDeactivateUserNotInFondo(t *testing.T) {
+	h, mock := newMockAuth(t)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT idRol, activo FROM fondo_usuario").WithArgs(2, 7).
+		WillReturnRows(sqlmock.NewRows([]string{"idRol", "activo"}))
+	mock.ExpectRollback()
+
+	w := httptest.NewRecorder()
+	h.DeactivateUser(w, adminRequest(http.MethodDelete, 7))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("DeactivateUser status = %d, want %d", w.Code, http.StatusNotFound)
+	}
+}
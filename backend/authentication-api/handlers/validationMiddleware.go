@@ -58,7 +58,7 @@ func (h *Auth) MiddlewareValidateUserSignin(next http.Handler) http.Handler {
 			data.ToJSON(&GenericError{Message: err.Error()}, w)
 			return
 		}
-		h.l.Debug("[MiddlewareValidateUserSignin] Serialized user", "email", user.Email)
+		h.l.Debug("[MiddlewareValidateUserSignin] Serialized user", "user", user.Usuario)
 		errs := h.v.Validate(user)
 		if len(errs) != 0 {
 			h.l.Error("[MiddlewareValidateUserSignin] Validating user", "errors:", errs)
@@ -75,3 +75,615 @@ func (h *Auth) MiddlewareValidateUserSignin(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+//MiddlewareValidateRefreshToken verificacion para los request que envian un refresh token
+func (h *Auth) MiddlewareValidateRefreshToken(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateRefreshToken] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.RefreshRequest{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateRefreshToken] Deserializing refresh token", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateRefreshToken] Validating refresh token", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidatePasswordForgot verificacion para los request de recuperacion de contrasena
+func (h *Auth) MiddlewareValidatePasswordForgot(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidatePasswordForgot] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.PasswordForgot{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidatePasswordForgot] Deserializing password forgot", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidatePasswordForgot] Validating password forgot", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidatePasswordReset verificacion para los request de cambio de contrasena con token
+func (h *Auth) MiddlewareValidatePasswordReset(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidatePasswordReset] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.PasswordReset{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidatePasswordReset] Deserializing password reset", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidatePasswordReset] Validating password reset", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateTOTPCode verificacion para los request con un codigo de autenticacion de dos factores
+func (h *Auth) MiddlewareValidateTOTPCode(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateTOTPCode] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.TOTPCode{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateTOTPCode] Deserializing totp code", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateTOTPCode] Validating totp code", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateTOTPSignin verificacion para el segundo paso de /signin
+func (h *Auth) MiddlewareValidateTOTPSignin(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateTOTPSignin] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.TOTPSignin{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateTOTPSignin] Deserializing totp signin", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateTOTPSignin] Validating totp signin", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateUserUpdate verificacion para los request de actualizacion de usuario
+func (h *Auth) MiddlewareValidateUserUpdate(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateUserUpdate] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.UserUpdate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateUserUpdate] Deserializing user update", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateUserUpdate] Validating user update", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateRoleChange verificacion para los request de cambio de rol
+func (h *Auth) MiddlewareValidateRoleChange(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateRoleChange] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.RoleChange{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateRoleChange] Deserializing role change", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateRoleChange] Validating role change", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateProfileUpdate verificacion para los request de actualizacion de perfil
+func (h *Auth) MiddlewareValidateProfileUpdate(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateProfileUpdate] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.ProfileUpdate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateProfileUpdate] Deserializing profile update", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateProfileUpdate] Validating profile update", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateEmailChange verificacion para los request de cambio de correo
+func (h *Auth) MiddlewareValidateEmailChange(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateEmailChange] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.EmailChange{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateEmailChange] Deserializing email change", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateEmailChange] Validating email change", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidatePasswordChange verificacion para los request de cambio de contrasena
+func (h *Auth) MiddlewareValidatePasswordChange(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidatePasswordChange] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.PasswordChange{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidatePasswordChange] Deserializing password change", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidatePasswordChange] Validating password change", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateInvitation verificacion para los request de creacion de invitaciones
+func (h *Auth) MiddlewareValidateInvitation(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateInvitation] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.InvitationCreate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateInvitation] Deserializing invitation", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateInvitation] Validating invitation", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateEstadoDecision verificacion para los request de aprobacion o rechazo de usuarios
+func (h *Auth) MiddlewareValidateEstadoDecision(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateEstadoDecision] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.EstadoDecision{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateEstadoDecision] Deserializing decision", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateEstadoDecision] Validating decision", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateFondoSwitch verificacion para los request de cambio de fondo
+func (h *Auth) MiddlewareValidateFondoSwitch(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateFondoSwitch] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.FondoSwitch{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateFondoSwitch] Deserializing fondo switch", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateFondoSwitch] Validating fondo switch", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateFondoJoin verificacion para los request de union a un fondo
+func (h *Auth) MiddlewareValidateFondoJoin(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateFondoJoin] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.FondoJoin{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateFondoJoin] Deserializing fondo join", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateFondoJoin] Validating fondo join", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateFondo verificacion para los request de creacion de fondos
+func (h *Auth) MiddlewareValidateFondo(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateFondo] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.FondoCreate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateFondo] Deserializing fondo", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateFondo] Validating fondo", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateServiceAccount verificacion para los request de creacion de cuentas de servicio
+func (h *Auth) MiddlewareValidateServiceAccount(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateServiceAccount] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.ServiceAccountCreate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateServiceAccount] Deserializing service account", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateServiceAccount] Validating service account", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateAPIKey verificacion para los request de creacion de API keys
+func (h *Auth) MiddlewareValidateAPIKey(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateAPIKey] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.APIKeyCreate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateAPIKey] Deserializing API key", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateAPIKey] Validating API key", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
+
+//MiddlewareValidateBeneficiarios verificacion para los request de cambio de beneficiarios
+func (h *Auth) MiddlewareValidateBeneficiarios(next http.Handler) http.Handler {
+	h.l.Info("[MiddlewareValidateBeneficiarios] Handling validator middleware request")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Content-Type", "application/json")
+
+		body := &data.BeneficiariosUpdate{}
+
+		err := data.FromJSON(body, r.Body)
+		if err != nil {
+			h.l.Error("[MiddlewareValidateBeneficiarios] Deserializing beneficiarios", "error", err)
+
+			w.WriteHeader(http.StatusBadRequest)
+			data.ToJSON(&GenericError{Message: err.Error()}, w)
+			return
+		}
+		errs := h.v.Validate(body)
+		if len(errs) != 0 {
+			h.l.Error("[MiddlewareValidateBeneficiarios] Validating beneficiarios", "errors:", errs)
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			data.ToJSON(&ValidationError{Messages: errs.Errors()}, w)
+			return
+		}
+
+		// add the body to the context
+		ctx := context.WithValue(r.Context(), KeyBody{}, body)
+		r = r.WithContext(ctx)
+
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
+	})
+}
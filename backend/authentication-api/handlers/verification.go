@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"authentication-api/data"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// verificationTTL is how long an email verification link can be used
+const verificationTTL = 48 * time.Hour
+
+// purposeVerifyEmail is the purpose claim of the email verification tokens
+const purposeVerifyEmail = "verify-email"
+
+// sendVerification sends a signed verification link to the given email
+func (h *Auth) sendVerification(id int, nombre string, email string) error {
+	h.l.Info("[sendVerification] Sending email verification to", "user", id)
+
+	token, err := h.k.Sign(jwt.MapClaims{
+		"sub":     id,
+		"email":   email,
+		"purpose": purposeVerifyEmail,
+		"exp":     time.Now().Add(verificationTTL).Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := os.Getenv("verifyEmailURL") + "?token=" + url.QueryEscape(token)
+	msg := fmt.Sprintf("Hola %s,\n\nPara verificar tu correo ingresa a:\n\n%s\n\nEl enlace vence en %d horas.",
+		nombre, link, int(verificationTTL.Hours()))
+
+	return h.n.Notify(email, "Verifica tu correo", msg)
+}
+
+// VerifyEmail marks an email as verified using the token sent by sendVerification
+func (h *Auth) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	h.l.Info("[VerifyEmail] Handling email verification request")
+	w.Header().Add("Content-Type", "application/json")
+
+	claims, err := h.parsePurposeToken(r.URL.Query().Get("token"), purposeVerifyEmail)
+	if err != nil {
+		h.l.Info("[VerifyEmail] Verification token rejected", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	}
+
+	id, _ := claims["sub"].(float64)
+	email, _ := claims["email"].(string)
+
+	err = h.u.SetUserVerified(int(id), email)
+	switch err {
+	case nil:
+		data.ToJSON(&GenericError{Message: "Email verified"}, w)
+	case data.ErrProductNotFound:
+		h.l.Info("[VerifyEmail] Email changed since the verification was sent", "user", id)
+		w.WriteHeader(http.StatusBadRequest)
+		data.ToJSON(&GenericError{Message: "Invalid token"}, w)
+	default:
+		h.l.Error("[VerifyEmail] Something went wrong verifying email", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong verifying email"}, w)
+	}
+}
+
+// ResendVerification sends the verification link of an user again
+func (h *Auth) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[ResendVerification] Handling resend verification request", "admin", claims.ID, "user", id)
+
+	if !h.checkAccountAdmin(w, id) {
+		return
+	}
+
+	userdb, err := h.u.GetSigninUserByID(id)
+	switch err {
+	case nil:
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+		return
+	default:
+		h.l.Error("[ResendVerification] Fetching user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if userdb.Verificado {
+		w.WriteHeader(http.StatusConflict)
+		data.ToJSON(&GenericError{Message: "Email already verified"}, w)
+		return
+	}
+
+	err = h.sendVerification(userdb.ID, userdb.Nombre, userdb.Email)
+	if err != nil {
+		h.l.Error("[ResendVerification] Something went wrong sending verification", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		data.ToJSON(&GenericError{Message: "Something went wrong sending verification"}, w)
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// VerifyUser lets an administrator verify the email of an user manually
+func (h *Auth) VerifyUser(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(KeyClaims{}).(*Claims)
+	id := getID(r)
+
+	h.l.Info("[VerifyUser] Handling manual verification request", "admin", claims.ID, "user", id)
+
+	if !h.checkAccountAdmin(w, id) {
+		return
+	}
+
+	userdb, err := h.u.GetSigninUserByID(id)
+	if err == nil {
+		err = h.u.SetUserVerified(userdb.ID, userdb.Email)
+	}
+
+	switch err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case data.ErrProductNotFound:
+		w.WriteHeader(http.StatusNotFound)
+		data.ToJSON(&GenericError{Message: err.Error()}, w)
+	default:
+		h.l.Error("[VerifyUser] Something went wrong verifying user", "user", id, "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/dgrijalva/jwt-go"
+)
+
+func TestSendVerification(t *testing.T) {
+	h, _ := newMockAuth(t)
+
+	if err := h.sendVerification(7, "Ana", "ana@x.co"); err != nil {
+		t.Fatalf("sendVerification error = %v", err)
+	}
+
+	sent := h.n.(*recorder).sent
+	if len(sent) != 1 || sent[0].to != "ana@x.co" {
+		t.Fatalf("sendVerification sent %+v, want one message to ana@x.co", sent)
+	}
+
+	i := strings.Index(sent[0].body, "?token=")
+	if i < 0 {
+		t.Fatalf("sendVerification body = %q, want a link with the token", sent[0].body)
+	}
+	token, err := url.QueryUnescape(strings.Fields(sent[0].body[i+len("?token="):])[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := h.parsePurposeToken(token, purposeVerifyEmail)
+	if err != nil {
+		t.Fatalf("parsePurposeToken error = %v", err)
+	}
+	if claims["sub"] != float64(7) || claims["email"] != "ana@x.co" {
+		t.Errorf("verification claims = %v, want sub 7 and email ana@x.co", claims)
+	}
+}
+
+func TestVerifyEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		purpose string
+		rows    int64
+		status  int
+	}{
+		{"verified", purposeVerifyEmail, 1, http.StatusOK},
+		{"email changed since it was sent", purposeVerifyEmail, 0, http.StatusBadRequest},
+		{"token of another purpose", "reset-password", -1, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, mock := newMockAuth(t)
+			if tt.rows >= 0 {
+				mock.ExpectExec("UPDATE usuario SET verificado = 1 WHERE id = \\? AND email = \\?").WithArgs(7, "ana@x.co").
+					WillReturnResult(sqlmock.NewResult(0, tt.rows))
+			}
+
+			token, err := h.k.Sign(jwt.MapClaims{"sub": 7, "email": "ana@x.co", "purpose": tt.purpose, "exp": time.Now().Add(time.Hour).Unix()})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := httptest.NewRecorder()
+			h.VerifyEmail(w, httptest.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(token), nil))
+			if w.Code != tt.status {
+				t.Errorf("VerifyEmail status = %d, want %d", w.Code, tt.status)
+			}
+		})
+	}
+}
+
+func TestVerifyEmailExpired(t *testing.T) {
+	h, _ := newMockAuth(t)
+	token, err := h.k.Sign(jwt.MapClaims{"sub": 7, "email": "ana@x.co", "purpose": purposeVerifyEmail, "exp": time.Now().Add(-time.Minute).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	h.VerifyEmail(w, httptest.NewRequest(http.MethodGet, "/verify-email?token="+url.QueryEscape(token), nil))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("VerifyEmail status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+}
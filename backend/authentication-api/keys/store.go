@@ -0,0 +1,180 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// ErrKeyNotFound is raised when a token references a kid that is not loaded
+var ErrKeyNotFound = fmt.Errorf("Signing key not found")
+
+// Key describes a signing key, Private is nil for keys that are only kept to verify
+// tokens signed before a rotation
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// JWK is the public part of a key as published on the JWKS endpoint
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKS is a set of public keys
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Store holds every key that can be used to verify tokens and the one used to sign new ones
+type Store struct {
+	keys   map[string]*Key
+	active *Key
+}
+
+// Load reads every .pem file in dir, the file name without extension is used as kid.
+// Files can hold RSA or Ed25519 private keys (PKCS#1 or PKCS#8), or a public key for
+// retired keys that should still be published. active is the kid used to sign new tokens.
+//
+// A new key can be generated with:
+//
+//	openssl genpkey -algorithm ed25519 -out cert/keys/2021-07.pem
+//	openssl genpkey -algorithm rsa -pkeyopt rsa_keygen_bits:2048 -out cert/keys/2021-07.pem
+func Load(dir string, active string) (*Store, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Store{keys: map[string]*Key{}}
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+
+		kid := strings.TrimSuffix(filepath.Base(f), ".pem")
+		k, err := parseKey(kid, b)
+		if err != nil {
+			return nil, fmt.Errorf("Parsing key %s: %s", f, err)
+		}
+
+		s.keys[kid] = k
+	}
+
+	k, ok := s.keys[active]
+	if !ok || k.Private == nil {
+		return nil, fmt.Errorf("Active signing key %q not found on %s", active, dir)
+	}
+	s.active = k
+
+	return s, nil
+}
+
+// Sign signs the claims with the active key and sets its kid on the token header
+func (s *Store) Sign(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(s.active.Method, claims)
+	token.Header["kid"] = s.active.ID
+
+	return token.SignedString(s.active.Private)
+}
+
+// Keyfunc returns the public key used to verify a token based on its kid header
+func (s *Store) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+
+	k, ok := s.keys[kid]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	if token.Method.Alg() != k.Method.Alg() {
+		return nil, fmt.Errorf("Unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return k.Public, nil
+}
+
+// JWKS returns the public part of every loaded key
+func (s *Store) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, k := range s.keys {
+		jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+
+		switch p := k.Public.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(p.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(p.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(p)
+		}
+
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
+
+func parseKey(kid string, b []byte) (*Key, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM data found")
+	}
+
+	var (
+		key interface{}
+		err error
+	)
+
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block %s", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Private: k, Public: &k.PublicKey}, nil
+	case ed25519.PrivateKey:
+		return &Key{ID: kid, Method: SigningMethodEd25519, Private: k, Public: k.Public()}, nil
+	case *rsa.PublicKey:
+		return &Key{ID: kid, Method: jwt.SigningMethodRS256, Public: k}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: kid, Method: SigningMethodEd25519, Public: k}, nil
+	}
+
+	return nil, fmt.Errorf("Unsupported key type %T", key)
+}
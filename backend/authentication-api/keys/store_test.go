@@ -0,0 +1,152 @@
+package keys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// writeKey stores a key as kid.pem on dir, public keys are stored without their private part
+func writeKey(t *testing.T, dir string, kid string, key interface{}) {
+	t.Helper()
+
+	var (
+		b   []byte
+		typ string
+		err error
+	)
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		b, typ = x509.MarshalPKCS1PrivateKey(k), "RSA PRIVATE KEY"
+	case ed25519.PrivateKey:
+		b, err = x509.MarshalPKCS8PrivateKey(k)
+		typ = "PRIVATE KEY"
+	default:
+		b, err = x509.MarshalPKIXPublicKey(k)
+		typ = "PUBLIC KEY"
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, kid+".pem"), pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "2021-01", rsaKey)
+	writeKey(t, dir, "2021-07", edKey)
+
+	old, err := Load(dir, "2021-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldToken, err := old.Sign(jwt.MapClaims{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the old key is retired, only its public part is kept to verify the tokens it signed
+	writeKey(t, dir, "2021-01", &rsaKey.PublicKey)
+	s, err := Load(dir, "2021-07")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newToken, err := s.Sign(jwt.MapClaims{"id": 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"id": 1})
+	forged.Header["kid"] = "2021-01"
+	forgedToken, err := forged.SignedString(x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unknown := jwt.NewWithClaims(SigningMethodEd25519, jwt.MapClaims{"id": 1})
+	unknown.Header["kid"] = "2020-01"
+	unknownToken, err := unknown.SignedString(edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"signed with the active key", newToken, true},
+		{"signed with the retired key", oldToken, true},
+		{"signed with another method than its key", forgedToken, false},
+		{"signed with a key that is not loaded", unknownToken, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := jwt.Parse(tt.token, s.Keyfunc)
+			valid := err == nil && token.Valid
+			if valid != tt.valid {
+				t.Errorf("Parse valid = %v, want %v (error %v)", valid, tt.valid, err)
+			}
+		})
+	}
+
+	if parsed, _ := jwt.Parse(newToken, s.Keyfunc); parsed.Header["kid"] != "2021-07" {
+		t.Errorf("Sign kid = %v, want 2021-07", parsed.Header["kid"])
+	}
+
+	jwks := s.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[1].Kty != "OKP" {
+		t.Errorf("JWKS = %+v, want the RSA and the OKP keys", jwks.Keys)
+	}
+}
+
+func TestLoadActiveKey(t *testing.T) {
+	dir := t.TempDir()
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeKey(t, dir, "privada", edKey)
+	writeKey(t, dir, "publica", edKey.Public())
+
+	tests := []struct {
+		name   string
+		active string
+		ok     bool
+	}{
+		{"private key", "privada", true},
+		{"public key can't sign", "publica", false},
+		{"missing key", "otra", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(dir, tt.active)
+			if (err == nil) != tt.ok {
+				t.Errorf("Load(%q) error = %v, want ok %v", tt.active, err, tt.ok)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"authentication-api/data"
 	"authentication-api/handlers"
+	"authentication-api/keys"
+	"authentication-api/notify"
 	"context"
 	"database/sql"
 	"fmt"
@@ -10,6 +12,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -24,7 +27,7 @@ import (
 var _ = godotenv.Load(".env")
 var (
 	//ConnectionString cadena de conexión a la base de datos
-	ConnectionString = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s",
+	ConnectionString = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&clientFoundRows=true",
 		os.Getenv("user"),
 		os.Getenv("pass"),
 		os.Getenv("host"),
@@ -48,16 +51,45 @@ func main() {
 	al := l.Named("Auth")
 
 	// Validator object
-	v := data.NewValidation()
+	pr := data.DefaultPasswordRules()
+	if min, err := strconv.Atoi(os.Getenv("passwordMinLength")); err == nil && min > pr.MinLength {
+		pr.MinLength = min
+	}
+	v := data.NewValidation(pr)
+
+	// Password hashing policy, bcrypt by default or argon2id
+	hp, err := data.NewHashPolicy(os.Getenv("passwordHash"), os.Getenv("bcryptCost"))
+	if err != nil {
+		log.Fatal(err)
+	}
 
 	// Se crea servicio de usuario
-	us := data.New(db, dl)
+	us := data.New(db, dl, hp)
+
+	// Signing keys, every key on the folder is published and the active one signs new tokens
+	ks, err := keys.Load(os.Getenv("signingKeysPath"), os.Getenv("activeSigningKey"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Notifier used to send emails to the users, logs them when there is no SMTP server
+	var n notify.Notifier
+	if os.Getenv("notifier") == "smtp" {
+		n = notify.NewSMTP(os.Getenv("smtpHost"), os.Getenv("smtpPort"), os.Getenv("smtpUser"), os.Getenv("smtpPass"), os.Getenv("smtpFrom"))
+	} else {
+		n = notify.NewLog(l.Named("Notify"), os.Getenv("notifierFile"))
+	}
 
 	// Creating mux server to save handlers
 	sm := mux.NewRouter()
 
 	// Creating auth handler
-	ah := handlers.New(al, us, v)
+	ah := handlers.New(al, us, v, ks, n, data.DefaultLockoutPolicy(), os.Getenv("requireAdminMFA") == "true")
+
+	getR := sm.Methods(http.MethodGet).Subrouter()
+	getR.HandleFunc("/.well-known/jwks.json", ah.JWKS)
+	getR.HandleFunc("/verify", ah.VerifyEmail)
+	getR.HandleFunc("/me/email/confirmar", ah.ConfirmEmail)
 
 	// Subrouter to hanlde post requests
 	postR := sm.Methods(http.MethodPost).Subrouter()
@@ -68,6 +100,170 @@ func main() {
 	postSignR.HandleFunc("/signin", ah.Signin)
 	postSignR.Use(ah.MiddlewareValidateUserSignin)
 
+	postSignTOTPR := sm.Methods(http.MethodPost).Subrouter()
+	postSignTOTPR.HandleFunc("/signin/2fa", ah.SigninTOTP)
+	postSignTOTPR.Use(ah.MiddlewareValidateTOTPSignin)
+
+	postRefreshR := sm.Methods(http.MethodPost).Subrouter()
+	postRefreshR.HandleFunc("/token/refresh", ah.RefreshToken)
+	postRefreshR.Use(ah.MiddlewareValidateRefreshToken)
+
+	postSignoutR := sm.Methods(http.MethodPost).Subrouter()
+	postSignoutR.HandleFunc("/signout", ah.Signout)
+	postSignoutR.Use(ah.MiddlewareTokenValidation)
+	postSignoutR.Use(ah.MiddlewareValidateRefreshToken)
+
+	postForgotR := sm.Methods(http.MethodPost).Subrouter()
+	postForgotR.HandleFunc("/password/forgot", ah.ForgotPassword)
+	postForgotR.Use(ah.MiddlewareValidatePasswordForgot)
+
+	postResetR := sm.Methods(http.MethodPost).Subrouter()
+	postResetR.HandleFunc("/password/reset", ah.ResetPassword)
+	postResetR.Use(ah.MiddlewareValidatePasswordReset)
+
+	postEnrollR := sm.Methods(http.MethodPost).Subrouter()
+	postEnrollR.HandleFunc("/2fa/enroll", ah.EnrollTOTP)
+	postEnrollR.Use(ah.MiddlewareTokenValidation)
+
+	postTOTPR := sm.Methods(http.MethodPost).Subrouter()
+	postTOTPR.HandleFunc("/2fa/confirm", ah.ConfirmTOTP)
+	postTOTPR.HandleFunc("/2fa/disable", ah.DisableTOTP)
+	postTOTPR.Use(ah.MiddlewareTokenValidation)
+	postTOTPR.Use(ah.MiddlewareValidateTOTPCode)
+
+	// Subrouters for the authenticated user
+	getMeR := sm.Methods(http.MethodGet).Subrouter()
+	getMeR.HandleFunc("/me", ah.GetMe)
+	getMeR.HandleFunc("/me/sesiones", ah.ListSessions)
+	getMeR.HandleFunc("/me/fondos", ah.ListFondos)
+	getMeR.HandleFunc("/me/beneficiarios", ah.GetMyBeneficiarios)
+	getMeR.Use(ah.MiddlewareTokenValidation)
+
+	putMeR := sm.Methods(http.MethodPut).Subrouter()
+	putMeR.HandleFunc("/me", ah.UpdateMe)
+	putMeR.Use(ah.MiddlewareTokenValidation)
+	putMeR.Use(ah.MiddlewareValidateProfileUpdate)
+
+	putPasswordR := sm.Methods(http.MethodPut).Subrouter()
+	putPasswordR.HandleFunc("/me/contrasena", ah.ChangePassword)
+	putPasswordR.Use(ah.MiddlewareTokenValidation)
+	putPasswordR.Use(ah.MiddlewareValidatePasswordChange)
+
+	postEmailR := sm.Methods(http.MethodPost).Subrouter()
+	postEmailR.HandleFunc("/me/email", ah.ChangeEmail)
+	postEmailR.Use(ah.MiddlewareTokenValidation)
+	postEmailR.Use(ah.MiddlewareValidateEmailChange)
+
+	putBeneficiariosR := sm.Methods(http.MethodPut).Subrouter()
+	putBeneficiariosR.HandleFunc("/me/beneficiarios", ah.SetMyBeneficiarios)
+	putBeneficiariosR.Use(ah.MiddlewareTokenValidation)
+	putBeneficiariosR.Use(ah.MiddlewareValidateBeneficiarios)
+
+	putFondoR := sm.Methods(http.MethodPut).Subrouter()
+	putFondoR.HandleFunc("/me/fondo", ah.SwitchFondo)
+	putFondoR.Use(ah.MiddlewareTokenValidation)
+	putFondoR.Use(ah.MiddlewareValidateFondoSwitch)
+
+	postJoinR := sm.Methods(http.MethodPost).Subrouter()
+	postJoinR.HandleFunc("/me/fondos", ah.JoinFondo)
+	postJoinR.Use(ah.MiddlewareTokenValidation)
+	postJoinR.Use(ah.MiddlewareValidateFondoJoin)
+
+	deleteMeR := sm.Methods(http.MethodDelete).Subrouter()
+	deleteMeR.HandleFunc("/me/sesiones/{id:[0-9]+}", ah.RevokeSession)
+	deleteMeR.HandleFunc("/me/beneficiarios", ah.DeleteMyBeneficiarios)
+	deleteMeR.Use(ah.MiddlewareTokenValidation)
+
+	// Subrouters for administrators
+	getAdminR := sm.Methods(http.MethodGet).Subrouter()
+	getAdminR.HandleFunc("/usuarios", ah.ListUsers)
+	getAdminR.HandleFunc("/usuarios/{id:[0-9]+}", ah.GetUser)
+	getAdminR.HandleFunc("/usuarios/{id:[0-9]+}/auditoria", ah.GetUserAudit)
+	getAdminR.HandleFunc("/usuarios/{id:[0-9]+}/beneficiarios", ah.GetUserBeneficiarios)
+	getAdminR.HandleFunc("/logins", ah.ListLogins)
+	getAdminR.HandleFunc("/invitaciones", ah.ListInvitations)
+	getAdminR.HandleFunc("/cuentas-servicio", ah.ListServiceAccounts)
+	getAdminR.HandleFunc("/cuentas-servicio/{id:[0-9]+}/claves", ah.ListAPIKeys)
+	getAdminR.HandleFunc("/cuentas-servicio/{id:[0-9]+}/uso", ah.ListAPIKeyUses)
+	getAdminR.Use(ah.MiddlewareTokenValidation)
+	getAdminR.Use(ah.MiddlewareRequireAdmin)
+	getAdminR.Use(ah.MiddlewareRequireFondoMember)
+
+	putAdminR := sm.Methods(http.MethodPut).Subrouter()
+	putAdminR.HandleFunc("/usuarios/{id:[0-9]+}", ah.UpdateUser)
+	putAdminR.Use(ah.MiddlewareTokenValidation)
+	putAdminR.Use(ah.MiddlewareRequireAdmin)
+	putAdminR.Use(ah.MiddlewareRequireFondoMember)
+	putAdminR.Use(ah.MiddlewareValidateUserUpdate)
+
+	putRolR := sm.Methods(http.MethodPut).Subrouter()
+	putRolR.HandleFunc("/usuarios/{id:[0-9]+}/rol", ah.ChangeRole)
+	putRolR.Use(ah.MiddlewareTokenValidation)
+	putRolR.Use(ah.MiddlewareRequireAdmin)
+	putRolR.Use(ah.MiddlewareRequireFondoMember)
+	putRolR.Use(ah.MiddlewareValidateRoleChange)
+
+	putAdminBeneficiariosR := sm.Methods(http.MethodPut).Subrouter()
+	putAdminBeneficiariosR.HandleFunc("/usuarios/{id:[0-9]+}/beneficiarios", ah.SetUserBeneficiarios)
+	putAdminBeneficiariosR.Use(ah.MiddlewareTokenValidation)
+	putAdminBeneficiariosR.Use(ah.MiddlewareRequireAdmin)
+	putAdminBeneficiariosR.Use(ah.MiddlewareRequireFondoMember)
+	putAdminBeneficiariosR.Use(ah.MiddlewareValidateBeneficiarios)
+
+	deleteAdminR := sm.Methods(http.MethodDelete).Subrouter()
+	deleteAdminR.HandleFunc("/usuarios/{id:[0-9]+}", ah.DeactivateUser)
+	deleteAdminR.HandleFunc("/invitaciones/{id:[0-9]+}", ah.RevokeInvitation)
+	deleteAdminR.HandleFunc("/cuentas-servicio/{id:[0-9]+}", ah.DeactivateServiceAccount)
+	deleteAdminR.HandleFunc("/cuentas-servicio/{id:[0-9]+}/claves/{idClave:[0-9]+}", ah.RevokeAPIKey)
+	deleteAdminR.Use(ah.MiddlewareTokenValidation)
+	deleteAdminR.Use(ah.MiddlewareRequireAdmin)
+	deleteAdminR.Use(ah.MiddlewareRequireFondoMember)
+
+	postAdminR := sm.Methods(http.MethodPost).Subrouter()
+	postAdminR.HandleFunc("/usuarios/{id:[0-9]+}/verificacion", ah.ResendVerification)
+	postAdminR.HandleFunc("/usuarios/{id:[0-9]+}/verificar", ah.VerifyUser)
+	postAdminR.HandleFunc("/usuarios/{id:[0-9]+}/desbloquear", ah.UnlockUser)
+	postAdminR.Use(ah.MiddlewareTokenValidation)
+	postAdminR.Use(ah.MiddlewareRequireAdmin)
+	postAdminR.Use(ah.MiddlewareRequireFondoMember)
+
+	postDecisionR := sm.Methods(http.MethodPost).Subrouter()
+	postDecisionR.HandleFunc("/usuarios/{id:[0-9]+}/aprobar", ah.ApproveUser)
+	postDecisionR.HandleFunc("/usuarios/{id:[0-9]+}/rechazar", ah.RejectUser)
+	postDecisionR.Use(ah.MiddlewareTokenValidation)
+	postDecisionR.Use(ah.MiddlewareRequireAdmin)
+	postDecisionR.Use(ah.MiddlewareRequireFondoMember)
+	postDecisionR.Use(ah.MiddlewareValidateEstadoDecision)
+
+	postInvitationR := sm.Methods(http.MethodPost).Subrouter()
+	postInvitationR.HandleFunc("/invitaciones", ah.CreateInvitation)
+	postInvitationR.Use(ah.MiddlewareTokenValidation)
+	postInvitationR.Use(ah.MiddlewareRequireAdmin)
+	postInvitationR.Use(ah.MiddlewareRequireFondoMember)
+	postInvitationR.Use(ah.MiddlewareValidateInvitation)
+
+	postServiceAccountR := sm.Methods(http.MethodPost).Subrouter()
+	postServiceAccountR.HandleFunc("/cuentas-servicio", ah.CreateServiceAccount)
+	postServiceAccountR.Use(ah.MiddlewareTokenValidation)
+	postServiceAccountR.Use(ah.MiddlewareRequireAdmin)
+	postServiceAccountR.Use(ah.MiddlewareRequireFondoMember)
+	postServiceAccountR.Use(ah.MiddlewareValidateServiceAccount)
+
+	postAPIKeyR := sm.Methods(http.MethodPost).Subrouter()
+	postAPIKeyR.HandleFunc("/cuentas-servicio/{id:[0-9]+}/claves", ah.CreateAPIKey)
+	postAPIKeyR.Use(ah.MiddlewareTokenValidation)
+	postAPIKeyR.Use(ah.MiddlewareRequireAdmin)
+	postAPIKeyR.Use(ah.MiddlewareRequireFondoMember)
+	postAPIKeyR.Use(ah.MiddlewareValidateAPIKey)
+
+	postFondoR := sm.Methods(http.MethodPost).Subrouter()
+	postFondoR.HandleFunc("/fondos", ah.CreateFondo)
+	postFondoR.Use(ah.MiddlewareTokenValidation)
+	postFondoR.Use(ah.MiddlewareRequireAdmin)
+	postFondoR.Use(ah.MiddlewareRequireFondoMember)
+	postFondoR.Use(ah.MiddlewareRequireFondoCreator)
+	postFondoR.Use(ah.MiddlewareValidateFondo)
+
 	// CORS
 	ch := gohandlers.CORS(gohandlers.AllowedOrigins([]string{"*"}))
 
@@ -101,7 +297,8 @@ func main() {
 	log.Println("Got signal:", sig)
 
 	// gracefully shutdown the server, waiting max 30 seconds for current operations to complete
-	ctx, _ := context.WithTimeout(context.Background(), 30*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
 	s.Shutdown(ctx)
 
 }
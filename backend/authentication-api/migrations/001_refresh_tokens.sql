@@ -0,0 +1,24 @@
+-- Refresh tokens issued by /signin and rotated by /token/refresh.
+-- Only the sha256 of the token is stored, familia groups every token
+-- rotated from the same signin so a reused token can revoke all of them.
+CREATE TABLE refresh_tokens (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NOT NULL,
+    tokenHash CHAR(64) NOT NULL,
+    familia VARCHAR(64) NOT NULL,
+    expira DATETIME NOT NULL,
+    revocado TINYINT(1) NOT NULL DEFAULT 0,
+    creado DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_refresh_tokens_hash (tokenHash),
+    KEY idx_refresh_tokens_familia (familia),
+    CONSTRAINT fk_refresh_tokens_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
+
+-- Denylist of access tokens (jti claim) revoked before they expire.
+-- Rows can be deleted once expira is in the past.
+CREATE TABLE revoked_tokens (
+    jti VARCHAR(64) NOT NULL,
+    expira DATETIME NOT NULL,
+    PRIMARY KEY (jti)
+);
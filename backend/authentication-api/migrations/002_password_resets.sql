@@ -0,0 +1,13 @@
+-- Single use tokens sent by /password/forgot, only the sha256 of the token is stored.
+CREATE TABLE password_resets (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NOT NULL,
+    tokenHash CHAR(64) NOT NULL,
+    expira DATETIME NOT NULL,
+    usado TINYINT(1) NOT NULL DEFAULT 0,
+    creado DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_password_resets_hash (tokenHash),
+    KEY idx_password_resets_usuario (idUsuario),
+    CONSTRAINT fk_password_resets_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
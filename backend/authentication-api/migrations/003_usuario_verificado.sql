@@ -0,0 +1,4 @@
+-- New accounts start unverified until the member opens the link sent to their email.
+-- Existing accounts are considered verified.
+ALTER TABLE usuario ADD COLUMN verificado TINYINT(1) NOT NULL DEFAULT 0;
+UPDATE usuario SET verificado = 1;
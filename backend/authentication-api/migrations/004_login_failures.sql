@@ -0,0 +1,11 @@
+-- Failed signins, counted per usuario and per ip to throttle and lock /signin.
+-- Rows are deleted on a successful signin or when an administrator unlocks the account.
+CREATE TABLE login_failures (
+    id INT NOT NULL AUTO_INCREMENT,
+    usuario VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_login_failures_usuario (usuario, fecha),
+    KEY idx_login_failures_ip (ip, fecha)
+);
@@ -0,0 +1,25 @@
+-- TOTP (RFC 6238) secrets, activo is set once the user confirms a first code.
+-- ultimoPaso is the last time step accepted so a code can't be replayed.
+CREATE TABLE usuario_totp (
+    idUsuario INT NOT NULL,
+    secreto VARCHAR(64) NOT NULL,
+    activo TINYINT(1) NOT NULL DEFAULT 0,
+    ultimoPaso BIGINT NOT NULL DEFAULT 0,
+    creado DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (idUsuario),
+    CONSTRAINT fk_usuario_totp_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
+
+-- Single use recovery codes, only their sha256 is stored.
+CREATE TABLE usuario_codigos_recuperacion (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NOT NULL,
+    codigoHash CHAR(64) NOT NULL,
+    usado TINYINT(1) NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    KEY idx_codigos_recuperacion_usuario (idUsuario),
+    CONSTRAINT fk_codigos_recuperacion_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
+
+-- Authentication methods used on the signin of each refresh token, copied on rotation.
+ALTER TABLE refresh_tokens ADD COLUMN amr VARCHAR(32) NOT NULL DEFAULT 'pwd' AFTER familia;
@@ -0,0 +1,2 @@
+-- Users are deactivated instead of deleted so their aportes and creditos history is kept.
+ALTER TABLE usuario ADD COLUMN activo TINYINT(1) NOT NULL DEFAULT 1;
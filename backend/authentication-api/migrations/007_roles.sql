@@ -0,0 +1,18 @@
+-- tokenVersion is incremented when the tokens already issued to an user must stop
+-- being accepted, for example after a role change. Access tokens carry it on the ver claim.
+ALTER TABLE usuario ADD COLUMN tokenVersion INT NOT NULL DEFAULT 0;
+
+-- Changes made by an administrator to an user.
+CREATE TABLE usuario_auditoria (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NOT NULL,
+    idActor INT NOT NULL,
+    accion VARCHAR(32) NOT NULL,
+    anterior VARCHAR(255) NOT NULL,
+    nuevo VARCHAR(255) NOT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_usuario_auditoria_usuario (idUsuario, fecha),
+    CONSTRAINT fk_usuario_auditoria_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id),
+    CONSTRAINT fk_usuario_auditoria_actor FOREIGN KEY (idActor) REFERENCES usuario (id)
+);
@@ -0,0 +1,5 @@
+-- usuario and email identify an user on /signin, so they can't be repeated.
+-- Existing duplicates have to be fixed by hand before running this migration.
+ALTER TABLE usuario
+    ADD UNIQUE KEY uq_usuario_usuario (usuario),
+    ADD UNIQUE KEY uq_usuario_email (email);
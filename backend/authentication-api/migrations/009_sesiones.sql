@@ -0,0 +1,30 @@
+-- Every signin attempt, idUsuario is NULL when the usuario does not exist.
+CREATE TABLE login_events (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NULL,
+    usuario VARCHAR(255) NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    userAgent VARCHAR(255) NOT NULL,
+    resultado VARCHAR(32) NOT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_login_events_usuario (idUsuario, fecha),
+    KEY idx_login_events_fecha (fecha)
+);
+
+-- A session is started on each signin and groups the refresh tokens of its familia.
+-- Access tokens carry the familia on the sid claim so a revoked session stops being accepted.
+CREATE TABLE sesiones (
+    id INT NOT NULL AUTO_INCREMENT,
+    familia VARCHAR(64) NOT NULL,
+    idUsuario INT NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    userAgent VARCHAR(255) NOT NULL,
+    creada DATETIME NOT NULL,
+    ultimoUso DATETIME NOT NULL,
+    revocada TINYINT(1) NOT NULL DEFAULT 0,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_sesiones_familia (familia),
+    KEY idx_sesiones_usuario (idUsuario),
+    CONSTRAINT fk_sesiones_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
@@ -0,0 +1,22 @@
+-- Invitation codes needed to sign up, only the sha256 of the code is stored.
+-- email and idRol bind the invitation, usos counts the signups made with it.
+CREATE TABLE invitaciones (
+    id INT NOT NULL AUTO_INCREMENT,
+    codigoHash CHAR(64) NOT NULL,
+    email VARCHAR(255) NULL,
+    idRol INT NOT NULL DEFAULT 3,
+    usosMaximos INT NOT NULL DEFAULT 1,
+    usos INT NOT NULL DEFAULT 0,
+    expira DATETIME NOT NULL,
+    revocada TINYINT(1) NOT NULL DEFAULT 0,
+    idCreador INT NOT NULL,
+    creada DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_invitaciones_codigo (codigoHash),
+    CONSTRAINT fk_invitaciones_creador FOREIGN KEY (idCreador) REFERENCES usuario (id)
+);
+
+-- Invitation used by each member, NULL for the members that joined before invitations.
+ALTER TABLE usuario
+    ADD COLUMN idInvitacion INT NULL,
+    ADD CONSTRAINT fk_usuario_invitacion FOREIGN KEY (idInvitacion) REFERENCES invitaciones (id);
@@ -0,0 +1,5 @@
+-- New members wait in 'pendiente' until an administrator approves or rejects them,
+-- members that joined before the approval queue are approved.
+ALTER TABLE usuario
+    ADD COLUMN estado VARCHAR(16) NOT NULL DEFAULT 'aprobado',
+    ADD COLUMN motivoEstado VARCHAR(255) NULL;
@@ -0,0 +1,34 @@
+-- A fondo is a family fund, users can belong to several fondos with a different rol in each.
+-- Every existing user joins fondo 1 with its current rol, usuario.idRol is kept for
+-- reference but roles are read from fondo_usuario.
+CREATE TABLE fondo (
+    id INT NOT NULL AUTO_INCREMENT,
+    nombre VARCHAR(255) NOT NULL,
+    creado DATETIME NOT NULL,
+    PRIMARY KEY (id)
+);
+
+INSERT INTO fondo (id, nombre, creado) VALUES (1, 'Fondo familiar', NOW());
+
+CREATE TABLE fondo_usuario (
+    idFondo INT NOT NULL,
+    idUsuario INT NOT NULL,
+    idRol INT NOT NULL DEFAULT 3,
+    creado DATETIME NOT NULL,
+    PRIMARY KEY (idFondo, idUsuario),
+    KEY idx_fondo_usuario_usuario (idUsuario),
+    CONSTRAINT fk_fondo_usuario_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_fondo_usuario_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
+
+INSERT INTO fondo_usuario (idFondo, idUsuario, idRol, creado) SELECT 1, id, idRol, NOW() FROM usuario;
+
+-- Invitations join the user to the fondo of the administrator that created them.
+ALTER TABLE invitaciones
+    ADD COLUMN idFondo INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT fk_invitaciones_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id);
+
+-- Active fondo of each session, the access tokens carry it on the fondo claim.
+ALTER TABLE sesiones
+    ADD COLUMN idFondo INT NOT NULL DEFAULT 1,
+    ADD CONSTRAINT fk_sesiones_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id);
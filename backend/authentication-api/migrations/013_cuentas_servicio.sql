@@ -0,0 +1,49 @@
+-- Service accounts let scripts call the app without signing in as a person, each one
+-- belongs to a fondo and can hold several API keys.
+CREATE TABLE cuentas_servicio (
+    id INT NOT NULL AUTO_INCREMENT,
+    idFondo INT NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    activa TINYINT(1) NOT NULL DEFAULT 1,
+    idCreador INT NOT NULL,
+    creada DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_cuentas_servicio_fondo (idFondo),
+    CONSTRAINT fk_cuentas_servicio_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id),
+    CONSTRAINT fk_cuentas_servicio_creador FOREIGN KEY (idCreador) REFERENCES usuario (id)
+);
+
+-- API keys look like ffk_<prefijo>_<secreto>, the prefijo is used to find the key and
+-- only the sha256 of the whole key is stored. scopes is a comma separated list.
+CREATE TABLE api_keys (
+    id INT NOT NULL AUTO_INCREMENT,
+    idCuenta INT NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    prefijo CHAR(8) NOT NULL,
+    claveHash CHAR(64) NOT NULL,
+    scopes VARCHAR(255) NOT NULL,
+    expira DATETIME NULL,
+    revocada TINYINT(1) NOT NULL DEFAULT 0,
+    ultimoUso DATETIME NULL,
+    idCreador INT NOT NULL,
+    creada DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY uq_api_keys_prefijo (prefijo),
+    KEY idx_api_keys_cuenta (idCuenta),
+    CONSTRAINT fk_api_keys_cuenta FOREIGN KEY (idCuenta) REFERENCES cuentas_servicio (id),
+    CONSTRAINT fk_api_keys_creador FOREIGN KEY (idCreador) REFERENCES usuario (id)
+);
+
+-- Every request made with an API key, written by the app.
+CREATE TABLE api_keys_uso (
+    id INT NOT NULL AUTO_INCREMENT,
+    idKey INT NOT NULL,
+    metodo VARCHAR(10) NOT NULL,
+    ruta VARCHAR(255) NOT NULL,
+    estado INT NOT NULL,
+    ip VARCHAR(45) NOT NULL,
+    fecha DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_api_keys_uso_key_fecha (idKey, fecha),
+    CONSTRAINT fk_api_keys_uso_key FOREIGN KEY (idKey) REFERENCES api_keys (id)
+);
@@ -0,0 +1,26 @@
+-- Identity document, address and birth date of the members, NULL for the members that
+-- joined before they were asked on signup.
+ALTER TABLE usuario
+    ADD COLUMN cedula VARCHAR(20) NULL,
+    ADD COLUMN direccion VARCHAR(255) NULL,
+    ADD COLUMN fechaNacimiento DATE NULL,
+    ADD UNIQUE KEY uq_usuario_cedula (cedula);
+
+-- Who receives the aportes of a member when the member dies, the porcentajes of the
+-- beneficiarios of a member add up to 100. The whole set is replaced on every change.
+CREATE TABLE beneficiarios (
+    id INT NOT NULL AUTO_INCREMENT,
+    idUsuario INT NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    cedula VARCHAR(20) NOT NULL,
+    parentesco VARCHAR(64) NOT NULL,
+    porcentaje DECIMAL(5,2) NOT NULL,
+    PRIMARY KEY (id),
+    KEY idx_beneficiarios_usuario (idUsuario),
+    CONSTRAINT fk_beneficiarios_usuario FOREIGN KEY (idUsuario) REFERENCES usuario (id)
+);
+
+-- The history of the beneficiarios is kept on usuario_auditoria as JSON, which does not fit in 255.
+ALTER TABLE usuario_auditoria
+    MODIFY anterior TEXT NOT NULL,
+    MODIFY nuevo TEXT NOT NULL;
@@ -0,0 +1,3 @@
+-- Members that leave a fondo through a liquidacion are kept inactive in it so their
+-- aportes and creditos can still be read. Inactive members can't get tokens for the fondo.
+ALTER TABLE fondo_usuario ADD COLUMN activo TINYINT(1) NOT NULL DEFAULT 1;
@@ -0,0 +1,4 @@
+-- Token version of each membership, it is incremented when tokens issued for the fondo must
+-- stop working, like on a rol change, without signing the user out of its other fondos.
+-- Access tokens carry it on the fver claim next to the ver claim of usuario.tokenVersion.
+ALTER TABLE fondo_usuario ADD COLUMN tokenVersion INT NOT NULL DEFAULT 0;
@@ -0,0 +1,13 @@
+-- The approval estado of a member belongs to its membership, an administrator can only decide on,
+-- or deactivate, the members of its own fondo. Users that join another fondo wait for its approval.
+-- The current estado and deactivation of each user are copied to its memberships, usuario.estado,
+-- usuario.motivoEstado and usuario.activo are kept for reference but are read from fondo_usuario.
+ALTER TABLE fondo_usuario
+    ADD COLUMN estado VARCHAR(16) NOT NULL DEFAULT 'aprobado',
+    ADD COLUMN motivoEstado VARCHAR(255) NULL;
+
+UPDATE fondo_usuario JOIN usuario ON usuario.id = fondo_usuario.idUsuario
+    SET fondo_usuario.estado = usuario.estado, fondo_usuario.motivoEstado = usuario.motivoEstado;
+
+UPDATE fondo_usuario JOIN usuario ON usuario.id = fondo_usuario.idUsuario
+    SET fondo_usuario.activo = 0 WHERE usuario.activo = 0;
@@ -0,0 +1,5 @@
+-- Creating fondos is a capability of the account, not of the rol in a fondo, so an administrator
+-- of one fondo can't create others. The administrators of the first fondo keep being able to.
+ALTER TABLE usuario ADD COLUMN creaFondos TINYINT(1) NOT NULL DEFAULT 0;
+
+UPDATE usuario SET creaFondos = 1 WHERE id IN (SELECT idUsuario FROM fondo_usuario WHERE idFondo = 1 AND idRol = 1 AND activo = 1);
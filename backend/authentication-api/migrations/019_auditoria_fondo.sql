@@ -0,0 +1,11 @@
+-- The rol, deactivation and estado of an user belong to its membership in a fondo, their audit
+-- entries record the fondo so an administrator only sees the history of its own fondo.
+-- idFondo is NULL for the changes to the account, which is shared by every fondo of the user.
+ALTER TABLE usuario_auditoria
+    ADD COLUMN idFondo INT NULL AFTER idUsuario,
+    ADD KEY idx_usuario_auditoria_fondo (idUsuario, idFondo, fecha),
+    ADD CONSTRAINT fk_usuario_auditoria_fondo FOREIGN KEY (idFondo) REFERENCES fondo (id);
+
+UPDATE usuario_auditoria
+    SET idFondo = CAST(SUBSTRING_INDEX(SUBSTRING(nuevo, 7), ':', 1) AS UNSIGNED)
+    WHERE accion IN ('rol', 'desactivar', 'estado') AND nuevo LIKE 'fondo %:%';
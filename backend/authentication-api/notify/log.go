@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+// Log writes notifications to the logger and optionally appends them to a file,
+// it is meant for local testing where there is no SMTP server
+type Log struct {
+	l    hclog.Logger
+	path string
+	mu   sync.Mutex
+}
+
+// NewLog creates a notifier that logs every message, path can be empty
+func NewLog(l hclog.Logger, path string) *Log {
+	return &Log{l: l, path: path}
+}
+
+// Notify logs the message and appends it to the file
+func (n *Log) Notify(to string, subject string, body string) error {
+	n.l.Info("[Notify] Sending notification", "to", to, "subject", subject, "body", body)
+
+	if n.path == "" {
+		return nil
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n", time.Now().Format(time.RFC3339), to, subject, body)
+
+	return err
+}
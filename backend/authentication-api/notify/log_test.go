@@ -0,0 +1,37 @@
+package notify
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+)
+
+func TestLogNotify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notificaciones.txt")
+	n := NewLog(hclog.NewNullLogger(), path)
+
+	for _, subject := range []string{"Reset your password", "Verify your email"} {
+		if err := n.Notify("ana@x.co", subject, "body of "+subject); err != nil {
+			t.Fatalf("Notify error = %v", err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"To: ana@x.co\nSubject: Reset your password\n\nbody of Reset your password", "Subject: Verify your email"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("notifications file = %q, want it to contain %q", b, want)
+		}
+	}
+}
+
+func TestLogNotifyWithoutFile(t *testing.T) {
+	if err := NewLog(hclog.NewNullLogger(), "").Notify("ana@x.co", "subject", "body"); err != nil {
+		t.Errorf("Notify error = %v", err)
+	}
+}
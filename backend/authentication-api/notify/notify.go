@@ -0,0 +1,6 @@
+package notify
+
+// Notifier sends a message to a user, to is the email address of the user
+type Notifier interface {
+	Notify(to string, subject string, body string) error
+}
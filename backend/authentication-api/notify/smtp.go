@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP sends notifications as plain text emails
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+}
+
+// NewSMTP creates a notifier that sends emails through the given SMTP server
+func NewSMTP(host string, port string, user string, pass string, from string) *SMTP {
+	var auth smtp.Auth
+	if user != "" {
+		auth = smtp.PlainAuth("", user, pass, host)
+	}
+
+	return &SMTP{net.JoinHostPort(host, port), auth, from}
+}
+
+// Notify sends an email to the given address
+func (s *SMTP) Notify(to string, subject string, body string) error {
+	if strings.ContainsAny(to+subject, "\r\n") {
+		return fmt.Errorf("Invalid email header")
+	}
+
+	msg := "From: " + s.from + "\r\n" +
+		"To: " + to + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + body + "\r\n"
+
+	return smtp.SendMail(s.addr, s.auth, s.from, []string{to}, []byte(msg))
+}